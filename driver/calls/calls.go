@@ -0,0 +1,477 @@
+// Package calls extracts the call graph of a Python file from its semantic UAST, as
+// produced by normalizer.Transforms or normalizer.ScopeTransforms: the calls made by
+// each function, resolved to the functions and classes of the file or to the names
+// imported in it.
+package calls
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/bblfsh/python-driver/driver/normalizer"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// ErrNotModule is returned when the root of the UAST is not a Python module.
+var ErrNotModule = errors.New("calls: the root node is not a module")
+
+// Module is the name of the caller of the calls in the module scope.
+const Module = "<module>"
+
+// Kind is the kind of callee of a call.
+type Kind string
+
+const (
+	// Function is a function defined in the file.
+	Function Kind = "function"
+	// Method is a method of a class of the file, called on the first parameter of
+	// another method, like self.method(), also in the functions nested in it, or on
+	// the class.
+	Method Kind = "method"
+	// Class is the instantiation of a class of the file.
+	Class Kind = "class"
+	// Import is a name imported in the file, or an attribute of it.
+	Import Kind = "import"
+	// Builtin is a builtin function or class that is not shadowed in the file.
+	Builtin Kind = "builtin"
+	// Dynamic is a callee that cannot be resolved statically, like a variable, an
+	// attribute of an object or the result of an expression.
+	Dynamic Kind = "dynamic"
+)
+
+// Call is an edge of the call graph.
+type Call struct {
+	// Caller is the qualified name of the function or class making the call, like the
+	// __qualname__ of Python, or Module for the code in the module scope. The calls
+	// in the decorators and the default values of the parameters are made by the
+	// enclosing scope.
+	Caller string
+	// Callee is the qualified name of the function, method or class called, like
+	// "func", "Class.method" or "outer.<locals>.inner". For imported names it's the
+	// path of the name, like "os.path.join", starting with a dot for each level of
+	// the relative imports, and for builtins, like "builtins.len". For dynamic calls
+	// it's the called name or attribute, like "obj.method", or empty for the other
+	// expressions.
+	Callee    string
+	Kind      Kind
+	Positions uast.Positions
+}
+
+// FromUAST returns the calls of the semantic UAST of a module, sorted by position. The
+// names are resolved to their variables by normalizer.Scopes, that follows the scoping
+// rules of Python: a name bound anywhere in a function is local to it, the names of
+// the class bodies are not visible from their methods, and global and nonlocal
+// declarations are honoured. When a variable has more than one binding site, the last
+// one is used.
+func FromUAST(root nodes.Node) ([]Call, error) {
+	mod, ok := root.(nodes.Object)
+	if !ok || typeOf(mod) != "Module" {
+		return nil, ErrNotModule
+	}
+	root, err := normalizer.Scopes.Do(mod)
+	if err != nil {
+		return nil, err
+	}
+	w := &walker{
+		bindings: make(map[string]binding),
+		selfs:    make(map[string]*class),
+	}
+	w.walk(root.(nodes.Object)["body"], frame{caller: Module})
+	calls := make([]Call, 0, len(w.calls))
+	for _, c := range w.calls {
+		if c.names != nil {
+			c.Callee, c.Kind = w.resolve(c.names, c.binding)
+		}
+		calls = append(calls, c.Call)
+	}
+	sort.SliceStable(calls, func(i, j int) bool {
+		return offset(calls[i].Positions) < offset(calls[j].Positions)
+	})
+	return calls, nil
+}
+
+// binding is what a variable refers to. The variables that are not functions, classes
+// or imports have the Dynamic kind.
+type binding struct {
+	kind Kind
+	// path is the qualified name of the functions and classes, or the path of the
+	// imported names.
+	path  string
+	class *class
+	// offset is the position of the binding site
+	offset uint32
+}
+
+type class struct {
+	qual    string
+	bases   nodes.Array
+	methods map[string]bool
+}
+
+// frame is the function or class whose body is walked.
+type frame struct {
+	caller string
+	// prefix is the prefix of the qualified names of the functions and classes defined
+	// in the body.
+	prefix string
+	// class is set for the class bodies.
+	class *class
+}
+
+// call is a call whose callee is resolved once all the binding sites are known.
+type call struct {
+	Call
+	// names are the names of the callee, if it's a name or attributes of a name
+	names []string
+	// binding is the ID of the variable of the first name
+	binding string
+}
+
+type walker struct {
+	// bindings are what the variables refer to, by the IDs set by normalizer.Scopes.
+	bindings map[string]binding
+	// selfs are the classes of the methods, by the ID of their first parameter.
+	selfs map[string]*class
+	// star is set for the files with "from module import *", that may bind any name.
+	star  bool
+	calls []call
+}
+
+// bind records the binding site of the variable of the identifier, if it's the last
+// one.
+func (w *walker) bind(id nodes.Node, b binding) {
+	obj, _ := id.(nodes.Object)
+	v := bindingOf(obj)
+	if v == "" {
+		return
+	}
+	b.offset = offset(uast.PositionsOf(obj))
+	if prev, ok := w.bindings[v]; !ok || prev.offset <= b.offset {
+		w.bindings[v] = b
+	}
+}
+
+func (w *walker) walk(n nodes.Node, f frame) {
+	switch n := n.(type) {
+	case nodes.Array:
+		for _, c := range n {
+			w.walk(c, f)
+		}
+	case nodes.Object:
+		switch typeOf(n) {
+		case uast.TypeOf(uast.FunctionGroup{}):
+			if meta, name, fn, ok := group(n); ok {
+				qual := f.prefix + identName(name)
+				w.walk(meta, f)
+				w.bind(name, binding{kind: Function, path: qual})
+				w.function(qual, fn, meta, f)
+				return
+			}
+		case uast.TypeOf(uast.Group{}):
+			if meta, name, body, ok := group(n); ok && typeOf(body) == uast.TypeOf(uast.Block{}) {
+				c := &class{qual: f.prefix + identName(name), methods: make(map[string]bool)}
+				c.bases, _ = meta["bases"].(nodes.Array)
+				stmts, _ := body["Statements"].(nodes.Array)
+				for _, st := range stmts {
+					st, _ := st.(nodes.Object)
+					if typeOf(st) != uast.TypeOf(uast.FunctionGroup{}) {
+						continue
+					}
+					if _, m, _, ok := group(st); ok {
+						c.methods[identName(m)] = true
+					}
+				}
+				w.walk(meta, f)
+				w.bind(name, binding{kind: Class, path: c.qual, class: c})
+				w.walk(body, frame{caller: c.qual, prefix: c.qual + ".", class: c})
+				return
+			}
+		case uast.TypeOf(uast.Function{}):
+			w.function(f.prefix+"<lambda>", n, nil, f)
+			return
+		case uast.TypeOf(uast.RuntimeImport{}):
+			w.imports(n)
+			return
+		case "Global", "Nonlocal":
+			// the declarations are not binding sites
+			return
+		case "BoxedName":
+			if n["ctx"] == nodes.String("Store") {
+				w.bind(n["boxed_value"], binding{kind: Dynamic})
+			}
+		case "ExceptHandler":
+			if name, _ := n["name"].(nodes.Object); typeOf(name) == uast.TypeOf(uast.Identifier{}) {
+				w.bind(name, binding{kind: Dynamic})
+			}
+		case "MatchAs", "MatchStar":
+			w.bind(n, binding{kind: Dynamic})
+		case "Call":
+			w.call(n, f)
+		}
+		for _, k := range n.Keys() {
+			w.walk(n[k], f)
+		}
+	}
+}
+
+// function walks a function or a lambda defined in the frame. The parameters are
+// evaluated by the caller, and the body by the function.
+func (w *walker) function(qual string, fn, meta nodes.Object, f frame) {
+	typ, _ := fn["Type"].(nodes.Object)
+	args, _ := typ["Arguments"].(nodes.Array)
+	if f.class != nil && meta["static"] != nodes.Bool(true) && len(args) != 0 {
+		// the positional parameters come first
+		first, _ := args[0].(nodes.Object)
+		if first["Variadic"] != nodes.Bool(true) && first["MapVariadic"] != nodes.Bool(true) {
+			name, _ := first["Name"].(nodes.Object)
+			if v := bindingOf(name); v != "" {
+				w.selfs[v] = f.class
+			}
+		}
+	}
+	w.walk(typ, f)
+	w.walk(fn["Body"], frame{caller: qual, prefix: qual + ".<locals>."})
+}
+
+func (w *walker) call(n nodes.Object, f frame) {
+	c := call{Call: Call{Caller: f.caller, Kind: Dynamic, Positions: uast.PositionsOf(n)}}
+	c.binding, c.names = chain(n["callee"])
+	w.calls = append(w.calls, c)
+}
+
+// resolve returns the callee of a call to a name or to attributes of a name, given the
+// ID of the variable of the name.
+func (w *walker) resolve(names []string, v string) (string, Kind) {
+	dotted := strings.Join(names, ".")
+	if c, ok := w.selfs[v]; ok {
+		if len(names) == 2 {
+			if m := w.method(c, names[1]); m != "" {
+				return m, Method
+			}
+		}
+		return dotted, Dynamic
+	}
+	b, ok := w.bindings[v]
+	if !ok {
+		if !w.star && v == "builtins."+names[0] && builtins[names[0]] {
+			return "builtins." + dotted, Builtin
+		}
+		return dotted, Dynamic
+	}
+	switch {
+	case b.kind == Import:
+		if len(names) == 1 {
+			return b.path, Import
+		}
+		return b.path + "." + strings.Join(names[1:], "."), Import
+	case b.kind == Function && len(names) == 1:
+		return b.path, Function
+	case b.kind == Class && len(names) == 1:
+		return b.path, Class
+	case b.kind == Class && len(names) == 2:
+		if m := w.method(b.class, names[1]); m != "" {
+			return m, Method
+		}
+	}
+	return dotted, Dynamic
+}
+
+// method returns the qualified name of a method of the class or of its bases defined
+// in the file, searched depth-first, or an empty string if it's not found.
+func (w *walker) method(c *class, name string) string {
+	return w.find(c, name, make(map[*class]bool))
+}
+
+func (w *walker) find(c *class, name string, seen map[*class]bool) string {
+	if c == nil || seen[c] {
+		return ""
+	}
+	seen[c] = true
+	if c.methods[name] {
+		return c.qual + "." + name
+	}
+	for _, base := range c.bases {
+		v, names := chain(base)
+		if len(names) != 1 {
+			continue
+		}
+		if b := w.bindings[v]; b.kind == Class {
+			if m := w.find(b.class, name, seen); m != "" {
+				return m
+			}
+		}
+	}
+	return ""
+}
+
+// imports binds the names of an import statement to their paths.
+func (w *walker) imports(obj nodes.Object) {
+	module := importPath(obj["Path"])
+	names, _ := obj["Names"].(nodes.Array)
+	if len(names) == 0 {
+		if obj["All"] == nodes.Bool(true) {
+			w.star = true
+			return
+		}
+		path, _ := obj["Path"].(nodes.Object)
+		switch typeOf(path) {
+		case uast.TypeOf(uast.Alias{}):
+			w.bind(path["Name"], binding{kind: Import, path: importPath(path["Node"])})
+		case uast.TypeOf(uast.QualifiedIdentifier{}):
+			// "import a.b" binds "a"
+			if ids, _ := path["Names"].(nodes.Array); len(ids) != 0 {
+				w.bind(ids[0], binding{kind: Import, path: identName(ids[0])})
+			}
+		default:
+			w.bind(path, binding{kind: Import, path: module})
+		}
+		return
+	}
+	prefix := module
+	if !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	for _, name := range names {
+		name, _ := name.(nodes.Object)
+		if typeOf(name) == uast.TypeOf(uast.Alias{}) {
+			w.bind(name["Name"], binding{kind: Import, path: prefix + importPath(name["Node"])})
+		} else {
+			w.bind(name, binding{kind: Import, path: prefix + identName(name)})
+		}
+	}
+}
+
+// importPath returns the dotted path of an identifier or qualified identifier of an
+// import. The levels of relative imports are ".." identifiers.
+func importPath(n nodes.Node) string {
+	obj, _ := n.(nodes.Object)
+	var ids nodes.Array
+	switch typeOf(obj) {
+	case uast.TypeOf(uast.Identifier{}):
+		ids = nodes.Array{obj}
+	case uast.TypeOf(uast.QualifiedIdentifier{}):
+		ids, _ = obj["Names"].(nodes.Array)
+	}
+	dots := ""
+	var names []string
+	for _, id := range ids {
+		switch name := identName(id); {
+		case name == ".." && len(names) == 0:
+			dots += "."
+		case name == "." && len(ids) == 1:
+			dots = "."
+		default:
+			names = append(names, name)
+		}
+	}
+	return dots + strings.Join(names, ".")
+}
+
+// chain returns the names of a callee that is a name or attributes of a name, like
+// "a.b.c", and the ID of the variable of the name, or nil for the other expressions.
+func chain(n nodes.Node) (string, []string) {
+	obj, _ := n.(nodes.Object)
+	switch typeOf(obj) {
+	case uast.TypeOf(uast.Identifier{}):
+		return bindingOf(obj), []string{identName(obj)}
+	case "BoxedName", "BoxedAttribute":
+		id, _ := obj["boxed_value"].(nodes.Object)
+		return bindingOf(id), []string{identName(id)}
+	case "QualifiedIdentifier":
+		ids, _ := obj["identifiers"].(nodes.Array)
+		var (
+			v   string
+			out []string
+		)
+		for i, id := range ids {
+			if i != 0 && typeOf(id) != "BoxedAttribute" {
+				return "", nil
+			}
+			b, names := chain(id)
+			if names == nil {
+				return "", nil
+			}
+			if i == 0 {
+				v = b
+			}
+			out = append(out, names...)
+		}
+		return v, out
+	}
+	return "", nil
+}
+
+// group returns the first node of a function or class group, and the identifier and
+// the node of the alias binding it.
+func group(obj nodes.Object) (meta, name, node nodes.Object, _ bool) {
+	list, _ := obj["Nodes"].(nodes.Array)
+	if len(list) != 2 {
+		return nil, nil, nil, false
+	}
+	meta, _ = list[0].(nodes.Object)
+	alias, ok := list[1].(nodes.Object)
+	if !ok || typeOf(alias) != uast.TypeOf(uast.Alias{}) {
+		return nil, nil, nil, false
+	}
+	name, _ = alias["Name"].(nodes.Object)
+	node, _ = alias["Node"].(nodes.Object)
+	return meta, name, node, true
+}
+
+// typeOf returns the type of the node without the namespace of the driver.
+func typeOf(n nodes.Node) string {
+	return strings.TrimPrefix(uast.TypeOf(n), "python:")
+}
+
+// bindingOf returns the ID of the variable of the node set by normalizer.Scopes.
+func bindingOf(obj nodes.Object) string {
+	v, _ := obj["binding"].(nodes.String)
+	return string(v)
+}
+
+func identName(n nodes.Node) string {
+	obj, _ := n.(nodes.Object)
+	name, _ := obj["Name"].(nodes.String)
+	return string(name)
+}
+
+func offset(pos uast.Positions) uint32 {
+	if start := pos.Start(); start != nil {
+		return start.Offset
+	}
+	return 0
+}
+
+// builtins are the builtin functions and classes of Python 3 and Python 2.
+var builtins = make(map[string]bool)
+
+func init() {
+	for _, name := range strings.Fields(`
+		abs aiter all anext any ascii bin bool breakpoint bytearray bytes callable chr
+		classmethod compile complex delattr dict dir divmod enumerate eval exec filter
+		float format frozenset getattr globals hasattr hash help hex id input int
+		isinstance issubclass iter len list locals map max memoryview min next object
+		oct open ord pow print property range repr reversed round set setattr slice
+		sorted staticmethod str sum super tuple type vars zip __import__
+
+		apply basestring buffer cmp coerce execfile file intern long raw_input reduce
+		reload unichr unicode xrange
+
+		BaseException Exception ArithmeticError AssertionError AttributeError
+		BlockingIOError BrokenPipeError BufferError ChildProcessError
+		ConnectionAbortedError ConnectionError ConnectionRefusedError
+		ConnectionResetError EOFError EnvironmentError FileExistsError
+		FileNotFoundError FloatingPointError GeneratorExit IOError ImportError
+		IndentationError IndexError InterruptedError IsADirectoryError KeyError
+		KeyboardInterrupt LookupError MemoryError ModuleNotFoundError NameError
+		NotADirectoryError NotImplementedError OSError OverflowError PermissionError
+		ProcessLookupError RecursionError ReferenceError RuntimeError
+		StopAsyncIteration StopIteration SyntaxError SystemError SystemExit TabError
+		TimeoutError TypeError UnboundLocalError UnicodeDecodeError UnicodeEncodeError
+		UnicodeError UnicodeTranslateError ValueError ZeroDivisionError
+	`) {
+		builtins[name] = true
+	}
+}
@@ -0,0 +1,160 @@
+package calls
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/bblfsh/python-driver/driver/normalizer"
+	"github.com/bblfsh/python-driver/driver/parser"
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+	"github.com/stretchr/testify/require"
+)
+
+const fixturesDir = "../../fixtures"
+
+type edge struct {
+	Caller string
+	Callee string
+	Kind   Kind
+	Line   uint32
+}
+
+func callGraph(t testing.TB, src string) []edge {
+	ast, err := parser.Parse(src)
+	require.NoError(t, err)
+	ast, err = normalizer.Transforms.Do(context.Background(), driver.ModeSemantic, src, ast)
+	require.NoError(t, err)
+	calls, err := FromUAST(ast)
+	require.NoError(t, err)
+	var out []edge
+	for _, c := range calls {
+		e := edge{Caller: c.Caller, Callee: c.Callee, Kind: c.Kind}
+		if start := c.Positions.Start(); start != nil {
+			e.Line = start.Line
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestCalls(t *testing.T) {
+	const src = `import os.path
+from .util import helper as h
+
+def main(argv):
+    app = App(argv)
+    app.run()
+    run(h(argv), os.path.join("a", "b"))
+    return len(argv)
+
+def run(*args):
+    def inner():
+        return run()
+    global main
+    main([])
+    inner()
+    args[0]()
+
+class Base:
+    def start(self):
+        pass
+
+class App(Base):
+    def __init__(self, argv, key=sorted([])):
+        self.argv = argv
+        self.start()
+
+    def run(this):
+        this.stop()
+        this.argv.pop()
+        App.start(this)
+        lambda: this.run()
+
+    @staticmethod
+    def stop(self):
+        self.run()
+`
+	require.Equal(t, []edge{
+		{Caller: "main", Callee: "App", Kind: Class, Line: 5},
+		{Caller: "main", Callee: "app.run", Kind: Dynamic, Line: 6},
+		{Caller: "main", Callee: "run", Kind: Function, Line: 7},
+		{Caller: "main", Callee: ".util.helper", Kind: Import, Line: 7},
+		{Caller: "main", Callee: "os.path.join", Kind: Import, Line: 7},
+		{Caller: "main", Callee: "builtins.len", Kind: Builtin, Line: 8},
+		{Caller: "run.<locals>.inner", Callee: "run", Kind: Function, Line: 12},
+		{Caller: "run", Callee: "main", Kind: Function, Line: 14},
+		{Caller: "run", Callee: "run.<locals>.inner", Kind: Function, Line: 15},
+		{Caller: "run", Callee: "", Kind: Dynamic, Line: 16},
+		{Caller: "App", Callee: "builtins.sorted", Kind: Builtin, Line: 23},
+		{Caller: "App.__init__", Callee: "Base.start", Kind: Method, Line: 25},
+		{Caller: "App.run", Callee: "App.stop", Kind: Method, Line: 28},
+		{Caller: "App.run", Callee: "this.argv.pop", Kind: Dynamic, Line: 29},
+		{Caller: "App.run", Callee: "Base.start", Kind: Method, Line: 30},
+		{Caller: "App.run.<locals>.<lambda>", Callee: "App.run", Kind: Method, Line: 31},
+		{Caller: "App.stop", Callee: "self.run", Kind: Dynamic, Line: 35},
+	}, callGraph(t, src))
+}
+
+// TestImportAliases checks that the calls through the names bound by the imports of
+// the fixtures are resolved to the imported paths.
+func TestImportAliases(t *testing.T) {
+	for _, c := range []struct {
+		fixture string
+		calls   string
+		callees []string
+	}{
+		{
+			fixture: "u2_import_rename.py",
+			calls:   "b()\nf()\n",
+			callees: []string{"a", "c.e"},
+		},
+		{
+			fixture: "u2_import_subsymbol_alias.py",
+			calls:   "z.w()\nd.m()\n",
+			callees: []string{"x.y.w", "a.b.c.m"},
+		},
+	} {
+		t.Run(c.fixture, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filepath.Join(fixturesDir, c.fixture))
+			require.NoError(t, err)
+			var callees []string
+			for _, e := range callGraph(t, string(data)+c.calls) {
+				require.Equal(t, Import, e.Kind)
+				require.Equal(t, Module, e.Caller)
+				callees = append(callees, e.Callee)
+			}
+			require.Equal(t, c.callees, callees)
+		})
+	}
+}
+
+// TestFixtures checks that the calls of all the fixtures have a caller and a position,
+// and that only the dynamic ones may have no callee.
+func TestFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(fixturesDir, "*.sem.uast"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, path := range files {
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		ast, err := uastyaml.Unmarshal(data)
+		require.NoError(t, err)
+		calls, err := FromUAST(ast)
+		require.NoError(t, err, path)
+		for _, c := range calls {
+			require.NotEmpty(t, c.Caller, path)
+			require.NotNil(t, c.Positions.Start(), path)
+			if c.Kind != Dynamic {
+				require.NotEmpty(t, c.Callee, path)
+			}
+		}
+	}
+
+	_, err = FromUAST(nodes.Object{})
+	require.Equal(t, ErrNotModule, err)
+}
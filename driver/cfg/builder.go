@@ -0,0 +1,501 @@
+package cfg
+
+import (
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+type frameKind int
+
+const (
+	loopFrame frameKind = iota
+	handlerFrame
+	finallyFrame
+)
+
+// frame is a statement enclosing the current one that changes the target of the jumps.
+type frame struct {
+	kind frameKind
+	// brk and cont are the targets of break and continue in the body of a loop.
+	brk, cont *Block
+	// target receives the exceptions raised in the body of a try with except clauses,
+	// and all the jumps leaving the body of a try with a finally clause or of a with
+	// statement.
+	target *Block
+	// pending are the kinds of the jumps through a finally clause or the exit of a with
+	// statement, that continue to their own targets once it completes.
+	pending []EdgeKind
+}
+
+type builder struct {
+	g *Graph
+	// cur is the block being built, or nil after a jump: the statements that follow
+	// are dead code.
+	cur    *Block
+	frames []*frame
+}
+
+func (b *builder) newBlock(label string) *Block {
+	blk := &Block{ID: len(b.g.Blocks), Label: label}
+	b.g.Blocks = append(b.g.Blocks, blk)
+	return blk
+}
+
+// block returns the current block, starting one if the code is dead.
+func (b *builder) block() *Block {
+	if b.cur == nil {
+		b.cur = b.newBlock("dead")
+	}
+	return b.cur
+}
+
+func (b *builder) link(from, to *Block, kind EdgeKind) {
+	if from == nil || to == nil {
+		return
+	}
+	for _, e := range from.Succs {
+		if e.To == to && e.Kind == kind {
+			return
+		}
+	}
+	from.Succs = append(from.Succs, Edge{To: to, Kind: kind})
+}
+
+func (b *builder) push(f *frame) {
+	b.frames = append(b.frames, f)
+}
+
+func (b *builder) pop() {
+	b.frames = b.frames[:len(b.frames)-1]
+}
+
+// target returns the target of a jump from the statements in the first depth frames.
+// The jumps through finally clauses and with statements are recorded in their frame.
+func (b *builder) target(kind EdgeKind, depth int) *Block {
+	for i := depth - 1; i >= 0; i-- {
+		f := b.frames[i]
+		switch {
+		case f.kind == loopFrame && kind == Break:
+			return f.brk
+		case f.kind == loopFrame && kind == Continue:
+			return f.cont
+		case f.kind == handlerFrame && kind == Exception:
+			return f.target
+		case f.kind == finallyFrame:
+			if !hasKind(f.pending, kind) {
+				f.pending = append(f.pending, kind)
+			}
+			return f.target
+		}
+	}
+	return b.g.Exit
+}
+
+func hasKind(kinds []EdgeKind, kind EdgeKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// jump ends the current block with a jump of the given kind.
+func (b *builder) jump(kind EdgeKind) {
+	b.link(b.block(), b.target(kind, len(b.frames)), kind)
+	b.cur = nil
+}
+
+// leave continues the jumps through a finally clause or a with statement, from the
+// block ending it. Nothing continues if it doesn't complete.
+func (b *builder) leave(f *frame, end *Block) {
+	if end == nil {
+		return
+	}
+	for _, k := range f.pending {
+		b.link(end, b.target(k, len(b.frames)), k)
+	}
+}
+
+// add appends a node to the current block. In the body of a try or with statement,
+// the node may raise an exception.
+func (b *builder) add(n nodes.Node) {
+	blk := b.block()
+	blk.Nodes = append(blk.Nodes, n)
+	for _, f := range b.frames {
+		if f.kind != loopFrame {
+			b.link(blk, b.target(Exception, len(b.frames)), Exception)
+			break
+		}
+	}
+}
+
+// simple adds a statement that doesn't jump, other than by yielding.
+func (b *builder) simple(n nodes.Node) {
+	b.add(n)
+	if hasYield(n) {
+		resume := b.newBlock("yield.resume")
+		b.link(b.cur, resume, Yield)
+		b.cur = resume
+	}
+}
+
+// hasYield reports whether an expression or statement yields, not counting the yields
+// of the functions and classes defined in it.
+func hasYield(n nodes.Node) bool {
+	found := false
+	nodes.WalkPreOrder(n, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok || found {
+			return !found
+		}
+		switch uast.TypeOf(obj) {
+		case uast.TypeOf(uast.Function{}), uast.TypeOf(uast.FunctionGroup{}), uast.TypeOf(uast.Group{}):
+			return false
+		}
+		switch typeOf(obj) {
+		case "Yield", "YieldFrom":
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// stmts adds a block or a list of statements.
+func (b *builder) stmts(n nodes.Node) {
+	switch n := n.(type) {
+	case nodes.Array:
+		for _, s := range n {
+			b.stmts(s)
+		}
+	case nodes.Object:
+		if uast.TypeOf(n) == uast.TypeOf(uast.Block{}) {
+			b.stmts(n["Statements"])
+			return
+		}
+		b.stmt(n)
+	}
+}
+
+func (b *builder) stmt(obj nodes.Object) {
+	switch typ := typeOf(obj); typ {
+	case "If":
+		b.ifStmt(obj)
+	case "Loop":
+		if obj["kind"] == nodes.String("while") {
+			b.whileStmt(obj)
+		} else {
+			b.forStmt(obj)
+		}
+	case "Try":
+		b.tryStmt(obj)
+	case "With":
+		b.withStmt(obj)
+	case "Match":
+		b.matchStmt(obj)
+	case "Assert":
+		b.assertStmt(obj)
+	case "Return":
+		b.simple(obj)
+		b.jump(Return)
+	case "Raise":
+		b.simple(obj)
+		b.jump(Exception)
+	case "Break":
+		b.add(obj)
+		b.jump(Break)
+	case "Continue":
+		b.add(obj)
+		b.jump(Continue)
+	default:
+		if strings.HasSuffix(typ, "Noops") {
+			// only comments
+			return
+		}
+		b.simple(obj)
+	}
+}
+
+// branch adds a condition, jumping to t if it's true and to f if it's false. The
+// boolean operators short-circuit, so each operand is evaluated in its own block.
+func (b *builder) branch(cond nodes.Node, t, f *Block) {
+	switch typeOf(cond) {
+	case "BoolOp":
+		obj := cond.(nodes.Object)
+		values, _ := obj["values"].(nodes.Array)
+		if len(values) == 0 {
+			break
+		}
+		and := typeOf(obj["op"]) == "And"
+		for _, v := range values[:len(values)-1] {
+			next := b.newBlock("cond")
+			if and {
+				b.branch(v, next, f)
+			} else {
+				b.branch(v, t, next)
+			}
+			b.cur = next
+		}
+		b.branch(values[len(values)-1], t, f)
+		return
+	case "UnaryOp":
+		obj := cond.(nodes.Object)
+		if typeOf(obj["op"]) == "Not" {
+			b.branch(obj["operand"], f, t)
+			return
+		}
+	}
+	b.add(cond)
+	b.link(b.cur, t, True)
+	if !isTrue(cond) {
+		b.link(b.cur, f, False)
+	}
+	b.cur = nil
+}
+
+// isTrue reports whether a condition is the constant True or 1, like in the infinite
+// loops.
+func isTrue(cond nodes.Node) bool {
+	obj, _ := cond.(nodes.Object)
+	switch typeOf(obj) {
+	case "BoxedBoolLiteral":
+		return obj["LiteralValue"] == nodes.String("True")
+	case "Num":
+		return obj[uast.KeyToken] == nodes.String("1")
+	}
+	return false
+}
+
+func (b *builder) ifStmt(obj nodes.Object) {
+	then := b.newBlock("if.then")
+	end := b.newBlock("if.end")
+	els := end
+	if obj["else"] != nil {
+		els = b.newBlock("if.else")
+	}
+	b.branch(obj["condition"], then, els)
+	b.cur = then
+	b.stmts(obj["then"])
+	b.link(b.cur, end, Next)
+	if els != end {
+		b.cur = els
+		b.stmts(obj["else"])
+		b.link(b.cur, end, Next)
+	}
+	b.cur = end
+}
+
+// loop adds the body of a loop starting at head, and its else clause, that runs when
+// the loop ends without a break.
+func (b *builder) loop(obj nodes.Object, head, body, els, end *Block) {
+	b.push(&frame{kind: loopFrame, brk: end, cont: head})
+	b.cur = body
+	b.stmts(obj["body"])
+	b.link(b.cur, head, Next)
+	b.pop()
+	if els != end {
+		b.cur = els
+		b.stmts(obj["else"])
+		b.link(b.cur, end, Next)
+	}
+	b.cur = end
+}
+
+func (b *builder) forStmt(obj nodes.Object) {
+	// the iterable is evaluated once, and the target is assigned the next item in the
+	// head of the loop until there are no more
+	b.add(obj["iterable"])
+	head := b.newBlock("for.head")
+	b.link(b.cur, head, Next)
+	b.cur = head
+	b.add(obj["target"])
+	body := b.newBlock("for.body")
+	end := b.newBlock("for.end")
+	els := end
+	if obj["else"] != nil {
+		els = b.newBlock("for.else")
+	}
+	b.link(head, body, True)
+	b.link(head, els, False)
+	b.loop(obj, head, body, els, end)
+}
+
+func (b *builder) whileStmt(obj nodes.Object) {
+	head := b.newBlock("while.cond")
+	b.link(b.cur, head, Next)
+	body := b.newBlock("while.body")
+	end := b.newBlock("while.end")
+	els := end
+	if obj["else"] != nil {
+		els = b.newBlock("while.else")
+	}
+	b.cur = head
+	b.branch(obj["condition"], body, els)
+	b.loop(obj, head, body, els, end)
+}
+
+// tryStmt adds a try statement. The exceptions raised in the body go to the except
+// clauses, that test their types in order. The else clause runs after the body, and
+// the exceptions raised in it are not handled by the except clauses. The finally
+// clause runs after all of them, and after the jumps leaving them, that continue once
+// it completes: a return or a raise in it overrides them.
+func (b *builder) tryStmt(obj nodes.Object) {
+	end := b.newBlock("try.end")
+	done := end
+	var fin *frame
+	if obj["finally"] != nil {
+		fin = &frame{kind: finallyFrame, target: b.newBlock("try.finally")}
+		done = fin.target
+		b.push(fin)
+	}
+	handlers, _ := obj["handlers"].(nodes.Array)
+	var h *frame
+	if len(handlers) != 0 {
+		h = &frame{kind: handlerFrame, target: b.newBlock("try.except")}
+		b.push(h)
+	}
+	body := b.newBlock("try.body")
+	b.link(b.block(), body, Next)
+	b.cur = body
+	b.stmts(obj["body"])
+	if h != nil {
+		b.pop()
+	}
+	if obj["else"] != nil {
+		els := b.newBlock("try.else")
+		b.link(b.cur, els, Next)
+		b.cur = els
+		b.stmts(obj["else"])
+	}
+	b.link(b.cur, done, Next)
+	if h != nil {
+		b.handlers(h.target, handlers, done)
+	}
+	if fin != nil {
+		b.pop()
+		b.cur = fin.target
+		b.stmts(obj["finally"])
+		if b.fallsInto(fin.target) {
+			b.link(b.cur, end, Next)
+		}
+		b.leave(fin, b.cur)
+	}
+	b.cur = end
+}
+
+// fallsInto reports whether a block is reached by falling through from another one,
+// and not only by jumps.
+func (b *builder) fallsInto(blk *Block) bool {
+	for _, p := range b.g.Blocks {
+		for _, e := range p.Succs {
+			if e.To == blk && e.Kind == Next {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handlers adds the except clauses, starting at the block testing the first one. The
+// exception propagates if no clause matches.
+func (b *builder) handlers(test *Block, handlers nodes.Array, done *Block) {
+	for i, n := range handlers {
+		h, _ := n.(nodes.Object)
+		var body *Block
+		if typ := h["type"]; typ != nil {
+			body = b.newBlock("except.body")
+			b.cur = test
+			b.add(typ)
+			b.link(test, body, True)
+			if i == len(handlers)-1 {
+				test = b.newBlock("except.unmatched")
+			} else {
+				test = b.newBlock("except")
+			}
+			b.link(b.cur, test, False)
+		} else {
+			// a bare except matches all the exceptions, so it has no test
+			body, test = test, nil
+			body.Label = "except.body"
+		}
+		b.cur = body
+		b.stmts(h["body"])
+		b.link(b.cur, done, Next)
+	}
+	if test != nil {
+		b.cur = test
+		b.jump(Exception)
+	}
+}
+
+// withStmt adds a with statement. The context managers are exited after the body, and
+// after the jumps leaving it, that continue once they are exited. Exiting may suppress
+// the exceptions raised in the body, so the statements that follow run after them.
+func (b *builder) withStmt(obj nodes.Object) {
+	items, _ := obj["items"].(nodes.Array)
+	for _, it := range items {
+		b.add(it)
+	}
+	exit := b.newBlock("with.exit")
+	end := b.newBlock("with.end")
+	f := &frame{kind: finallyFrame, target: exit}
+	b.push(f)
+	body := b.newBlock("with.body")
+	b.link(b.cur, body, Next)
+	b.cur = body
+	b.stmts(obj["body"])
+	b.link(b.cur, exit, Next)
+	b.pop()
+	if b.fallsInto(exit) || hasKind(f.pending, Exception) {
+		b.link(exit, end, Next)
+	}
+	b.leave(f, exit)
+	b.cur = end
+}
+
+// matchStmt adds a match statement, that tests the patterns of the cases in order,
+// followed by their guards.
+func (b *builder) matchStmt(obj nodes.Object) {
+	b.add(obj["subject"])
+	end := b.newBlock("match.end")
+	cases, _ := obj["cases"].(nodes.Array)
+	for _, n := range cases {
+		c, _ := n.(nodes.Object)
+		body := b.newBlock("case.body")
+		next := b.newBlock("match.case")
+		b.add(c["pattern"])
+		b.link(b.cur, next, False)
+		if guard := c["guard"]; guard != nil {
+			g := b.newBlock("case.guard")
+			b.link(b.cur, g, True)
+			b.cur = g
+			b.branch(guard, body, next)
+		} else {
+			b.link(b.cur, body, True)
+		}
+		b.cur = body
+		stmts, _ := c["body"].(nodes.Object)
+		b.stmts(stmts["body_stmts"])
+		b.link(b.cur, end, Next)
+		b.cur = next
+	}
+	b.link(b.cur, end, Next)
+	b.cur = end
+}
+
+// assertStmt adds an assert statement, that raises if its condition is false.
+func (b *builder) assertStmt(obj nodes.Object) {
+	end := b.newBlock("assert.end")
+	fail := b.newBlock("assert.fail")
+	b.branch(obj["test"], end, fail)
+	b.cur = fail
+	if msg := obj["msg"]; msg != nil {
+		b.add(msg)
+	}
+	b.jump(Exception)
+	b.cur = end
+}
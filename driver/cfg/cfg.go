@@ -0,0 +1,300 @@
+// Package cfg builds the intra-procedural control flow graphs of the functions of a
+// Python file, from its semantic UAST as produced by normalizer.Transforms.
+package cfg
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// ErrNotFunction is returned when the node to build the graph of is not a function.
+var ErrNotFunction = errors.New("cfg: the node is not a function")
+
+// EdgeKind is the kind of transfer of control of an edge.
+type EdgeKind int
+
+const (
+	// Next is the fall through to the following block.
+	Next EdgeKind = iota
+	// True and False are the branches of a condition.
+	True
+	False
+	// Exception is the propagation of an exception, raised explicitly or by any
+	// statement in the body of a try or with statement.
+	Exception
+	Break
+	Continue
+	Return
+	// Yield goes from a yield to the statements running when the generator resumes.
+	Yield
+)
+
+var edgeKinds = [...]string{
+	Next:      "next",
+	True:      "true",
+	False:     "false",
+	Exception: "exception",
+	Break:     "break",
+	Continue:  "continue",
+	Return:    "return",
+	Yield:     "yield",
+}
+
+func (k EdgeKind) String() string {
+	if k < 0 || int(k) >= len(edgeKinds) {
+		return fmt.Sprintf("EdgeKind(%d)", int(k))
+	}
+	return edgeKinds[k]
+}
+
+// Edge is a transfer of control to a block.
+type Edge struct {
+	To   *Block
+	Kind EdgeKind
+}
+
+// Block is a basic block: a sequence of nodes that run one after the other.
+type Block struct {
+	// ID is the index of the block in the graph.
+	ID int
+	// Label names the part of the statement that created the block, like "if.then" or
+	// "for.head".
+	Label string
+	// Nodes are the simple statements of the block, and the expressions evaluated by
+	// the compound statements: the conditions, the iterables and targets of the for
+	// loops, the items of the with statements and the types of the except clauses.
+	Nodes []nodes.Node
+	Succs []Edge
+}
+
+// Lines returns the first and the last line of the nodes of the block, or zeros if
+// they have no positions.
+func (b *Block) Lines() (first, last uint32) {
+	for _, n := range b.Nodes {
+		nodes.WalkPreOrder(n, func(n nodes.Node) bool {
+			obj, ok := n.(nodes.Object)
+			if !ok {
+				return true
+			}
+			pos := uast.PositionsOf(obj)
+			for _, p := range []*uast.Position{pos.Start(), pos.End()} {
+				if p == nil || p.Line == 0 {
+					continue
+				}
+				if first == 0 || p.Line < first {
+					first = p.Line
+				}
+				if p.Line > last {
+					last = p.Line
+				}
+			}
+			return true
+		})
+	}
+	return first, last
+}
+
+func (b *Block) String() string {
+	s := fmt.Sprintf("%d: %s", b.ID, b.Label)
+	switch first, last := b.Lines(); {
+	case first == 0:
+	case first == last:
+		s += fmt.Sprintf(" (line %d)", first)
+	default:
+		s += fmt.Sprintf(" (lines %d-%d)", first, last)
+	}
+	return s
+}
+
+// Graph is the control flow graph of a function.
+type Graph struct {
+	// Name is the qualified name of the function, like the __qualname__ of Python.
+	Name string
+	// Func is the uast:Function node.
+	Func nodes.Object
+	// Blocks are sorted in reverse post-order from the entry, followed by the blocks
+	// of dead code. The entry is the first block and the exit the last one.
+	Blocks []*Block
+	// Entry has no nodes and no predecessors. Exit has no nodes and no successors: the
+	// function returns, or raises an exception that is not handled in it, by jumping
+	// to it.
+	Entry, Exit *Block
+}
+
+// Preds returns the predecessors of the block.
+func (g *Graph) Preds(b *Block) []*Block {
+	var out []*Block
+	for _, p := range g.Blocks {
+		for _, e := range p.Succs {
+			if e.To == b {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// New builds the graph of a uast:Function node, that is named as given.
+func New(name string, fn nodes.Node) (*Graph, error) {
+	obj, ok := fn.(nodes.Object)
+	if !ok || uast.TypeOf(obj) != uast.TypeOf(uast.Function{}) {
+		return nil, ErrNotFunction
+	}
+	g := &Graph{Name: name, Func: obj}
+	b := &builder{g: g}
+	g.Entry = b.newBlock("entry")
+	g.Exit = b.newBlock("exit")
+	b.cur = b.newBlock("body")
+	b.link(g.Entry, b.cur, Next)
+	b.stmts(obj["Body"])
+	// falling off the end returns None
+	b.link(b.cur, g.Exit, Next)
+	g.sort()
+	return g, nil
+}
+
+// Functions returns the graphs of all the functions in the semantic UAST, including the
+// methods, the nested functions and the lambdas. The graphs are named like the
+// __qualname__ of the functions: "Class.method", "func.<locals>.nested" or "<lambda>".
+func Functions(root nodes.Node) []*Graph {
+	var out []*Graph
+	var walk func(n nodes.Node, prefix string)
+	function := func(name string, fn nodes.Object, prefix string) {
+		qual := prefix + name
+		g, _ := New(qual, fn)
+		out = append(out, g)
+		// the default values are evaluated in the enclosing scope
+		walk(fn["Type"], prefix)
+		walk(fn["Body"], qual+".<locals>.")
+	}
+	walk = func(n nodes.Node, prefix string) {
+		switch n := n.(type) {
+		case nodes.Array:
+			for _, c := range n {
+				walk(c, prefix)
+			}
+		case nodes.Object:
+			switch uast.TypeOf(n) {
+			case uast.TypeOf(uast.FunctionGroup{}), uast.TypeOf(uast.Group{}):
+				// functions and classes bind their name to the function or the block
+				// of the class in an alias, after the decorators
+				list, _ := n["Nodes"].(nodes.Array)
+				for _, c := range list {
+					name, node := aliasOf(c)
+					switch uast.TypeOf(node) {
+					case uast.TypeOf(uast.Function{}):
+						function(name, node, prefix)
+					case uast.TypeOf(uast.Block{}):
+						walk(node, prefix+name+".")
+					default:
+						walk(c, prefix)
+					}
+				}
+			case uast.TypeOf(uast.Function{}):
+				function("<lambda>", n, prefix)
+			default:
+				for _, k := range n.Keys() {
+					walk(n[k], prefix)
+				}
+			}
+		}
+	}
+	walk(root, "")
+	return out
+}
+
+// aliasOf returns the name and the node of an alias.
+func aliasOf(n nodes.Node) (string, nodes.Object) {
+	obj, _ := n.(nodes.Object)
+	if uast.TypeOf(obj) != uast.TypeOf(uast.Alias{}) {
+		return "", nil
+	}
+	id, _ := obj["Name"].(nodes.Object)
+	name, _ := id["Name"].(nodes.String)
+	node, _ := obj["Node"].(nodes.Object)
+	return string(name), node
+}
+
+// sort orders the blocks in reverse post-order, drops the empty blocks that cannot be
+// reached, and numbers them.
+func (g *Graph) sort() {
+	seen := make(map[*Block]bool)
+	var post []*Block
+	var visit func(b *Block)
+	visit = func(b *Block) {
+		seen[b] = true
+		for _, e := range b.Succs {
+			if !seen[e.To] {
+				visit(e.To)
+			}
+		}
+		post = append(post, b)
+	}
+	seen[g.Exit] = true
+	visit(g.Entry)
+	blocks := make([]*Block, 0, len(g.Blocks))
+	for i := len(post) - 1; i >= 0; i-- {
+		blocks = append(blocks, post[i])
+	}
+	for _, b := range g.Blocks {
+		if !seen[b] && len(b.Nodes) != 0 {
+			blocks = append(blocks, b)
+		}
+	}
+	blocks = append(blocks, g.Exit)
+	dead := make(map[*Block]bool)
+	for _, b := range g.Blocks {
+		if !seen[b] && len(b.Nodes) == 0 {
+			dead[b] = true
+		}
+	}
+	for i, b := range blocks {
+		b.ID = i
+		// the dead code may jump to the empty blocks that are dropped
+		succs := b.Succs[:0]
+		for _, e := range b.Succs {
+			if !dead[e.To] {
+				succs = append(succs, e)
+			}
+		}
+		b.Succs = succs
+	}
+	g.Blocks = blocks
+}
+
+// WriteDOT writes the graph in the Graphviz format. The exception edges are drawn with
+// dashed lines, and the edges other than the fall through are labeled with their kind.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %q {\n", g.Name)
+	for _, b := range g.Blocks {
+		fmt.Fprintf(bw, "\t%d [label=%q];\n", b.ID, b.String())
+	}
+	for _, b := range g.Blocks {
+		for _, e := range b.Succs {
+			switch e.Kind {
+			case Next:
+				fmt.Fprintf(bw, "\t%d -> %d;\n", b.ID, e.To.ID)
+			case Exception:
+				fmt.Fprintf(bw, "\t%d -> %d [label=%q, style=dashed];\n", b.ID, e.To.ID, e.Kind.String())
+			default:
+				fmt.Fprintf(bw, "\t%d -> %d [label=%q];\n", b.ID, e.To.ID, e.Kind.String())
+			}
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// typeOf returns the type of a node, without the "python:" prefix of the Python types.
+func typeOf(n nodes.Node) string {
+	obj, _ := n.(nodes.Object)
+	return strings.TrimPrefix(uast.TypeOf(obj), "python:")
+}
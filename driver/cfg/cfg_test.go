@@ -0,0 +1,322 @@
+package cfg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/python-driver/driver/normalizer"
+	"github.com/bblfsh/python-driver/driver/parser"
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+	"github.com/stretchr/testify/require"
+)
+
+const fixturesDir = "../../fixtures"
+
+// TestBenchFixtures checks that the graphs of the functions of the benchmark fixtures
+// are well formed.
+func TestBenchFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(fixturesDir, "bench_*.sem.uast"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), ".sem.uast")
+		t.Run(name, func(t *testing.T) {
+			data, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			ast, err := uastyaml.Unmarshal(data)
+			require.NoError(t, err)
+			for _, g := range Functions(ast) {
+				require.NotEmpty(t, g.Name)
+				require.Equal(t, g.Entry, g.Blocks[0], g.Name)
+				require.Equal(t, g.Exit, g.Blocks[len(g.Blocks)-1], g.Name)
+				require.Empty(t, g.Preds(g.Entry), g.Name)
+				require.Empty(t, g.Exit.Succs, g.Name)
+				for i, b := range g.Blocks {
+					require.Equal(t, i, b.ID, g.Name)
+					for _, e := range b.Succs {
+						require.True(t, e.To.ID < len(g.Blocks) && g.Blocks[e.To.ID] == e.To,
+							"%s: edge to a block out of the graph: %v", g.Name, e.To)
+					}
+					if b != g.Entry && b != g.Exit && b.Label != "dead" && len(b.Nodes) == 0 {
+						require.NotEmpty(t, g.Preds(b), "%s: empty block with no predecessors: %v", g.Name, b)
+					}
+				}
+				var buf bytes.Buffer
+				require.NoError(t, g.WriteDOT(&buf))
+				require.True(t, strings.HasPrefix(buf.String(), fmt.Sprintf("digraph %q {\n", g.Name)))
+			}
+		})
+	}
+}
+
+func TestFunctionNames(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join(fixturesDir, "bench_accumulator_factory.py.sem.uast"))
+	require.NoError(t, err)
+	ast, err := uastyaml.Unmarshal(data)
+	require.NoError(t, err)
+	var names []string
+	for _, g := range Functions(ast) {
+		names = append(names, g.Name)
+	}
+	require.Equal(t, []string{"accumulator", "accumulator.<locals>.f"}, names)
+
+	_, err = New("x", ast)
+	require.Equal(t, ErrNotFunction, err)
+}
+
+// edges returns the edges of a graph as "from -kind-> to", with the labels of the
+// blocks prefixed by their index.
+func edges(g *Graph) []string {
+	var out []string
+	for _, b := range g.Blocks {
+		for _, e := range b.Succs {
+			out = append(out, fmt.Sprintf("%d.%s -%s-> %d.%s", b.ID, b.Label, e.Kind, e.To.ID, e.To.Label))
+		}
+	}
+	return out
+}
+
+func TestGraphs(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		src   string
+		edges []string
+	}{
+		{
+			name: "for_else_break",
+			src: `
+def f(xs):
+    for x in xs:
+        if x:
+            break
+    else:
+        g()
+    return x
+`,
+			edges: []string{
+				"0.entry -next-> 1.body",
+				"1.body -next-> 2.for.head",
+				"2.for.head -true-> 4.for.body",
+				"2.for.head -false-> 3.for.else",
+				"3.for.else -next-> 7.for.end",
+				"4.for.body -true-> 6.if.then",
+				"4.for.body -false-> 5.if.end",
+				"5.if.end -next-> 2.for.head",
+				"6.if.then -break-> 7.for.end",
+				"7.for.end -return-> 8.exit",
+			},
+		},
+		{
+			name: "while_continue",
+			src: `
+def f(n):
+    while n > 0:
+        n -= 1
+        if n % 2:
+            continue
+        g(n)
+`,
+			edges: []string{
+				"0.entry -next-> 1.body",
+				"1.body -next-> 2.while.cond",
+				"2.while.cond -true-> 4.while.body",
+				"2.while.cond -false-> 3.while.end",
+				"3.while.end -next-> 7.exit",
+				"4.while.body -true-> 6.if.then",
+				"4.while.body -false-> 5.if.end",
+				"5.if.end -next-> 2.while.cond",
+				"6.if.then -continue-> 2.while.cond",
+			},
+		},
+		{
+			name: "while_true",
+			src: `
+def f():
+    while True:
+        if g():
+            return 1
+`,
+			edges: []string{
+				"0.entry -next-> 1.body",
+				"1.body -next-> 2.while.cond",
+				"2.while.cond -true-> 3.while.body",
+				"3.while.body -true-> 5.if.then",
+				"3.while.body -false-> 4.if.end",
+				"4.if.end -next-> 2.while.cond",
+				"5.if.then -return-> 6.exit",
+			},
+		},
+		{
+			name: "try_except_else",
+			src: `
+def f():
+    try:
+        g()
+    except (KeyError, ValueError) as e:
+        h(e)
+    except:
+        raise
+    else:
+        i()
+`,
+			edges: []string{
+				"0.entry -next-> 1.body",
+				"1.body -next-> 2.try.body",
+				"2.try.body -exception-> 4.try.except",
+				"2.try.body -next-> 3.try.else",
+				"3.try.else -next-> 7.try.end",
+				"4.try.except -true-> 6.except.body",
+				"4.try.except -false-> 5.except.body",
+				"5.except.body -exception-> 8.exit",
+				"6.except.body -next-> 7.try.end",
+				"7.try.end -next-> 8.exit",
+			},
+		},
+		{
+			name: "try_finally_return",
+			src: `
+def f(xs):
+    for x in xs:
+        try:
+            if x:
+                break
+            return x
+        finally:
+            g()
+`,
+			edges: []string{
+				"0.entry -next-> 1.body",
+				"1.body -next-> 2.for.head",
+				"2.for.head -true-> 3.for.body",
+				"2.for.head -false-> 8.for.end",
+				"3.for.body -next-> 4.try.body",
+				"4.try.body -exception-> 7.try.finally",
+				"4.try.body -true-> 6.if.then",
+				"4.try.body -false-> 5.if.end",
+				"5.if.end -exception-> 7.try.finally",
+				"5.if.end -return-> 7.try.finally",
+				"6.if.then -exception-> 7.try.finally",
+				"6.if.then -break-> 7.try.finally",
+				"7.try.finally -exception-> 9.exit",
+				"7.try.finally -break-> 8.for.end",
+				"7.try.finally -return-> 9.exit",
+				"8.for.end -next-> 9.exit",
+			},
+		},
+		{
+			name: "return_in_finally",
+			src: `
+def f():
+    try:
+        raise ValueError()
+    finally:
+        return 1
+`,
+			edges: []string{
+				"0.entry -next-> 1.body",
+				"1.body -next-> 2.try.body",
+				"2.try.body -exception-> 3.try.finally",
+				"3.try.finally -return-> 4.exit",
+			},
+		},
+		{
+			name: "with_exit",
+			src: `
+def f(path):
+    with open(path) as fh:
+        if fh:
+            return fh.read()
+    g()
+`,
+			edges: []string{
+				"0.entry -next-> 1.body",
+				"1.body -next-> 2.with.body",
+				"2.with.body -exception-> 5.with.exit",
+				"2.with.body -true-> 4.if.then",
+				"2.with.body -false-> 3.if.end",
+				"3.if.end -next-> 5.with.exit",
+				"4.if.then -exception-> 5.with.exit",
+				"4.if.then -return-> 5.with.exit",
+				"5.with.exit -next-> 6.with.end",
+				"5.with.exit -exception-> 7.exit",
+				"5.with.exit -return-> 7.exit",
+				"6.with.end -next-> 7.exit",
+			},
+		},
+		{
+			name: "short_circuit",
+			src: `
+def f(a, b, c):
+    if a and (b or c):
+        g()
+`,
+			edges: []string{
+				"0.entry -next-> 1.body",
+				"1.body -true-> 2.cond",
+				"1.body -false-> 5.if.end",
+				"2.cond -true-> 4.if.then",
+				"2.cond -false-> 3.cond",
+				"3.cond -true-> 4.if.then",
+				"3.cond -false-> 5.if.end",
+				"4.if.then -next-> 5.if.end",
+				"5.if.end -next-> 6.exit",
+			},
+		},
+		{
+			name: "yield",
+			src: `
+def f(xs):
+    for x in xs:
+        y = yield x
+    yield from xs
+`,
+			edges: []string{
+				"0.entry -next-> 1.body",
+				"1.body -next-> 2.for.head",
+				"2.for.head -true-> 5.for.body",
+				"2.for.head -false-> 3.for.end",
+				"3.for.end -yield-> 4.yield.resume",
+				"4.yield.resume -next-> 7.exit",
+				"5.for.body -yield-> 6.yield.resume",
+				"6.yield.resume -next-> 2.for.head",
+			},
+		},
+		{
+			name: "assert_raise",
+			src: `
+def f(x):
+    assert x > 0, 'positive'
+    if x > 10:
+        raise ValueError(x)
+    return x
+`,
+			edges: []string{
+				"0.entry -next-> 1.body",
+				"1.body -true-> 3.assert.end",
+				"1.body -false-> 2.assert.fail",
+				"2.assert.fail -exception-> 6.exit",
+				"3.assert.end -true-> 5.if.then",
+				"3.assert.end -false-> 4.if.end",
+				"4.if.end -return-> 6.exit",
+				"5.if.then -exception-> 6.exit",
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ast, err := parser.Parse(c.src)
+			require.NoError(t, err)
+			ast, err = normalizer.Transforms.Do(context.Background(), driver.ModeSemantic, c.src, ast)
+			require.NoError(t, err)
+			graphs := Functions(ast)
+			require.Len(t, graphs, 1)
+			require.Equal(t, c.edges, edges(graphs[0]))
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Command importgraph prints the import graph of the Python files in a directory, as
+// JSON or in the Graphviz format.
+//
+// Usage:
+//
+//	importgraph [-format json|dot] [-external] [-cycles] [dir]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bblfsh/python-driver/driver/imports"
+	"github.com/bblfsh/python-driver/driver/normalizer"
+	"github.com/bblfsh/python-driver/driver/parser"
+	"github.com/bblfsh/sdk/v3/driver"
+)
+
+var (
+	fFormat   = flag.String("format", "json", "output format: json or dot")
+	fExternal = flag.Bool("external", false, "include the modules outside the directory")
+	fCycles   = flag.Bool("cycles", false, "print the import cycles instead of the graph")
+)
+
+func main() {
+	flag.Parse()
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	if err := run(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string) error {
+	switch *fFormat {
+	case "json", "dot":
+	default:
+		return fmt.Errorf("unknown format: %q", *fFormat)
+	}
+	files := make(map[imports.File][]imports.Import)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if path != dir && strings.HasPrefix(fi.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".py" {
+			return nil
+		}
+		f, imps, err := fileImports(path)
+		if err != nil {
+			// the files that cannot be parsed are skipped
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return nil
+		}
+		for _, imp := range imps {
+			if !imp.Resolved {
+				line := uint32(0)
+				if start := imp.Positions.Start(); start != nil {
+					line = start.Line
+				}
+				fmt.Fprintf(os.Stderr, "%s:%d: relative import beyond the top-level package: %s\n", path, line, imp.Module)
+			}
+		}
+		files[f] = imps
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	g := imports.NewGraph(files, *fExternal)
+	if *fCycles {
+		for _, c := range g.Cycles() {
+			fmt.Println(strings.Join(c, " "))
+		}
+		return nil
+	}
+	if *fFormat == "dot" {
+		return g.WriteDOT(os.Stdout)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// fileImports parses the file and returns its imports.
+func fileImports(path string) (imports.File, []imports.Import, error) {
+	f, err := imports.NewFile(path)
+	if err != nil {
+		return f, nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return f, nil, err
+	}
+	src := string(data)
+	ast, err := parser.Parse(src)
+	if err != nil {
+		return f, nil, err
+	}
+	ast, err = normalizer.Transforms.Do(context.Background(), driver.ModeSemantic, src, ast)
+	if err != nil {
+		return f, nil, err
+	}
+	return f, f.Imports(ast), nil
+}
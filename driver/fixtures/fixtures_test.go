@@ -5,9 +5,9 @@ import (
 	"testing"
 
 	"github.com/bblfsh/python-driver/driver/normalizer"
+	"github.com/bblfsh/python-driver/driver/parser"
 	"github.com/bblfsh/sdk/v3/driver"
 	"github.com/bblfsh/sdk/v3/driver/fixtures"
-	"github.com/bblfsh/sdk/v3/driver/native"
 )
 
 const projectRoot = "../../"
@@ -17,7 +17,7 @@ var Suite = &fixtures.Suite{
 	Ext:  ".py",
 	Path: filepath.Join(projectRoot, fixtures.Dir),
 	NewDriver: func() driver.Native {
-		return native.NewDriverAt(filepath.Join(projectRoot, "build/bin/native"), native.UTF8)
+		return parser.NewDriver()
 	},
 	Transforms: normalizer.Transforms,
 	BenchName:  "issue_server101",
@@ -27,6 +27,7 @@ var Suite = &fixtures.Suite{
 			"Attribute",
 			"BoolLiteral",
 			"Bytes",
+			"ClassDef",
 			"FunctionDef",
 			"Import",
 			"ImportFrom",
@@ -0,0 +1,75 @@
+package fixtures
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bblfsh/python-driver/driver/impl"
+	"github.com/bblfsh/python-driver/driver/parser"
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/driver/manifest"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+	"github.com/stretchr/testify/require"
+)
+
+// syntaxErrorFixture is the fixture with a syntax error, that the suite only checks
+// to fail.
+const syntaxErrorFixture = "_syntax_error.py"
+
+// TestPartialParse checks that the driver module, created with driver.NewDriverFrom,
+// transforms the partial AST returned with the syntax error of the fixture like the
+// other ones, and that the positions of the errors are in it. The fixtures are
+// generated if they don't exist, and the UAST that doesn't match them is written next
+// to them.
+func TestPartialParse(t *testing.T) {
+	path := filepath.Join(Suite.Path, syntaxErrorFixture)
+	code, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	m, err := manifest.Load(filepath.Join(projectRoot, manifest.Filename))
+	require.NoError(t, err)
+	d, err := impl.NewDriver(m, Suite.Transforms, parser.Auto)
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		mode driver.Mode
+		ext  string
+	}{
+		{driver.ModeAnnotated, ".uast"},
+		{driver.ModeSemantic, ".sem.uast"},
+	} {
+		t.Run(c.ext, func(t *testing.T) {
+			opts := &driver.ParseOptions{Mode: c.mode}
+			ua, err := d.Parse(context.Background(), string(code), opts)
+			require.True(t, driver.ErrSyntax.Is(err), "%v", err)
+			require.Equal(t, driver.ParseOptions{Mode: c.mode}, *opts)
+
+			errs, _ := ua.(nodes.Object)["syntax_errors"].(nodes.Array)
+			require.Len(t, errs, 1)
+			pos := uast.PositionsOf(errs[0].(nodes.Object))
+			require.Equal(t, uast.Position{Offset: 24, Line: 2, Col: 5}, *pos.Start())
+			require.Equal(t, uast.Position{Offset: 25, Line: 2, Col: 6}, *pos.End())
+
+			got, err := uastyaml.Marshal(ua)
+			require.NoError(t, err)
+
+			exp, err := ioutil.ReadFile(path + c.ext)
+			if os.IsNotExist(err) {
+				require.NoError(t, ioutil.WriteFile(path+c.ext, got, 0666))
+				t.Skip("no test file found - generating")
+			}
+			require.NoError(t, err)
+			if string(exp) != string(got) {
+				require.NoError(t, ioutil.WriteFile(path+c.ext+"_got", got, 0666))
+				require.Fail(t, "unexpected UAST for the partial AST",
+					"run diff command to debug:\ndiff -d %s %s", path+c.ext+"_got", path+c.ext)
+			}
+			os.Remove(path + c.ext + "_got")
+		})
+	}
+}
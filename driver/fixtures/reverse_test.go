@@ -0,0 +1,72 @@
+package fixtures
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/python-driver/driver/normalizer"
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReverseNormalize checks that the semantic UAST of every fixture converts back
+// to its native AST with ReverseNormalize. The steps of Normalize in OneWay discard some
+// information, so the native AST is compared after running them.
+func TestReverseNormalize(t *testing.T) {
+	tr := normalizer.Transforms
+
+	files, err := filepath.Glob(filepath.Join(Suite.Path, "*"+Suite.Ext))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, path := range files {
+		path := path
+		name := strings.TrimSuffix(filepath.Base(path), Suite.Ext)
+		if strings.HasPrefix(name, "_") {
+			// syntax errors and other special cases
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			code, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+			data, err := ioutil.ReadFile(path + ".native")
+			require.NoError(t, err)
+			ast, err := uastyaml.Unmarshal(data)
+			require.NoError(t, err)
+
+			// the normalizers work on the preprocessed AST
+			pre := driver.Transforms{
+				Preprocess:     tr.Preprocess,
+				PreprocessCode: tr.PreprocessCode,
+			}
+			exp, err := pre.Do(context.Background(), driver.ModeAnnotated, string(code), ast)
+			require.NoError(t, err)
+			for _, n := range normalizer.OneWay {
+				exp, err = n.Do(exp)
+				require.NoError(t, err)
+			}
+
+			sem, err := pre.Do(context.Background(), driver.ModeAnnotated, string(code), ast)
+			require.NoError(t, err)
+			for _, n := range tr.Normalize {
+				sem, err = n.Do(sem)
+				require.NoError(t, err)
+			}
+
+			got, err := normalizer.ReverseNormalize(sem)
+			require.NoError(t, err)
+			if !nodes.Equal(exp, got) {
+				expData, err := uastyaml.Marshal(exp)
+				require.NoError(t, err)
+				gotData, err := uastyaml.Marshal(got)
+				require.NoError(t, err)
+				require.Equal(t, string(expData), string(gotData))
+			}
+		})
+	}
+}
@@ -1,11 +1,95 @@
 package impl
 
 import (
-	"github.com/bblfsh/sdk/v3/driver/native"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/bblfsh/python-driver/driver/normalizer"
+	"github.com/bblfsh/python-driver/driver/parser"
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/driver/manifest"
 	"github.com/bblfsh/sdk/v3/driver/server"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
 )
 
 func init() {
-	// Can be overridden to link a native driver into a Go driver server.
-	server.DefaultDriver = native.NewDriver(native.UTF8)
+	// The native driver is linked into the Go driver server, so the Python
+	// process is not required.
+	server.DefaultDriver = serverDriver{parser.NewDriver()}
+}
+
+// serverDriver is the native driver run by server.Run, that is called by the main
+// package managed by the SDK. server.Run wraps it with driver.NewDriverFrom, that
+// returns the partial ASTs untransformed, so starting it serves Driver instead, with
+// the same flags. The server of server.Run never gets to listen.
+type serverDriver struct {
+	*parser.Driver
+}
+
+// VersionEnv is the environment variable with the default version of the grammar of
+// the driver server, as accepted by parser.LookupVersion. It's Auto if it's not set.
+const VersionEnv = "PYTHON_DRIVER_VERSION"
+
+// Start implements driver.Module. It blocks until the server stops.
+func (serverDriver) Start() error {
+	v, ok := parser.LookupVersion(os.Getenv(VersionEnv))
+	if !ok {
+		return fmt.Errorf("unknown Python version in %s: %q", VersionEnv, os.Getenv(VersionEnv))
+	}
+	m, err := manifest.Load(server.ManifestLocation)
+	if err != nil {
+		return err
+	}
+	d, err := NewDriver(m, normalizer.Transforms, v)
+	if err != nil {
+		return err
+	}
+	return server.NewServer(d).Start()
+}
+
+// Driver is the driver module with the native driver linked in. The SDK returns the
+// AST of the code with syntax errors as is, so Driver transforms the partial AST
+// returned with them, like the ASTs of the code that parses.
+//
+// The language of the requests selects the version of the grammar, like "python2"
+// or "python3", and has precedence over the parser.MetadataKey of the gRPC metadata.
+type Driver struct {
+	driver.DriverModule
+	t driver.Transforms
+}
+
+// NewDriver creates the driver module with the manifest and the transforms. The
+// requests that don't select a version of the grammar are parsed with v.
+func NewDriver(m *manifest.Manifest, t driver.Transforms, v parser.Version) (*Driver, error) {
+	d, err := driver.NewDriverFrom(&parser.Driver{Version: v}, m, t)
+	if err != nil {
+		return nil, err
+	}
+	return &Driver{DriverModule: d, t: t}, nil
+}
+
+// Parse implements driver.Driver. The syntax errors are returned with the transformed
+// partial AST, and the errors of the transformation replace them. The options of the
+// partial ASTs are not modified.
+func (d *Driver) Parse(ctx context.Context, src string, opts *driver.ParseOptions) (nodes.Node, error) {
+	if opts != nil {
+		// the name of the language alone is Auto, that doesn't override the metadata
+		if v, ok := parser.LookupVersion(opts.Language); ok && v != parser.Auto {
+			ctx = parser.WithVersion(ctx, v)
+		}
+	}
+	ast, err := d.DriverModule.Parse(ctx, src, opts)
+	if ast == nil || !driver.ErrSyntax.Is(err) {
+		return ast, err
+	}
+	var o driver.ParseOptions
+	if opts != nil {
+		o = *opts
+	}
+	ua, terr := d.t.Do(ctx, o.Mode, src, ast)
+	if terr != nil {
+		return nil, driver.ErrTransformFailure.Wrap(terr)
+	}
+	return ua, err
 }
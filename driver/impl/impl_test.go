@@ -0,0 +1,104 @@
+package impl
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/bblfsh/python-driver/driver/normalizer"
+	"github.com/bblfsh/python-driver/driver/parser"
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/driver/manifest"
+	"github.com/bblfsh/sdk/v3/driver/server"
+	"github.com/bblfsh/sdk/v3/protocol"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// newClient starts a gRPC server of the driver with the default version and returns a
+// client of it.
+func newClient(t *testing.T, v parser.Version) driver.Driver {
+	m, err := manifest.Load(filepath.Join("..", "..", manifest.Filename))
+	require.NoError(t, err)
+	d, err := NewDriver(m, normalizer.Transforms, v)
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	srv := server.NewGRPCServer(d)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return protocol.AsDriver(conn)
+}
+
+// TestServerPartialParse checks that the gRPC server of the driver returns the partial
+// AST of the code with syntax errors transformed in the mode of the request.
+func TestServerPartialParse(t *testing.T) {
+	_, ok := server.DefaultDriver.(serverDriver)
+	require.True(t, ok, "the driver server doesn't serve Driver")
+	cli := newClient(t, parser.Auto)
+
+	const src = "def f(a):\n    = a\n    return a\n"
+	for _, c := range []struct {
+		name string
+		mode driver.Mode
+		typ  string
+	}{
+		{"native", driver.ModeNative, "Module"},
+		{"annotated", driver.ModeAnnotated, "Module"},
+		{"semantic", driver.ModeSemantic, "python:Module"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ast, err := cli.Parse(context.Background(), src, &driver.ParseOptions{Mode: c.mode})
+			require.True(t, driver.ErrSyntax.Is(err), "%v", err)
+			obj, ok := ast.(nodes.Object)
+			require.True(t, ok, "%v", ast)
+			typ := uast.TypeOf(obj)
+			if c.mode == driver.ModeNative {
+				obj, _ = obj["PY3AST"].(nodes.Object)
+				typ = string(obj["ast_type"].(nodes.String))
+			}
+			require.Equal(t, c.typ, typ)
+			require.NotNil(t, obj["syntax_errors"])
+		})
+	}
+}
+
+// TestServerVersion checks that the language of the requests selects the version of
+// the grammar without the gRPC metadata, and that the other requests use the default
+// version of the driver.
+func TestServerVersion(t *testing.T) {
+	const src = "print 'x'\n"
+	for _, c := range []struct {
+		name     string
+		version  parser.Version
+		language string
+		root     string
+	}{
+		{"python2", parser.Python3, "python2", "PY2AST"},
+		{"python3", parser.Auto, "python3", ""},
+		{"default", parser.Python2, "", "PY2AST"},
+		{"language", parser.Python2, "python", "PY2AST"},
+		{"auto", parser.Auto, "", "PY2AST"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cli := newClient(t, c.version)
+			opts := &driver.ParseOptions{Mode: driver.ModeNative, Language: c.language}
+			ast, err := cli.Parse(context.Background(), src, opts)
+			if c.root == "" {
+				require.True(t, driver.ErrSyntax.Is(err), "%v", err)
+				return
+			}
+			require.NoError(t, err)
+			obj, _ := ast.(nodes.Object)
+			require.Contains(t, obj, c.root)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package imports
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Graph is the import graph of a set of files, with an edge from each module to the
+// modules it imports.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Node is a module of the graph.
+type Node struct {
+	Module string `json:"module"`
+	// Path is the file of the module, empty for external modules.
+	Path string `json:"path,omitempty"`
+	// External is set for the modules that are not in the set of files. They are named
+	// by their top-level package.
+	External bool `json:"external,omitempty"`
+}
+
+// Edge is an import of a module by another.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// NewGraph returns the import graph of the files, given their imports. The imported
+// names of "from" imports are modules if there is a file for them, and the imports
+// of modules that have no file are edges to the closest package that has one. The
+// imports of modules outside the files are only kept if external is set, and the
+// relative imports that cannot be resolved are dropped.
+func NewGraph(files map[File][]Import, external bool) *Graph {
+	g := &Graph{}
+	internal := make(map[string]bool, len(files))
+	for f := range files {
+		internal[f.Module] = true
+		g.Nodes = append(g.Nodes, Node{Module: f.Module, Path: f.Path})
+	}
+	// target returns the module of the files or the external package imported
+	target := func(name string) (string, bool) {
+		for mod := name; mod != ""; {
+			if internal[mod] {
+				return mod, true
+			}
+			i := strings.LastIndexByte(mod, '.')
+			if i < 0 {
+				break
+			}
+			mod = mod[:i]
+		}
+		return strings.SplitN(name, ".", 2)[0], false
+	}
+
+	edges := make(map[Edge]bool)
+	externals := make(map[string]bool)
+	for f, imps := range files {
+		for _, imp := range imps {
+			if !imp.Resolved {
+				continue
+			}
+			targets := []string{imp.Module}
+			if len(imp.Names) != 0 {
+				targets = targets[:0]
+				for _, name := range imp.Names {
+					if sub := joinName(imp.Module, name); internal[sub] {
+						targets = append(targets, sub)
+					} else {
+						targets = append(targets, imp.Module)
+					}
+				}
+			}
+			for _, name := range targets {
+				to, ok := target(name)
+				if !ok && (!external || to == "") {
+					continue
+				}
+				if !ok {
+					externals[to] = true
+				}
+				if to != f.Module {
+					edges[Edge{From: f.Module, To: to}] = true
+				}
+			}
+		}
+	}
+	for mod := range externals {
+		g.Nodes = append(g.Nodes, Node{Module: mod, External: true})
+	}
+	for e := range edges {
+		g.Edges = append(g.Edges, e)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].Module < g.Nodes[j].Module
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	return g
+}
+
+func joinName(mod, name string) string {
+	if mod == "" {
+		return name
+	}
+	return mod + "." + name
+}
+
+// Cycles returns the import cycles of the graph: the sets of modules that import each
+// other directly or indirectly, sorted by name.
+func (g *Graph) Cycles() [][]string {
+	adj := make(map[string][]string)
+	for _, e := range g.Edges {
+		adj[e.From] = append(adj[e.From], e.To)
+	}
+	// Tarjan's strongly connected components
+	var (
+		index   = make(map[string]int)
+		low     = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		out     [][]string
+	)
+	var visit func(v string)
+	visit = func(v string) {
+		index[v] = len(index)
+		low[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range adj[v] {
+			if _, ok := index[w]; !ok {
+				visit(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
+				}
+			} else if onStack[w] && index[w] < low[v] {
+				low[v] = index[w]
+			}
+		}
+		if low[v] != index[v] {
+			return
+		}
+		var comp []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			comp = append(comp, w)
+			if w == v {
+				break
+			}
+		}
+		if len(comp) > 1 {
+			sort.Strings(comp)
+			out = append(out, comp)
+		}
+	}
+	for _, n := range g.Nodes {
+		if _, ok := index[n.Module]; !ok {
+			visit(n.Module)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i][0] < out[j][0]
+	})
+	return out
+}
+
+// WriteDOT writes the graph in the Graphviz format. External modules are drawn with
+// dashed lines.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph imports {")
+	for _, n := range g.Nodes {
+		if n.External {
+			fmt.Fprintf(bw, "\t%q [style=dashed];\n", n.Module)
+		} else {
+			fmt.Fprintf(bw, "\t%q;\n", n.Module)
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "\t%q -> %q;\n", e.From, e.To)
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
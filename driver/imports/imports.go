@@ -0,0 +1,155 @@
+// Package imports resolves the imports of Python files to absolute module names and
+// builds the import graph of a source tree.
+package imports
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// File is a Python file in a package tree.
+type File struct {
+	Path string
+	// Module is the absolute name of the module of the file.
+	Module string
+	// Package is set for the __init__.py files, that are the module of their package.
+	Package bool
+}
+
+// NewFile returns the file at the path, with the module name given by the packages
+// containing it: the directories with an __init__.py file. Namespace packages (PEP 420)
+// are not detected, so the files in them are top-level modules.
+func NewFile(path string) (File, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return File{}, err
+	}
+	f := File{Path: path}
+	name := strings.TrimSuffix(filepath.Base(path), ".py")
+	var parts []string
+	if name == "__init__" {
+		f.Package = true
+	} else {
+		parts = append(parts, name)
+	}
+	for dir := filepath.Dir(path); ; {
+		if _, err := os.Stat(filepath.Join(dir, "__init__.py")); err != nil {
+			break
+		}
+		parts = append(parts, filepath.Base(dir))
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	f.Module = strings.Join(parts, ".")
+	return f, nil
+}
+
+// Import is an import statement, or one of the paths of an import statement with
+// multiple ones.
+type Import struct {
+	// Module is the absolute name of the imported module. For relative imports that
+	// cannot be resolved, it's the relative name, starting with a dot for each level.
+	Module string
+	// Names are the names imported from the module by "from" imports.
+	Names []string
+	// Star is set for "from module import *".
+	Star bool
+	// Level is the number of leading dots of relative imports.
+	Level int
+	// Resolved is unset for relative imports going above the top-level package.
+	Resolved  bool
+	Positions uast.Positions
+}
+
+// Imports returns the imports of the file in its semantic UAST, as produced by
+// normalizer.Transforms, including the ones in functions and classes.
+func (f File) Imports(root nodes.Node) []Import {
+	var out []Import
+	nodes.WalkPreOrder(root, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok || uast.TypeOf(obj) != uast.TypeOf(uast.RuntimeImport{}) {
+			return true
+		}
+		out = append(out, f.resolve(obj))
+		return false
+	})
+	return out
+}
+
+func (f File) resolve(obj nodes.Object) Import {
+	imp := Import{Positions: uast.PositionsOf(obj), Resolved: true}
+	imp.Star = obj["All"] == nodes.Bool(true)
+	path := obj["Path"]
+	names, _ := obj["Names"].(nodes.Array)
+	for _, name := range names {
+		name, _ := name.(nodes.Object)
+		if uast.TypeOf(name) == uast.TypeOf(uast.Alias{}) {
+			name, _ = name["Node"].(nodes.Object)
+		}
+		imp.Names = append(imp.Names, strings.Join(identNames(name), "."))
+	}
+	if alias, ok := path.(nodes.Object); ok && uast.TypeOf(alias) == uast.TypeOf(uast.Alias{}) {
+		// import a.b as c
+		path = alias["Node"]
+	}
+	parts := identNames(path)
+	for len(parts) != 0 && parts[0] == ".." {
+		imp.Level++
+		parts = parts[1:]
+	}
+	if len(parts) == 1 && parts[0] == "." {
+		parts = nil
+	}
+	rel := strings.Join(parts, ".")
+	if imp.Level == 0 {
+		imp.Module = rel
+		return imp
+	}
+	imp.Module, imp.Resolved = f.absolute(imp.Level, rel)
+	return imp
+}
+
+// absolute returns the absolute name of a module imported relatively from the file.
+func (f File) absolute(level int, rel string) (string, bool) {
+	pkg := strings.Split(f.Module, ".")
+	if !f.Package {
+		// the package of a module is its parent
+		pkg = pkg[:len(pkg)-1]
+	}
+	if f.Module == "" || level-1 >= len(pkg) {
+		return strings.Repeat(".", level) + rel, false
+	}
+	pkg = pkg[:len(pkg)-(level-1)]
+	if rel != "" {
+		pkg = append(pkg, rel)
+	}
+	return strings.Join(pkg, "."), true
+}
+
+// identNames returns the names of an identifier or a qualified identifier.
+func identNames(n nodes.Node) []string {
+	obj, _ := n.(nodes.Object)
+	switch uast.TypeOf(obj) {
+	case uast.TypeOf(uast.Identifier{}):
+		name, _ := obj["Name"].(nodes.String)
+		return []string{string(name)}
+	case uast.TypeOf(uast.QualifiedIdentifier{}):
+		ids, _ := obj["Names"].(nodes.Array)
+		var out []string
+		for _, id := range ids {
+			out = append(out, identNames(id)...)
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package imports
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bblfsh/python-driver/driver/normalizer"
+	"github.com/bblfsh/python-driver/driver/parser"
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/stretchr/testify/require"
+)
+
+// tree is a package tree with import cycles between the modules of app, and a relative
+// import beyond the top-level package.
+var tree = map[string]string{
+	"setup.py":                  "import setuptools\n",
+	"app/__init__.py":           "from . import models\nfrom .views import *\n",
+	"app/models.py":             "import os.path\nfrom .views import render as r\n",
+	"app/views.py":              "from . import models, util\nfrom .. import outside\n",
+	"app/util/__init__.py":      "from .helpers import helper\n",
+	"app/util/helpers.py":       "from ..models import Model\nimport app.util\n",
+	"app/util/deep/mod.py":      "from ... import views\n",
+	"app/util/deep/__init__.py": "",
+}
+
+func writeTree(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "imports")
+	require.NoError(t, err)
+	for name, src := range tree {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(src), 0644))
+	}
+	return dir
+}
+
+func readImports(t *testing.T, dir string) map[File][]Import {
+	files := make(map[File][]Import)
+	for name := range tree {
+		f, err := NewFile(filepath.Join(dir, filepath.FromSlash(name)))
+		require.NoError(t, err)
+		src := tree[name]
+		ast, err := parser.Parse(src)
+		require.NoError(t, err)
+		ast, err = normalizer.Transforms.Do(context.Background(), driver.ModeSemantic, src, ast)
+		require.NoError(t, err)
+		files[f] = f.Imports(ast)
+	}
+	return files
+}
+
+type summary struct {
+	Module   string
+	Names    []string
+	Star     bool
+	Level    int
+	Resolved bool
+}
+
+func TestImports(t *testing.T) {
+	dir := writeTree(t)
+	defer os.RemoveAll(dir)
+
+	byModule := make(map[string][]summary)
+	for f, imps := range readImports(t, dir) {
+		var list []summary
+		for _, imp := range imps {
+			require.NotNil(t, imp.Positions.Start())
+			list = append(list, summary{
+				Module: imp.Module, Names: imp.Names, Star: imp.Star,
+				Level: imp.Level, Resolved: imp.Resolved,
+			})
+		}
+		byModule[f.Module] = list
+	}
+	require.Equal(t, map[string][]summary{
+		"setup": {
+			{Module: "setuptools", Resolved: true},
+		},
+		"app": {
+			{Module: "app", Names: []string{"models"}, Level: 1, Resolved: true},
+			{Module: "app.views", Star: true, Level: 1, Resolved: true},
+		},
+		"app.models": {
+			{Module: "os.path", Resolved: true},
+			{Module: "app.views", Names: []string{"render"}, Level: 1, Resolved: true},
+		},
+		"app.views": {
+			{Module: "app", Names: []string{"models", "util"}, Level: 1, Resolved: true},
+			{Module: "..", Names: []string{"outside"}, Level: 2},
+		},
+		"app.util": {
+			{Module: "app.util.helpers", Names: []string{"helper"}, Level: 1, Resolved: true},
+		},
+		"app.util.helpers": {
+			{Module: "app.models", Names: []string{"Model"}, Level: 2, Resolved: true},
+			{Module: "app.util", Resolved: true},
+		},
+		"app.util.deep.mod": {
+			{Module: "app", Names: []string{"views"}, Level: 3, Resolved: true},
+		},
+		"app.util.deep": nil,
+	}, byModule)
+}
+
+func TestGraph(t *testing.T) {
+	dir := writeTree(t)
+	defer os.RemoveAll(dir)
+
+	g := NewGraph(readImports(t, dir), false)
+	var edges [][2]string
+	for _, e := range g.Edges {
+		edges = append(edges, [2]string{e.From, e.To})
+	}
+	require.Equal(t, [][2]string{
+		{"app", "app.models"},
+		{"app", "app.views"},
+		{"app.models", "app.views"},
+		{"app.util", "app.util.helpers"},
+		{"app.util.deep.mod", "app.views"},
+		{"app.util.helpers", "app.models"},
+		{"app.util.helpers", "app.util"},
+		{"app.views", "app.models"},
+		{"app.views", "app.util"},
+	}, edges)
+	require.Equal(t, [][]string{
+		{"app.models", "app.util", "app.util.helpers", "app.views"},
+	}, g.Cycles())
+
+	g = NewGraph(readImports(t, dir), true)
+	var externals []string
+	for _, n := range g.Nodes {
+		if n.External {
+			require.Empty(t, n.Path)
+			externals = append(externals, n.Module)
+		} else {
+			require.NotEmpty(t, n.Path)
+		}
+	}
+	require.Equal(t, []string{"os", "setuptools"}, externals)
+
+	var buf bytes.Buffer
+	require.NoError(t, g.WriteDOT(&buf))
+	require.Contains(t, buf.String(), "\t\"os\" [style=dashed];\n")
+	require.Contains(t, buf.String(), "\t\"app.models\" -> \"os\";\n")
+}
@@ -2,6 +2,7 @@ package normalizer
 
 import (
 	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
 	"github.com/bblfsh/sdk/v3/uast/role"
 	. "github.com/bblfsh/sdk/v3/uast/transformer"
 )
@@ -48,7 +49,8 @@ func functionAnnotate(typ string, roles ...role.Role) Mapping {
 
 func withAnnotate(typ string, roles ...role.Role) Mapping {
 	return AnnotateType(typ, MapObj(Obj{
-		"body":  Var("body_stmts"),
+		// the body is a block in the semantic mode
+		"body":  Check(OfKind(nodes.KindArray), Var("body_stmts")),
 		"items": Var("itms"),
 	}, Obj{
 		"body": Obj{
@@ -86,6 +88,8 @@ func loopAnnotate(typ string, mainRole role.Role, roles ...role.Role) Mapping {
 var Annotations = []Mapping{
 	// FIXME: doesnt work
 	AnnotateType("Module", nil, role.File, role.Module),
+	// the syntax errors of the statements left out of a partial AST
+	AnnotateType("SyntaxError", nil, role.Incomplete),
 
 	// Comparison operators
 	// in Python, with internaltype)
@@ -136,6 +140,9 @@ var Annotations = []Mapping{
 	AnnotateType("Set", nil, role.Literal, role.Set, role.Expression, role.Primitive),
 	AnnotateType("List", nil, role.Literal, role.List, role.Expression, role.Primitive),
 	AnnotateType("Tuple", nil, role.Literal, role.Tuple, role.Expression, role.Primitive),
+	// the spread arguments of calls and the targets taking the rest of the values in
+	// destructuring assignments
+	AnnotateType("Starred", nil, role.Expression, role.ArgsList, role.List),
 
 	// Expressions
 	AnnotateType("Expression", nil, role.Expression),
@@ -210,18 +217,28 @@ var Annotations = []Mapping{
 	//		targets[] => Left
 	//		value	  => Right
 	//
+	// In the semantic mode all the assignments are Assign nodes, with the operator of
+	// the augmented ones and the type of the annotated ones, that may have no value.
 	AnnotateType("Assign", FieldRoles{
-		"targets": {Arr: true, Roles: role.Roles{role.Left}},
-		"value":   {Roles: role.Roles{role.Right}},
+		"targets":    {Arr: true, Roles: role.Roles{role.Left}},
+		"value":      {Opt: true, Roles: role.Roles{role.Right}},
+		"operator":   {Opt: true, Roles: role.Roles{role.Operator}},
+		"annotation": {Opt: true, Roles: role.Roles{role.Annotation, role.Type}},
 	}, role.Binary, role.Expression, role.Assignment),
 
 	AnnotateType("AugAssign", ObjRoles{
 		"op":     {role.Operator},
-		"target": {role.Right},
-		"value":  {role.Left},
+		"target": {role.Left},
+		"value":  {role.Right},
 	}, role.Binary, role.Expression, role.Operator, role.Assignment,
 	),
 
+	// Assignment expressions (Python 3.8)
+	AnnotateType("NamedExpr", FieldRoles{
+		"target": {Roles: role.Roles{role.Left}},
+		"value":  {Roles: role.Roles{role.Right}},
+	}, role.Binary, role.Expression, role.Assignment),
+
 	// Exceptions
 	// Adds a parent node for each these properties with direct list values
 	AnnotateType("Try", MapObj(Obj{
@@ -256,8 +273,11 @@ var Annotations = []Mapping{
 
 	// python 2 exception handling
 	AnnotateType("TryExcept", nil, role.Try, role.Catch, role.Statement),
-	AnnotateType("ExceptHandler", FieldRoles{"name": {Rename: uast.KeyToken}},
-		role.Try, role.Catch, role.Identifier),
+	AnnotateType("ExceptHandler", FieldRoles{
+		"name": {Rename: uast.KeyToken},
+		// the body is a block in the semantic mode
+		"body": {Op: Check(OfKind(nodes.KindArray), Var("body_stmts"))},
+	}, role.Try, role.Catch, role.Identifier),
 	AnnotateType("TryFinally", nil, role.Try, role.Finally, role.Statement),
 	AnnotateType("Raise", nil, role.Throw),
 
@@ -270,6 +290,20 @@ var Annotations = []Mapping{
 	// With
 	withAnnotate("With"),
 	withAnnotate("AsyncWith"),
+	// python 2 with statements have a single item
+	AnnotateType("With", MapObj(Obj{
+		"body":          Check(OfKind(nodes.KindArray), Var("body_stmts")),
+		"context_expr":  ObjectRoles("expr"),
+		"optional_vars": Var("vars"),
+	}, Obj{
+		"body": Obj{
+			uast.KeyType:  String("With.body"),
+			uast.KeyRoles: Roles(role.Block, role.Scope, role.Body, role.Incomplete),
+			"body_stmts":  Var("body_stmts"),
+		},
+		"context_expr":  ObjectRoles("expr", role.Identifier, role.Expression, role.Incomplete),
+		"optional_vars": Var("vars"),
+	}), role.Block, role.Scope, role.Statement),
 	AnnotateType("withitem", nil, role.Identifier, role.Expression, role.Incomplete),
 
 	// uast.List/uast.Map/uast.Set comprehensions. We map the "for x in y" to uast.For, uast.Iterator (foreach)
@@ -292,7 +326,10 @@ var Annotations = []Mapping{
 	// (some preprocessors or linters can use them, the runtimes ignore them). The
 	// TOKEN will take the annotation in the UAST node so the information is keept in
 	// any case.
-	AnnotateType("AnnAssign", nil, role.Operator, role.Binary, role.Assignment),
+	AnnotateType("AnnAssign", ObjRoles{
+		"target": {role.Left},
+		"value":  {role.Right},
+	}, role.Operator, role.Binary, role.Assignment),
 	AnnotateType("annotation", nil, role.Annotation, role.Noop),
 	AnnotateType("returns", nil, role.Annotation, role.Noop),
 
@@ -318,6 +355,12 @@ var Annotations = []Mapping{
 			"default":    {Opt: true, Roles: role.Roles{role.Argument, role.Default}},
 			"annotation": {Opt: true, Roles: role.Roles{role.Annotation, role.Noop}},
 		}, role.Function, role.Declaration, role.Argument, role.Name),
+	// Incomplete because we've no role for "positional only"
+	AnnotateType("posonly_arg",
+		FieldRoles{
+			"default":    {Opt: true, Roles: role.Roles{role.Argument, role.Default}},
+			"annotation": {Opt: true, Roles: role.Roles{role.Annotation, role.Noop}},
+		}, role.Function, role.Declaration, role.Argument, role.Name, role.Incomplete),
 	// Incomplete because we've no role for "mandatory name"
 	AnnotateType("kwonly_arg",
 		FieldRoles{
@@ -334,6 +377,13 @@ var Annotations = []Mapping{
 		"keywords": {Arr: true, Roles: role.Roles{role.Function, role.Call, role.Argument}},
 	}, role.Function, role.Call, role.Expression),
 
+	// Calls in the semantic mode, converted by the calls transformer
+	AnnotateType("Call", FieldRoles{
+		"args":     {Arr: true, Roles: role.Roles{role.Function, role.Call, role.Positional, role.Argument}},
+		"callee":   {Roles: role.Roles{role.Call, role.Callee}},
+		"keywords": {Arr: true, Roles: role.Roles{role.Function, role.Call, role.Argument, role.Name}},
+	}, role.Function, role.Call, role.Expression),
+
 	// Keywords are additionally annotated in FunctionDef and ClassDef
 	AnnotateType("keyword", FieldRoles{
 		"value": {Roles: role.Roles{role.Argument, role.Value}},
@@ -451,6 +501,11 @@ var Annotations = []Mapping{
 		uast.KeyToken: {Add: true, Op: String("exec")},
 	}, role.Function, role.Call, role.Expression),
 
+	AnnotateType("Repr", FieldRoles{
+		"value":       {Roles: role.Roles{role.Call, role.Argument, role.Positional}},
+		uast.KeyToken: {Add: true, Op: String("repr")},
+	}, role.Function, role.Call, role.Expression),
+
 	AnnotateType("Print", FieldRoles{
 		"values":      {Arr: true, Roles: role.Roles{role.Call, role.Argument, role.Positional}},
 		uast.KeyToken: {Add: true, Op: String("print")},
@@ -498,6 +553,98 @@ var Annotations = []Mapping{
 		"test": {role.While, role.Condition},
 	}),
 
+	// Control flow statements in the semantic mode, see controlflow.go
+	AnnotateType("If", FieldRoles{
+		"condition": {Roles: role.Roles{role.If, role.Condition}},
+		"then":      {Roles: role.Roles{role.If, role.Body, role.Then}},
+		"else":      {Opt: true, Roles: role.Roles{role.If, role.Body, role.Else}},
+	}, role.If, role.Statement),
+	AnnotateType("Loop", FieldRoles{
+		"kind":     {Op: String("for")},
+		"target":   {Roles: role.Roles{role.For, role.Update}},
+		"iterable": {Roles: role.Roles{role.For, role.Expression}},
+		"body":     {Roles: role.Roles{role.For, role.Body}},
+		"else":     {Opt: true, Roles: role.Roles{role.For, role.Body, role.Else}},
+	}, role.For, role.Iterator, role.Statement),
+	AnnotateType("Loop", FieldRoles{
+		"kind":      {Op: String("while")},
+		"condition": {Roles: role.Roles{role.While, role.Condition}},
+		"body":      {Roles: role.Roles{role.While, role.Body}},
+		"else":      {Opt: true, Roles: role.Roles{role.While, role.Body, role.Else}},
+	}, role.While, role.Statement),
+	AnnotateType("Try", FieldRoles{
+		"body":     {Roles: role.Roles{role.Try, role.Body}},
+		"handlers": {Arr: true, Roles: role.Roles{role.Try, role.Catch}},
+		"else":     {Opt: true, Roles: role.Roles{role.Try, role.Else}},
+		"finally":  {Opt: true, Roles: role.Roles{role.Try, role.Finally}},
+	}, role.Try, role.Statement),
+	AnnotateType("ExceptHandler", FieldRoles{
+		"type": {Opt: true, Roles: role.Roles{role.Catch, role.Type}},
+		"body": {Roles: role.Roles{role.Catch, role.Body}},
+	}, role.Try, role.Catch),
+	AnnotateType("With", FieldRoles{
+		"items": {Arr: true, Roles: role.Roles{role.Block, role.Scope, role.Incomplete}},
+		"body":  {Roles: role.Roles{role.Block, role.Scope, role.Body}},
+	}, role.Block, role.Scope, role.Statement),
+
+	// Structural pattern matching (Python 3.10). Patterns are conditions of the case
+	// clauses that may also bind names, so they are annotated like comparisons and
+	// assignments. Incomplete because there are no roles for patterns.
+	AnnotateType("Match", FieldRoles{
+		"subject": {Roles: role.Roles{role.Switch, role.Condition}},
+		"cases":   {Arr: true, Roles: role.Roles{role.Switch, role.Case}},
+	}, role.Switch, role.Statement),
+	AnnotateType("match_case", MapObj(Obj{
+		"body":    Var("body_stmts"),
+		"pattern": ObjectRoles("pattern"),
+		"guard":   OptObjectRoles("guard"),
+	}, Obj{
+		"body": Obj{
+			uast.KeyType:  String("match_case.body"),
+			uast.KeyRoles: Roles(role.Case, role.Body, role.Then),
+			"body_stmts":  Var("body_stmts"),
+		},
+		"pattern": ObjectRoles("pattern", role.Case, role.Condition),
+		"guard":   OptObjectRoles("guard", role.Case, role.If, role.Condition),
+	}), role.Case, role.Statement),
+	AnnotateType("MatchValue", FieldRoles{
+		"value": {Roles: role.Roles{role.Value}},
+	}, role.Relational, role.Equal, role.Incomplete),
+	AnnotateType("MatchSingleton", nil, role.Relational, role.Identical, role.Literal, role.Incomplete),
+	AnnotateType("MatchSequence", FieldRoles{
+		"patterns": {Arr: true, Roles: role.Roles{role.List, role.Entry}},
+	}, role.List, role.Incomplete),
+	AnnotateType("MatchMapping", FieldRoles{
+		"keys":     {Arr: true, Roles: role.Roles{role.Map, role.Key}},
+		"patterns": {Arr: true, Roles: role.Roles{role.Map, role.Value}},
+	}, role.Map, role.Incomplete),
+	AnnotateType("MatchClass", FieldRoles{
+		"cls":          {Roles: role.Roles{role.Type}},
+		"patterns":     {Arr: true, Roles: role.Roles{role.Argument, role.Positional}},
+		"kwd_patterns": {Arr: true, Roles: role.Roles{role.Argument, role.Name}},
+	}, role.Type, role.Instance, role.Incomplete),
+	AnnotateType("MatchStar", FieldRoles{
+		"name": {Rename: uast.KeyToken},
+	}, role.List, role.Variable, role.Declaration, role.Assignment, role.Incomplete),
+	// the wildcard pattern is a capture pattern with no name and no subpattern
+	AnnotateType("MatchAs", MapObj(Obj{
+		"pattern": Is(nil),
+		"name":    Is(nil),
+	}, Obj{
+		"pattern": Is(nil),
+		"name":    Is(nil),
+	}), role.Default),
+	AnnotateType("MatchAs", MapObj(Obj{
+		"name":    Check(Not(Is(nil)), Var("name")),
+		"pattern": OptObjectRoles("pattern"),
+	}, Obj{
+		uast.KeyToken: Var("name"),
+		"pattern":     OptObjectRoles("pattern", role.Condition),
+	}), role.Variable, role.Declaration, role.Assignment, role.Incomplete),
+	AnnotateType("MatchOr", FieldRoles{
+		"patterns": {Arr: true, Roles: role.Roles{role.Or}},
+	}, role.Boolean, role.Or, role.Incomplete),
+
 	// Comparison nodes in Python are oddly structured. Probably one if the first
 	// things that could be changed once we can normalize tree structures. Check:
 	// https://greentreesnakes.readthedocs.io/en/latest/nodes.html#Compare
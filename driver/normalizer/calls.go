@@ -0,0 +1,115 @@
+package normalizer
+
+import (
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+)
+
+// foldSpreadArgs moves the spread arguments of Python 2 calls, in the "starargs" and
+// "kwargs" fields, to the end of the positional and keyword arguments, as a Starred
+// argument and a keyword with no name, like they are in Python 3. Python 2 only allows
+// them after the other arguments, so the order of the source is kept.
+var foldSpreadArgs = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	if uast.TypeOf(obj) != "Call" {
+		return obj, false, nil
+	}
+	star, ok1 := obj["starargs"]
+	kwargs, ok2 := obj["kwargs"]
+	if !ok1 && !ok2 {
+		return obj, false, nil
+	}
+	obj = obj.CloneObject()
+	delete(obj, "starargs")
+	delete(obj, "kwargs")
+	if star, ok := star.(nodes.Object); ok {
+		args, _ := obj["args"].(nodes.Array)
+		starred := nodes.Object{
+			uast.KeyType: nodes.String("Starred"),
+			"value":      star,
+			"ctx":        nodes.String("Load"),
+		}
+		if pos, ok := star[uast.KeyPos]; ok {
+			starred[uast.KeyPos] = pos
+		}
+		obj["args"] = append(args.CloneList(), starred)
+	}
+	if kwargs, ok := kwargs.(nodes.Object); ok {
+		keywords, _ := obj["keywords"].(nodes.Array)
+		kw := nodes.Object{
+			uast.KeyType: nodes.String("keyword"),
+			"arg":        nil,
+			"value":      kwargs,
+		}
+		if pos, ok := kwargs[uast.KeyPos]; ok {
+			kw[uast.KeyPos] = pos
+		}
+		obj["keywords"] = append(keywords.CloneList(), kw)
+	}
+	return obj, true, nil
+})
+
+// callArgs converts the positional arguments of calls to uast:Argument nodes, marking
+// the starred ones as Variadic. The other arguments have no position of their own,
+// only their values have one.
+var callArgs = struct {
+	Native, Semantic Op
+}{
+	Native: Each("args", Cases("arg_case",
+		Fields{
+			{Name: uast.KeyType, Op: String("Starred")},
+			{Name: uast.KeyPos, Op: Var("star_pos")},
+			{Name: "value", Op: Var("star")},
+			// starred arguments are always loaded
+			{Name: "ctx", Op: String("Load")},
+		},
+		Var("arg"),
+	)),
+	Semantic: Each("args", Cases("arg_case",
+		UASTType(uast.Argument{}, Obj{
+			uast.KeyPos: Var("star_pos"),
+			"Init":      Var("star"),
+			"Variadic":  Bool(true),
+		}),
+		UASTType(uast.Argument{}, Obj{
+			"Init": Var("arg"),
+		}),
+	)),
+}
+
+// keywordArgs converts the keyword arguments of calls and classes to named arguments,
+// positioned at the argument name by tokenPositions. The keywords with no name are the
+// **kwargs spread arguments. The keywords of classes are the arguments of the
+// metaclass. Like the import aliases, they are only converted as a part of their
+// parent, so the reverse transformation doesn't confuse them with the arguments of
+// functions that have a default value.
+var keywordArgs = struct {
+	Native, Semantic Op
+}{
+	Native: Each("keywords", Cases("kw_case",
+		Obj{
+			uast.KeyType: String("keyword"),
+			uast.KeyPos:  positions(),
+			"arg":        Is(nil),
+			"value":      Var("kw_value"),
+		},
+		Obj{
+			uast.KeyType: String("keyword"),
+			uast.KeyPos:  positions(),
+			"arg":        VarKind("kw_name", nodes.KindString),
+			"value":      Var("kw_value"),
+		},
+	)),
+	Semantic: Each("keywords", Cases("kw_case",
+		UASTType(uast.Argument{}, Obj{
+			uast.KeyPos:   positions(),
+			"Init":        Var("kw_value"),
+			"MapVariadic": Bool(true),
+		}),
+		UASTType(uast.Argument{}, Obj{
+			uast.KeyPos: positions(),
+			"Name":      identifierWithPos("kw_name"),
+			"Init":      Var("kw_value"),
+		}),
+	)),
+}
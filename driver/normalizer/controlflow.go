@@ -0,0 +1,174 @@
+package normalizer
+
+import (
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+)
+
+// The control flow statements share a few semantic shapes:
+//
+//	If{condition, then, else}
+//	Loop{kind, async, target, iterable, condition, body, else}
+//	Try{body, handlers, else, finally}
+//	ExceptHandler{type, name, body}
+//	With{async, items, body}
+//
+// The lists of statements are converted to blocks, and the optional clauses ("else"
+// and "finally") are nil when they are not in the source. The loops are "for" or
+// "while" loops: the for loops have a target and an iterable, and the while loops a
+// condition.
+
+// block converts a list of statements to a block.
+func block(vr string) Op {
+	return UASTType(uast.Block{}, Obj{
+		"Statements": Var(vr),
+	})
+}
+
+// optBlock converts a list of statements that may be empty to a block, or to nil if
+// it's empty.
+func optBlock(vr string) (native, semantic Op) {
+	native = Cases(vr+"_case",
+		Arr(),
+		Var(vr),
+	)
+	semantic = Cases(vr+"_case",
+		Is(nil),
+		block(vr),
+	)
+	return native, semantic
+}
+
+func ifMap() Mapping {
+	elseNative, elseSemantic := optBlock("else")
+	return Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("If")},
+			{Name: "test", Op: Var("test")},
+			{Name: "body", Op: Var("body")},
+			{Name: "orelse", Op: elseNative},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("If")},
+			{Name: "condition", Op: Var("test")},
+			{Name: "then", Op: block("body")},
+			{Name: "else", Op: elseSemantic},
+		}),
+	)
+}
+
+func forMap(typ string, async bool) Mapping {
+	elseNative, elseSemantic := optBlock("else")
+	return Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String(typ)},
+			{Name: "target", Op: Var("target")},
+			{Name: "iter", Op: Var("iter")},
+			{Name: "body", Op: Var("body")},
+			{Name: "orelse", Op: elseNative},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Loop")},
+			{Name: "kind", Op: String("for")},
+			{Name: "async", Op: Bool(async)},
+			{Name: "target", Op: Var("target")},
+			{Name: "iterable", Op: Var("iter")},
+			{Name: "condition", Op: Is(nil)},
+			{Name: "body", Op: block("body")},
+			{Name: "else", Op: elseSemantic},
+		}),
+	)
+}
+
+func whileMap() Mapping {
+	elseNative, elseSemantic := optBlock("else")
+	return Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("While")},
+			{Name: "test", Op: Var("test")},
+			{Name: "body", Op: Var("body")},
+			{Name: "orelse", Op: elseNative},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Loop")},
+			{Name: "kind", Op: String("while")},
+			{Name: "async", Op: Bool(false)},
+			{Name: "target", Op: Is(nil)},
+			{Name: "iterable", Op: Is(nil)},
+			{Name: "condition", Op: Var("test")},
+			{Name: "body", Op: block("body")},
+			{Name: "else", Op: elseSemantic},
+		}),
+	)
+}
+
+func tryMap() Mapping {
+	elseNative, elseSemantic := optBlock("else")
+	finalNative, finalSemantic := optBlock("final")
+	return Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Try")},
+			{Name: "body", Op: Var("body")},
+			{Name: "handlers", Op: Var("handlers")},
+			{Name: "orelse", Op: elseNative},
+			{Name: "finalbody", Op: finalNative},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Try")},
+			{Name: "body", Op: block("body")},
+			{Name: "handlers", Op: Var("handlers")},
+			{Name: "else", Op: elseSemantic},
+			{Name: "finally", Op: finalSemantic},
+		}),
+	)
+}
+
+// exceptHandlerMap converts the name bound by the handlers to an identifier. The
+// handlers are positioned at the name by tokenPositions. Python 2 handlers may bind an
+// expression other than a name, that is kept as it is.
+func exceptHandlerMap() Mapping {
+	return Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("ExceptHandler")},
+			{Name: uast.KeyPos, Op: Var("pos")},
+			{Name: "type", Op: Var("type")},
+			{Name: "name", Op: Cases("name_case",
+				Is(nil),
+				VarKind("name", nodes.KindString),
+				Var("name_expr"),
+			)},
+			{Name: "body", Op: Var("body")},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("ExceptHandler")},
+			{Name: uast.KeyPos, Op: Var("pos")},
+			{Name: "type", Op: Var("type")},
+			{Name: "name", Op: Cases("name_case",
+				Is(nil),
+				UASTType(uast.Identifier{}, Obj{
+					uast.KeyPos: Var("pos"),
+					"Name":      Var("name"),
+				}),
+				Var("name_expr"),
+			)},
+			{Name: "body", Op: block("body")},
+		}),
+	)
+}
+
+func withMap(typ string, async bool) Mapping {
+	return Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String(typ)},
+			{Name: "items", Op: Var("items")},
+			{Name: "body", Op: Var("body")},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("With")},
+			{Name: "async", Op: Bool(async)},
+			{Name: "items", Op: Var("items")},
+			{Name: "body", Op: block("body")},
+		}),
+	)
+}
@@ -0,0 +1,344 @@
+package normalizer
+
+import (
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+)
+
+// decoratorFlags are the fields set on the functions for the well-known decorators.
+var decoratorFlags = []string{
+	"static", "classmethod", "property", "setter", "deleter", "abstract", "overload",
+}
+
+// knownDecorators are the flags set by the well-known decorators, by their qualified
+// name. The names of the standard library are also recognized when they are not
+// imported explicitly (star imports), except for the accessors.
+var knownDecorators = map[string][]string{
+	"staticmethod":               {"static"},
+	"classmethod":                {"classmethod"},
+	"property":                   {"property"},
+	"functools.cached_property":  {"property"},
+	"abc.abstractmethod":         {"abstract"},
+	"abc.abstractproperty":       {"abstract", "property"},
+	"abc.abstractclassmethod":    {"abstract", "classmethod"},
+	"abc.abstractstaticmethod":   {"abstract", "static"},
+	"typing.overload":            {"overload"},
+	"typing_extensions.overload": {"overload"},
+	"cached_property":            {"property"},
+	"abstractmethod":             {"abstract"},
+	"abstractproperty":           {"abstract", "property"},
+	"abstractclassmethod":        {"abstract", "classmethod"},
+	"abstractstaticmethod":       {"abstract", "static"},
+	"overload":                   {"overload"},
+}
+
+// accessorDecorators are the flags set by the attributes of properties used as
+// decorators, like "@x.setter".
+var accessorDecorators = map[string]string{
+	"getter":  "property",
+	"setter":  "setter",
+	"deleter": "deleter",
+}
+
+// decorators converts the decorators of functions and classes to identifiers (or
+// qualified identifiers) and calls with an identifier as the function, and sets the
+// "decorator_names" field to the qualified names of the decorators, resolved with the
+// imports of the module. The names of the decorators that are not imported (builtins
+// or names defined in the module) are kept as they are, and the decorators that are
+// not names nor calls have an empty name.
+//
+// The functions also have a field for each one of the decoratorFlags, set if one of
+// the decorators is a well-known one.
+type decorators struct{}
+
+var _ Transformer = decorators{}
+
+func (decorators) Do(root nodes.Node) (nodes.Node, error) {
+	imports := make(map[string]string)
+	collectImports(root, imports)
+	return TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+		typ := uast.TypeOf(obj)
+		switch typ {
+		case "FunctionDef", "AsyncFunctionDef", "ClassDef":
+		default:
+			return obj, false, nil
+		}
+		list, _ := obj["decorator_list"].(nodes.Array)
+		obj = obj.CloneObject()
+		decs := make(nodes.Array, 0, len(list))
+		names := make(nodes.Array, 0, len(list))
+		flags := make(map[string]bool)
+		for _, d := range list {
+			name := resolveName(decoratorName(d), imports)
+			names = append(names, nodes.String(name))
+			decs = append(decs, convertDecorator(d))
+			for _, f := range knownDecorators[name] {
+				flags[f] = true
+			}
+			if i := strings.LastIndexByte(name, '.'); i >= 0 {
+				if f, ok := accessorDecorators[name[i+1:]]; ok {
+					flags[f] = true
+				}
+			}
+		}
+		obj["decorator_list"] = decs
+		obj["decorator_names"] = names
+		if typ != "ClassDef" {
+			for _, f := range decoratorFlags {
+				obj[f] = nodes.Bool(flags[f])
+			}
+		}
+		return obj, true, nil
+	}).Do(root)
+}
+
+// restoreDecorators converts the decorators of functions and classes back to the
+// native names, attributes and calls, and removes the fields set by decorators. The
+// decorators are always loaded.
+var restoreDecorators = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	switch uast.TypeOf(obj) {
+	case "FunctionDef", "AsyncFunctionDef", "ClassDef":
+	default:
+		return obj, false, nil
+	}
+	if _, ok := obj["decorator_names"]; !ok {
+		return obj, false, nil
+	}
+	obj = obj.CloneObject()
+	delete(obj, "decorator_names")
+	for _, f := range decoratorFlags {
+		delete(obj, f)
+	}
+	list, _ := obj["decorator_list"].(nodes.Array)
+	decs := make(nodes.Array, 0, len(list))
+	for _, d := range list {
+		decs = append(decs, nativeDecorator(d))
+	}
+	obj["decorator_list"] = decs
+	return obj, true, nil
+})
+
+// nativeDecorator converts a decorator converted by convertDecorator back to the
+// native node.
+func nativeDecorator(n nodes.Node) nodes.Node {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return n
+	}
+	switch uast.TypeOf(obj) {
+	case uast.TypeOf(uast.Identifier{}):
+		return nativeName(obj, "Name", "id")
+	case uast.TypeOf(uast.QualifiedIdentifier{}):
+		names, _ := obj["Names"].(nodes.Array)
+		ids := make(nodes.Array, 0, len(names))
+		for i, id := range names {
+			id, _ := id.(nodes.Object)
+			if i == 0 {
+				ids = append(ids, nativeName(id, "Name", "id"))
+			} else {
+				ids = append(ids, nativeName(id, "Attribute", "attr"))
+			}
+		}
+		out := nodes.Object{
+			uast.KeyType:  nodes.String("QualifiedIdentifier"),
+			"identifiers": ids,
+			"ctx":         nodes.String("Load"),
+		}
+		copyPos(out, obj)
+		return out
+	case "Call":
+		obj = obj.CloneObject()
+		obj["func"] = nativeDecorator(obj["func"])
+		return obj
+	}
+	return n
+}
+
+// nativeName converts a uast:Identifier to a loaded native node of the given type,
+// with the name in the given field.
+func nativeName(id nodes.Object, typ, field string) nodes.Object {
+	obj := nodes.Object{
+		uast.KeyType: nodes.String(typ),
+		field:        id["Name"],
+		"ctx":        nodes.String("Load"),
+	}
+	copyPos(obj, id)
+	return obj
+}
+
+// collectImports sets the qualified names of the names bound by the imports of the
+// module, excluding the ones in functions and classes.
+func collectImports(n nodes.Node, imports map[string]string) {
+	switch n := n.(type) {
+	case nodes.Array:
+		for _, e := range n {
+			collectImports(e, imports)
+		}
+	case nodes.Object:
+		switch uast.TypeOf(n) {
+		case "FunctionDef", "AsyncFunctionDef", "ClassDef", "Lambda":
+			return
+		case "Import":
+			names, _ := n["names"].(nodes.Array)
+			for _, a := range names {
+				a, _ := a.(nodes.Object)
+				name, _ := a["name"].(nodes.String)
+				if asname, ok := a["asname"].(nodes.String); ok {
+					imports[string(asname)] = string(name)
+				} else {
+					// "import a.b" binds "a"
+					first := strings.SplitN(string(name), ".", 2)[0]
+					imports[first] = first
+				}
+			}
+			return
+		case "ImportFrom":
+			module, _ := n["module"].(nodes.String)
+			level, _ := n["level"].(nodes.Int)
+			prefix := strings.Repeat(".", int(level)) + string(module)
+			if module != "" {
+				prefix += "."
+			}
+			names, _ := n["names"].(nodes.Array)
+			for _, a := range names {
+				a, _ := a.(nodes.Object)
+				name, _ := a["name"].(nodes.String)
+				if name == "*" {
+					continue
+				}
+				bound := name
+				if asname, ok := a["asname"].(nodes.String); ok {
+					bound = asname
+				}
+				imports[string(bound)] = prefix + string(name)
+			}
+			return
+		}
+		for _, v := range n {
+			collectImports(v, imports)
+		}
+	}
+}
+
+// decoratorName returns the dotted name of a decorator, or of the function called by
+// it. It returns an empty string for other expressions.
+func decoratorName(n nodes.Node) string {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return ""
+	}
+	switch uast.TypeOf(obj) {
+	case "Name":
+		id, _ := obj["id"].(nodes.String)
+		return string(id)
+	case "Attribute":
+		attr, _ := obj["attr"].(nodes.String)
+		if base := decoratorName(obj["value"]); base != "" {
+			return base + "." + string(attr)
+		}
+	case "QualifiedIdentifier":
+		ids, _ := obj["identifiers"].(nodes.Array)
+		parts := make([]string, 0, len(ids))
+		for _, id := range ids {
+			name := decoratorName(id)
+			if id, ok := id.(nodes.Object); ok && uast.TypeOf(id) == "Attribute" {
+				// the attributes of a qualified identifier have no value
+				attr, _ := id["attr"].(nodes.String)
+				name = string(attr)
+			}
+			if name == "" {
+				return ""
+			}
+			parts = append(parts, name)
+		}
+		return strings.Join(parts, ".")
+	case "Call":
+		return decoratorName(obj["func"])
+	}
+	return ""
+}
+
+// resolveName replaces the first name of the dotted name by the name it is imported
+// from, if any.
+func resolveName(name string, imports map[string]string) string {
+	if name == "" {
+		return ""
+	}
+	parts := strings.SplitN(name, ".", 2)
+	qual, ok := imports[parts[0]]
+	if !ok {
+		return name
+	}
+	if len(parts) == 1 {
+		return qual
+	}
+	return qual + "." + parts[1]
+}
+
+// convertDecorator converts a decorator that is a name (or calls one) to a semantic
+// identifier. The decorators with comments are kept as they are.
+func convertDecorator(n nodes.Node) nodes.Node {
+	obj, ok := n.(nodes.Object)
+	if !ok || hasNoops(obj) {
+		return n
+	}
+	switch uast.TypeOf(obj) {
+	case "Name":
+		id, _ := obj["id"].(nodes.String)
+		return identifier(obj, string(id))
+	case "QualifiedIdentifier":
+		ids, _ := obj["identifiers"].(nodes.Array)
+		qid := nodes.Array{}
+		for _, id := range ids {
+			id, ok := id.(nodes.Object)
+			if !ok || hasNoops(id) {
+				return n
+			}
+			var name nodes.String
+			switch uast.TypeOf(id) {
+			case "Name":
+				name, _ = id["id"].(nodes.String)
+			case "Attribute":
+				name, _ = id["attr"].(nodes.String)
+			default:
+				return n
+			}
+			qid = append(qid, identifier(id, string(name)))
+		}
+		out := nodes.Object{
+			uast.KeyType: nodes.String(uast.TypeOf(uast.QualifiedIdentifier{})),
+			"Names":      qid,
+		}
+		if pos, ok := obj[uast.KeyPos]; ok {
+			out[uast.KeyPos] = pos
+		}
+		return out
+	case "Call":
+		fnc := convertDecorator(obj["func"])
+		obj = obj.CloneObject()
+		obj["func"] = fnc
+		return obj
+	}
+	return n
+}
+
+// identifier returns a uast:Identifier with the position of the node.
+func identifier(obj nodes.Object, name string) nodes.Object {
+	id := nodes.Object{
+		uast.KeyType: nodes.String(uast.TypeOf(uast.Identifier{})),
+		"Name":       nodes.String(name),
+	}
+	if pos, ok := obj[uast.KeyPos]; ok {
+		id[uast.KeyPos] = pos
+	}
+	return id
+}
+
+func hasNoops(obj nodes.Object) bool {
+	_, prev := obj["noops_previous"]
+	_, same := obj["noops_sameline"]
+	return prev || same
+}
@@ -0,0 +1,217 @@
+package normalizer
+
+import (
+	"strings"
+
+	"github.com/bblfsh/python-driver/driver/parser"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+)
+
+// forwardRefs parses the string literals used in type annotations (forward
+// references, PEP 484) and sets their "forward_ref" field to the preprocessed AST of
+// the expression. The strings are replaced by the expression by replaceForwardRefs
+// in the semantic mode, so it's normalized as the rest of the code.
+//
+// Strings nested in an annotation (like in List["Node"]) are parsed too, except the
+// arguments of Literal. The expression is positioned in the source code if the string
+// has no escapes, and has no positions otherwise. Strings that are not expressions
+// are kept as they are.
+//
+// It must run before the positions are converted, because the native positions of
+// the expression are moved to the position of the string.
+type forwardRefs struct{}
+
+var _ sourceTransformer = forwardRefs{}
+
+func (forwardRefs) onSource(s *source) Transformer {
+	return TransformObjFunc(s.fixAnnotation)
+}
+
+// replaceForwardRefs replaces the string annotations parsed by forwardRefs with the
+// expression they contain. The comments of the string are moved by moveDroppedNoops
+// first, unless it's nested in the annotation, and the remaining ones are moved to
+// the expression.
+var replaceForwardRefs = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	ref, ok := obj["forward_ref"].(nodes.Object)
+	if !ok || uast.TypeOf(obj) != "Str" {
+		return obj, false, nil
+	}
+	ref = ref.CloneObject()
+	moveNoops(ref, obj)
+	return ref, true, nil
+})
+
+// annotationFields are the fields with a type annotation of each node type.
+var annotationFields = map[string]string{
+	"arg":              "annotation",
+	"posonly_arg":      "annotation",
+	"kwonly_arg":       "annotation",
+	"vararg":           "annotation",
+	"kwarg":            "annotation",
+	"AnnAssign":        "annotation",
+	"FunctionDef":      "returns",
+	"AsyncFunctionDef": "returns",
+}
+
+// fixAnnotation sets the forward references in the annotation of the node. The code
+// is only tokenized if the annotation has a string literal.
+func (s *source) fixAnnotation(obj nodes.Object) (nodes.Object, bool, error) {
+	field, ok := annotationFields[uast.TypeOf(obj)]
+	if !ok || obj[field] == nil {
+		return obj, false, nil
+	}
+	ann, changed := s.forwardRefs(obj[field])
+	if !changed {
+		return obj, false, nil
+	}
+	obj = obj.CloneObject()
+	obj[field] = ann
+	return obj, true, nil
+}
+
+// forwardRefs sets the forward references of the string literals in the annotation.
+func (s *source) forwardRefs(n nodes.Node) (nodes.Node, bool) {
+	switch n := n.(type) {
+	case nodes.Array:
+		var out nodes.Array
+		for i, e := range n {
+			ne, changed := s.forwardRefs(e)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = append(nodes.Array{}, n...)
+			}
+			out[i] = ne
+		}
+		return out, out != nil
+	case nodes.Object:
+		switch uast.TypeOf(n) {
+		case "Str":
+			ref := s.parseForwardRef(n)
+			if ref == nil {
+				return n, false
+			}
+			n = n.CloneObject()
+			n["forward_ref"] = ref
+			return n, true
+		case "Subscript":
+			if isLiteralType(n["value"]) {
+				return n, false
+			}
+		}
+		var out nodes.Object
+		for k, v := range n {
+			nv, changed := s.forwardRefs(v)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = n.CloneObject()
+			}
+			out[k] = nv
+		}
+		return out, out != nil
+	}
+	return n, false
+}
+
+// isLiteralType checks if the node is the Literal type, whose arguments are values.
+func isLiteralType(n nodes.Node) bool {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return false
+	}
+	switch uast.TypeOf(obj) {
+	case "Name":
+		return obj["id"] == nodes.String("Literal")
+	case "Attribute":
+		return obj["attr"] == nodes.String("Literal")
+	}
+	return false
+}
+
+// parseForwardRef returns the preprocessed AST of the expression in the string, or
+// nil if it's not a valid expression.
+func (s *source) parseForwardRef(str nodes.Object) nodes.Node {
+	v, ok := str["s"].(nodes.String)
+	if !ok {
+		return nil
+	}
+	line, col, _ := s.tokens().stringContent(str)
+	return parseTypeExpr(string(v), line, col)
+}
+
+// parseTypeExpr parses the expression of a type written in a string or a comment
+// that starts at the given line and column, and returns its preprocessed AST. The
+// expression has no positions if the line is zero. It returns nil if the code is not
+// a valid expression.
+func parseTypeExpr(src string, line, col int) nodes.Node {
+	ast, err := parser.ParseExpression(src)
+	if err != nil {
+		return nil
+	}
+	ast = movePositions(ast, line, col)
+	for _, tr := range Preprocess {
+		if ast, err = tr.Do(ast); err != nil {
+			return nil
+		}
+	}
+	return ast
+}
+
+// stringContent returns the position of the value of the string literal in the
+// source code. It fails if the value of the literal is not written verbatim.
+func (t *tokenIndex) stringContent(str nodes.Object) (line, col int, _ bool) {
+	i := t.at(str)
+	if i < 0 {
+		return 0, 0, false
+	}
+	tok := t.toks[i]
+	raw := strings.TrimLeft(tok.Value, "rRuU")
+	quote := raw[:1]
+	if strings.HasPrefix(raw, strings.Repeat(quote, 3)) && len(raw) >= 6 {
+		quote = raw[:3]
+	}
+	if !strings.HasSuffix(raw, quote) || raw[len(quote):len(raw)-len(quote)] != string(str["s"].(nodes.String)) {
+		return 0, 0, false
+	}
+	return tok.Start.Line, tok.Start.Col + len(tok.Value) - len(raw) + len(quote), true
+}
+
+// movePositions moves the native positions of the AST of an expression parsed from
+// a string to the position of the string content at the given line and column. The
+// positions are removed if the line is zero.
+func movePositions(n nodes.Node, line, col int) nodes.Node {
+	switch n := n.(type) {
+	case nodes.Array:
+		out := make(nodes.Array, 0, len(n))
+		for _, e := range n {
+			out = append(out, movePositions(e, line, col))
+		}
+		return out
+	case nodes.Object:
+		out := make(nodes.Object, len(n))
+		for k, v := range n {
+			out[k] = movePositions(v, line, col)
+		}
+		for _, keys := range [][2]string{{"lineno", "col_offset"}, {"end_lineno", "end_col_offset"}} {
+			l, lok := n[keys[0]].(nodes.Int)
+			c, cok := n[keys[1]].(nodes.Int)
+			if line == 0 || !lok || !cok {
+				delete(out, keys[0])
+				delete(out, keys[1])
+				continue
+			}
+			if l == 1 {
+				c += nodes.Int(col - 1)
+			}
+			out[keys[0]] = l + nodes.Int(line-1)
+			out[keys[1]] = c
+		}
+		return out
+	}
+	return n
+}
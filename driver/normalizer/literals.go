@@ -0,0 +1,170 @@
+package normalizer
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+)
+
+// numLiterals sets the "literal" field of the numeric literals to their spelling in
+// the source code. The native AST only has the value of the number, so the base,
+// the digit separators and the exponent of the literal are lost.
+//
+// The field is not set if the token of the literal cannot be found or if it is not
+// the native value of the node, so the native value can always be restored from the
+// literal. The floats out of range keep their spelling, with an infinite value.
+type numLiterals struct{}
+
+var _ sourceTransformer = numLiterals{}
+
+func (numLiterals) onSource(s *source) Transformer {
+	return TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+		if uast.TypeOf(obj) != "Num" {
+			return obj, false, nil
+		}
+		t := s.tokens()
+		lit := t.number(t.at(obj))
+		kind, val, err := numberValue(lit)
+		if err != nil {
+			return obj, false, nil
+		}
+		if n, err := nativeNumber(kind, val); err != nil || !nodes.NodeEqual(n, obj["n"]) {
+			return obj, false, nil
+		}
+		obj = obj.CloneObject()
+		obj["literal"] = nodes.String(lit)
+		return obj, true, nil
+	})
+}
+
+// numberValue parses a numeric literal and returns its kind ("int", "float" or
+// "complex") and its value. The value of complex literals is the imaginary part.
+// Integers have arbitrary precision, so the ones that do not fit in an int64 are
+// returned as a decimal string, and the floats out of range as "inf", like in the
+// native AST. The Python 2 octal ("0777") and long ("10L") integers
+// are accepted.
+func numberValue(lit string) (string, nodes.Value, error) {
+	s := strings.Replace(lit, "_", "", -1)
+	if s == "" || !(s[0] == '.' || (s[0] >= '0' && s[0] <= '9')) {
+		return "", nil, fmt.Errorf("not a number: %q", lit)
+	}
+	if last := s[len(s)-1]; last == 'j' || last == 'J' {
+		f, err := floatValue(s[:len(s)-1])
+		if err != nil {
+			return "", nil, err
+		}
+		return "complex", f, nil
+	}
+	hasBase := len(s) > 1 && s[0] == '0' && strings.IndexByte("xXoObB", s[1]) >= 0
+	if last := s[len(s)-1]; last == 'l' || last == 'L' {
+		s = s[:len(s)-1]
+	} else if !hasBase && strings.ContainsAny(s, ".eE") {
+		f, err := floatValue(s)
+		if err != nil {
+			return "", nil, err
+		}
+		return "float", f, nil
+	}
+	base := 10
+	switch {
+	case hasBase:
+		// the base is taken from the prefix
+		base = 0
+	case len(s) > 1 && s[0] == '0':
+		base = 8
+	}
+	v, ok := new(big.Int).SetString(s, base)
+	if !ok {
+		return "", nil, fmt.Errorf("invalid integer: %q", lit)
+	}
+	if v.IsInt64() {
+		return "int", nodes.Int(v.Int64()), nil
+	}
+	return "int", nodes.String(v.String()), nil
+}
+
+// floatValue parses a float, returning "inf" if it is out of range.
+func floatValue(s string) (nodes.Value, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange && math.IsInf(f, 1) {
+			return nodes.String("inf"), nil
+		}
+		return nil, err
+	}
+	return nodes.Float(f), nil
+}
+
+// nativeNumber returns the value of a number in the native AST, given its kind and
+// its value as returned by numberValue. The native values are the ones of the JSON
+// serialization: integers that do not fit in a float64 lose their precision, and
+// complex numbers are objects with the real and the imaginary part. Infinities and
+// NaNs are strings.
+func nativeNumber(kind string, val nodes.Value) (nodes.Node, error) {
+	var (
+		n   nodes.Node
+		f   float64
+		err error
+	)
+	switch v := val.(type) {
+	case nodes.Int:
+		f = float64(v)
+	case nodes.Float:
+		f = float64(v)
+	case nodes.String:
+		if v == "inf" || v == "-inf" || v == "nan" {
+			n = v
+			break
+		}
+		i, ok := new(big.Int).SetString(string(v), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer: %q", v)
+		}
+		f, _ = new(big.Float).SetInt(i).Float64()
+	default:
+		return nil, fmt.Errorf("unexpected number value: %T", val)
+	}
+	if n == nil {
+		if n, err = nodes.ToNode(f, nil); err != nil {
+			return nil, err
+		}
+	}
+	switch kind {
+	case "int", "float":
+		return n, nil
+	case "complex":
+		return nodes.Object{"real": nodes.Int(0), "imag": n}, nil
+	}
+	return nil, fmt.Errorf("unexpected number kind: %q", kind)
+}
+
+// nativeNumberValue returns the kind and the value of a number from its value in the
+// native AST, for the numbers without a literal. The kind is a guess, since the
+// native floats with an integer value cannot be told apart from the integers.
+// Infinities and NaNs are kept as the strings of the native AST.
+func nativeNumberValue(n nodes.Node) (string, nodes.Value, bool) {
+	switch n := n.(type) {
+	case nodes.Int:
+		return "int", n, true
+	case nodes.Uint:
+		return "int", n, true
+	case nodes.Float:
+		return "float", n, true
+	case nodes.String:
+		switch n {
+		case "inf", "-inf", "nan":
+			return "float", n, true
+		}
+	case nodes.Object:
+		if v, ok := n["imag"].(nodes.Value); ok && len(n) == 2 {
+			return "complex", v, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,523 @@
+package normalizer
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+)
+
+// droppedNoops are the native node types that have no place to store the comments
+// in their semantic form.
+// See: https://github.com/bblfsh/sdk/issues/361
+// See: https://github.com/bblfsh/python-driver/issues/178
+var droppedNoops = map[string]bool{
+	"Import":      true,
+	"ImportFrom":  true,
+	"arg":         true,
+	"posonly_arg": true,
+	"kwonly_arg":  true,
+	"vararg":      true,
+	"kwarg":       true,
+	"keyword":     true,
+}
+
+// stmtLists are the fields that hold a list of statements.
+var stmtLists = []string{"body", "orelse", "finalbody"}
+
+// docOwners are the native node types that may have a docstring as the first
+// statement of the body.
+var docOwners = map[string]bool{
+	"Module":           true,
+	"FunctionDef":      true,
+	"AsyncFunctionDef": true,
+	"ClassDef":         true,
+}
+
+// moveDroppedNoops moves the comments of the nodes listed in droppedNoops (and of
+// the names and strings used as type annotations, that are unboxed) to the closest
+// statement list, as siblings of the statement containing them. The comments
+// attached to the node itself, or nested into it, are placed before the statement
+// and the same line comments of the statement after it, so they are all converted
+// to uast:Comment.
+//
+// The comments of docstrings are also moved, because docstrings are converted to a
+// uast:Comment too. They are all placed after the docstring, so it is still the
+// first statement of the body.
+//
+// The moved comment lines keep the node they are taken from in the "noops_owner"
+// field, so restoreDroppedNoops can put them back.
+var moveDroppedNoops = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	changed := false
+	for _, field := range stmtLists {
+		stmts, ok := obj[field].(nodes.Array)
+		if !ok {
+			continue
+		}
+		var out nodes.Array
+		for i, st := range stmts {
+			var (
+				nst           nodes.Node
+				before, after nodes.Array
+				ok            bool
+			)
+			if i == 0 && field == "body" && docOwners[uast.TypeOf(obj)] && isDocstring(st) {
+				nst, after, ok = takeDocNoops(st)
+			} else {
+				nst, before, after, ok = takeStmtNoops(st)
+			}
+			if !ok {
+				if out != nil {
+					out = append(out, st)
+				}
+				continue
+			}
+			if out == nil {
+				out = append(nodes.Array{}, stmts[:i]...)
+			}
+			out = append(out, before...)
+			out = append(out, nst)
+			out = append(out, after...)
+		}
+		if out == nil {
+			continue
+		}
+		if !changed {
+			obj = obj.CloneObject()
+			changed = true
+		}
+		obj[field] = out
+	}
+	return obj, changed, nil
+})
+
+// takeStmtNoops removes the dropped comments from the statement and its children
+// and returns the comment nodes to place before and after the statement.
+func takeStmtNoops(st nodes.Node) (_ nodes.Node, before, after nodes.Array, changed bool) {
+	obj, ok := st.(nodes.Object)
+	if !ok {
+		return st, nil, nil, false
+	}
+	if droppedNoops[uast.TypeOf(obj)] {
+		obj, before, after, changed = takeNoops(obj)
+	}
+	obj, nested, nchanged := takeNestedNoops(obj)
+	return obj, append(before, nested...), after, changed || nchanged
+}
+
+// takeNestedNoops removes the dropped comments of the children of the node, except
+// the ones in nested statement lists.
+func takeNestedNoops(obj nodes.Object) (nodes.Object, nodes.Array, bool) {
+	var out nodes.Array
+	changed := false
+	for _, k := range obj.Keys() {
+		if isStmtList(obj, k) {
+			continue
+		}
+		var (
+			nv       nodes.Node
+			noops    nodes.Array
+			nchanged bool
+		)
+		switch v := obj[k].(type) {
+		case nodes.Object:
+			nv, noops, nchanged = takeNodeNoops(v, k)
+		case nodes.Array:
+			var arr nodes.Array
+			for i, e := range v {
+				e, ok := e.(nodes.Object)
+				if !ok {
+					continue
+				}
+				ne, enoops, echanged := takeNodeNoops(e, k)
+				if !echanged {
+					continue
+				}
+				if arr == nil {
+					arr = append(nodes.Array{}, v...)
+				}
+				arr[i] = ne
+				noops = append(noops, enoops...)
+			}
+			nv, nchanged = arr, arr != nil
+		}
+		if !nchanged {
+			continue
+		}
+		if !changed {
+			obj = obj.CloneObject()
+			changed = true
+		}
+		obj[k] = nv
+		out = append(out, noops...)
+	}
+	return obj, out, changed
+}
+
+// takeNodeNoops removes the dropped comments of the node, stored in the given field
+// of its parent, and of its children.
+func takeNodeNoops(obj nodes.Object, field string) (nodes.Object, nodes.Array, bool) {
+	var (
+		out     nodes.Array
+		changed bool
+	)
+	typ := uast.TypeOf(obj)
+	if droppedNoops[typ] || (isAnnotationField(field) && (typ == "Name" || typ == "Str")) {
+		var prev, same nodes.Array
+		obj, prev, same, changed = takeNoops(obj)
+		out = append(prev, same...)
+	}
+	obj, nested, nchanged := takeNestedNoops(obj)
+	return obj, append(out, nested...), changed || nchanged
+}
+
+// takeNoops removes the comments of the node and returns the comment lines it had
+// before the node and at the same line, with their owner. The owner of the comments
+// of a forward reference is the expression that replaces the string.
+func takeNoops(obj nodes.Object) (_ nodes.Object, prev, same nodes.Array, changed bool) {
+	p, hasPrev := obj["noops_previous"]
+	s, hasSame := obj["noops_sameline"]
+	if !hasPrev && !hasSame {
+		return obj, nil, nil, false
+	}
+	owner := obj
+	if ref, ok := obj["forward_ref"].(nodes.Object); ok {
+		owner = ref
+	}
+	obj = obj.CloneObject()
+	delete(obj, "noops_previous")
+	delete(obj, "noops_sameline")
+	if p, ok := p.(nodes.Object); ok {
+		lines, _ := p["lines"].(nodes.Array)
+		for _, l := range lines {
+			if l, ok := l.(nodes.Object); ok {
+				prev = append(prev, ownedNoop(l, owner, "noops_previous", p))
+			}
+		}
+	}
+	if s, ok := s.(nodes.Object); ok {
+		lines, _ := s["noop_lines"].(nodes.Array)
+		for _, l := range lines {
+			// empty comments are removed by dropEmptyNoops anyway
+			if l, ok := l.(nodes.Object); ok && !isEmptyNoop(l) {
+				same = append(same, ownedNoop(l, owner, "noops_sameline", s))
+			}
+		}
+	}
+	return obj, prev, same, true
+}
+
+// ownedNoop returns a copy of the comment line taken from the given field of the
+// owner node, with the type and the position of the owner and of the noops node that
+// had the line in the "noops_owner" field.
+func ownedNoop(line, owner nodes.Object, field string, noops nodes.Object) nodes.Object {
+	line = line.CloneObject()
+	line["noops_owner"] = nodes.Object{
+		"type":      nodes.String(uast.TypeOf(owner)),
+		"pos":       owner[uast.KeyPos],
+		"field":     nodes.String(field),
+		"noops_pos": noops[uast.KeyPos],
+	}
+	return line
+}
+
+// restoreDroppedNoops puts the comment lines moved by moveDroppedNoops back into the
+// node they were taken from, that is searched in the statements of the same list.
+// The comments of the nodes that are not in the tree anymore are dropped.
+var restoreDroppedNoops = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	changed := false
+	for _, field := range stmtLists {
+		stmts, ok := obj[field].(nodes.Array)
+		if !ok {
+			continue
+		}
+		var (
+			out   nodes.Array
+			moved []*movedNoops
+		)
+		for _, st := range stmts {
+			line, _ := st.(nodes.Object)
+			owner, ok := line["noops_owner"].(nodes.Object)
+			if !ok {
+				out = append(out, st)
+				continue
+			}
+			moved = addMovedNoop(moved, line, owner)
+		}
+		if moved == nil {
+			continue
+		}
+		for i, st := range out {
+			if nst, ok := putNoops(st, moved); ok {
+				out[i] = nst
+			}
+		}
+		if out == nil {
+			out = nodes.Array{}
+		}
+		if !changed {
+			obj = obj.CloneObject()
+			changed = true
+		}
+		obj[field] = out
+	}
+	return obj, changed, nil
+})
+
+// movedNoops are the comments moved out of a node, by field.
+type movedNoops struct {
+	typ   nodes.Node
+	pos   nodes.Node
+	noops map[string]nodes.Object
+}
+
+// addMovedNoop adds the comment line to the comments moved out of its owner.
+func addMovedNoop(moved []*movedNoops, line, owner nodes.Object) []*movedNoops {
+	var m *movedNoops
+	for _, m2 := range moved {
+		if nodes.Equal(m2.typ, owner["type"]) && nodes.Equal(m2.pos, owner["pos"]) {
+			m = m2
+			break
+		}
+	}
+	if m == nil {
+		m = &movedNoops{typ: owner["type"], pos: owner["pos"], noops: make(map[string]nodes.Object)}
+		moved = append(moved, m)
+	}
+	line = line.CloneObject()
+	delete(line, "noops_owner")
+
+	field, _ := owner["field"].(nodes.String)
+	typ, key := "PreviousNoops", "lines"
+	if field == "noops_sameline" {
+		typ, key = "SameLineNoops", "noop_lines"
+	}
+	noops, ok := m.noops[string(field)]
+	if !ok {
+		noops = nodes.Object{
+			uast.KeyType: nodes.String(typ),
+			key:          nodes.Array{},
+		}
+		if pos := owner["noops_pos"]; pos != nil {
+			noops[uast.KeyPos] = pos
+		}
+		m.noops[string(field)] = noops
+	}
+	noops[key] = append(noops[key].(nodes.Array), line)
+	return moved
+}
+
+// putNoops sets the moved comments of the node and of its children, except the ones
+// in nested statement lists.
+func putNoops(n nodes.Node, moved []*movedNoops) (nodes.Node, bool) {
+	switch n := n.(type) {
+	case nodes.Array:
+		var out nodes.Array
+		for i, e := range n {
+			ne, ok := putNoops(e, moved)
+			if !ok {
+				continue
+			}
+			if out == nil {
+				out = n.CloneList()
+			}
+			out[i] = ne
+		}
+		return out, out != nil
+	case nodes.Object:
+		changed := false
+		for _, m := range moved {
+			if !nodes.Equal(m.typ, nodes.String(uast.TypeOf(n))) || !nodes.Equal(m.pos, n[uast.KeyPos]) {
+				continue
+			}
+			if !changed {
+				n = n.CloneObject()
+				changed = true
+			}
+			for field, noops := range m.noops {
+				n[field] = noops
+			}
+		}
+		for _, k := range n.Keys() {
+			if isStmtList(n, k) {
+				continue
+			}
+			v, ok := putNoops(n[k], moved)
+			if !ok {
+				continue
+			}
+			if !changed {
+				n = n.CloneObject()
+				changed = true
+			}
+			n[k] = v
+		}
+		return n, changed
+	}
+	return n, false
+}
+
+// isDocstring checks if the statement is a string literal, that is a docstring when
+// it's the first statement of a module, class or function.
+func isDocstring(st nodes.Node) bool {
+	obj, ok := st.(nodes.Object)
+	if !ok || uast.TypeOf(obj) != "Expr" {
+		return false
+	}
+	return uast.TypeOf(obj["value"]) == "Str"
+}
+
+// takeDocNoops removes the comments of the docstring and returns them in order.
+func takeDocNoops(st nodes.Node) (nodes.Node, nodes.Array, bool) {
+	obj := st.(nodes.Object)
+	str, prev, same, changed := takeNoops(obj["value"].(nodes.Object))
+	if !changed {
+		return st, nil, false
+	}
+	obj = obj.CloneObject()
+	obj["value"] = str
+	return obj, append(prev, same...), true
+}
+
+// isAnnotationField checks if the field holds a type annotation, that is converted to
+// a type with no place for the comments if it's a name or a forward reference.
+func isAnnotationField(field string) bool {
+	return field == "returns" || field == "annotation"
+}
+
+func isStmtList(obj nodes.Object, field string) bool {
+	if _, ok := obj[field].(nodes.Array); !ok {
+		return false
+	}
+	for _, f := range stmtLists {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// dropEmptyNoops removes the noops at the end of the lines that have no comment, that
+// are the line breaks and the indentation after the node, like takeNoops does. The
+// noops with no lines left are removed too.
+var dropEmptyNoops = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	changed := false
+	for _, k := range noopKeys {
+		noops, ok := obj[k].(nodes.Object)
+		if !ok {
+			continue
+		}
+		key := "lines"
+		if k == "noops_sameline" {
+			key = "noop_lines"
+		}
+		lines, _ := noops[key].(nodes.Array)
+		out := nodes.Array{}
+		for _, l := range lines {
+			if l, ok := l.(nodes.Object); !ok || !isEmptyNoop(l) {
+				out = append(out, l)
+			}
+		}
+		if len(out) == len(lines) && len(out) != 0 {
+			continue
+		}
+		if !changed {
+			obj = obj.CloneObject()
+			changed = true
+		}
+		if len(out) == 0 {
+			delete(obj, k)
+			continue
+		}
+		noops = noops.CloneObject()
+		noops[key] = out
+		obj[k] = noops
+	}
+	return obj, changed, nil
+})
+
+func isEmptyNoop(line nodes.Object) bool {
+	return uast.TypeOf(line) == "NoopSameLine" && line["s"] == nodes.String("")
+}
+
+// trimNoopLines removes the indentation of the comment lines, because they are all
+// converted to the same uast:Comment, with no indentation. The lines that are not
+// comments are kept as is.
+var trimNoopLines = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	if uast.TypeOf(obj) != "NoopLine" {
+		return obj, false, nil
+	}
+	s, _ := obj["noop_line"].(nodes.String)
+	text := strings.TrimLeftFunc(string(s), unicode.IsSpace)
+	if text == string(s) || !strings.HasPrefix(text, "#") {
+		return obj, false, nil
+	}
+	obj = obj.CloneObject()
+	obj["noop_line"] = nodes.String(text)
+	return obj, true, nil
+})
+
+// foldNoopLines converts the comments at the end of the lines to NoopLine nodes, so
+// they have the same semantic form as the other comments.
+var foldNoopLines = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	if uast.TypeOf(obj) != "NoopSameLine" {
+		return obj, false, nil
+	}
+	line := nodes.Object{
+		uast.KeyType: nodes.String("NoopLine"),
+		"noop_line":  obj["s"],
+	}
+	copyPos(line, obj)
+	if owner, ok := obj["noops_owner"]; ok {
+		line["noops_owner"] = owner
+	}
+	return line, true, nil
+})
+
+// unfoldNoopLines converts the lines folded by foldNoopLines back to NoopSameLine
+// nodes: the lines of SameLineNoops, and the ones moved out of the "noops_sameline"
+// field of their owner.
+var unfoldNoopLines = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	switch uast.TypeOf(obj) {
+	case "SameLineNoops":
+		lines, _ := obj["noop_lines"].(nodes.Array)
+		var out nodes.Array
+		for i, l := range lines {
+			l, ok := l.(nodes.Object)
+			if !ok || uast.TypeOf(l) != "NoopLine" {
+				continue
+			}
+			if out == nil {
+				out = lines.CloneList()
+			}
+			out[i] = sameLineNoop(l)
+		}
+		if out == nil {
+			return obj, false, nil
+		}
+		obj = obj.CloneObject()
+		obj["noop_lines"] = out
+		return obj, true, nil
+	case "NoopLine":
+		owner, _ := obj["noops_owner"].(nodes.Object)
+		if owner["field"] != nodes.String("noops_sameline") {
+			return obj, false, nil
+		}
+		return sameLineNoop(obj), true, nil
+	}
+	return obj, false, nil
+})
+
+func sameLineNoop(line nodes.Object) nodes.Object {
+	obj := nodes.Object{
+		uast.KeyType: nodes.String("NoopSameLine"),
+		"s":          line["noop_line"],
+	}
+	copyPos(obj, line)
+	if owner, ok := line["noops_owner"]; ok {
+		obj["noops_owner"] = owner
+	}
+	return obj
+}
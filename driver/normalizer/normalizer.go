@@ -1,11 +1,11 @@
 package normalizer
 
 import (
+	"fmt"
+
 	"github.com/bblfsh/sdk/v3/uast"
 	"github.com/bblfsh/sdk/v3/uast/nodes"
-	"github.com/bblfsh/sdk/v3/uast/role"
 	. "github.com/bblfsh/sdk/v3/uast/transformer"
-	"github.com/bblfsh/sdk/v3/uast/transformer/positioner"
 )
 
 var Preprocess = Transformers([][]Transformer{
@@ -13,7 +13,13 @@ var Preprocess = Transformers([][]Transformer{
 }...)
 
 var PreprocessCode = []CodeTransformer{
-	positioner.FromLineCol(),
+	sourceTransformers{
+		forwardRefs{},
+		typeComments{},
+		sourcePositions{},
+		tokenPositions{},
+		numLiterals{},
+	},
 }
 
 var Preprocessors = []Mapping{
@@ -26,101 +32,187 @@ var Preprocessors = []Mapping{
 	}.Mapping(),
 }
 
-var Normalize = Transformers([][]Transformer{
-	{Mappings(Normalizers...)},
-}...)
+// normalizeSteps are the steps of Normalize that run before the Normalizers, in order,
+// with the transformation that undoes them on the AST given by the Normalizers running
+// backwards. The steps that cannot be undone, that have no undo, are:
+//
+//   - replaceForwardRefs: the string literals of the forward references are replaced
+//     by the expression they contain, so the quotes and the escapes are lost;
+//   - applyTypeComments: the types parsed from the type comments, that are still kept
+//     as comments, are set as the missing annotations;
+//   - foldSpreadArgs and foldPython2: the Python 2 nodes are converted to the Python 3
+//     ones, that have the same semantic form;
+//   - dropEmptyNoops: the noops at the end of the lines with no comment only hold the
+//     line breaks and the spaces, and the noops with no lines hold nothing;
+//   - trimNoopLines: uast:Comment has no place for the indentation of the line.
+var normalizeSteps = []struct {
+	do, undo Transformer
+}{
+	{do: moveDroppedNoops, undo: restoreDroppedNoops},
+	{do: replaceForwardRefs},
+	{do: applyTypeComments},
+	{do: decorators{}, undo: restoreDecorators},
+	{do: foldSpreadArgs},
+	{do: foldPython2},
+	{do: dropEmptyNoops},
+	{do: trimNoopLines},
+	{do: foldNoopLines, undo: unfoldNoopLines},
+}
+
+// OneWay are the steps of Normalize that ReverseNormalize cannot undo, in order. Running
+// them on the native AST gives the one that ReverseNormalize returns for its semantic
+// UAST.
+var OneWay = func() []Transformer {
+	var out []Transformer
+	for _, st := range normalizeSteps {
+		if st.undo == nil {
+			out = append(out, st.do)
+		}
+	}
+	return out
+}()
+
+var Normalize = func() []Transformer {
+	var out []Transformer
+	for _, st := range normalizeSteps {
+		out = append(out, st.do)
+	}
+	return append(out, Mappings(Normalizers...))
+}()
+
+// reverseNormalizers are the Normalizers running backwards.
+var reverseNormalizers = func() Transformer {
+	maps := make([]Mapping, 0, len(Normalizers))
+	for _, m := range Normalizers {
+		maps = append(maps, Reverse(m))
+	}
+	return Mappings(maps...)
+}()
+
+// ReverseNormalize converts the semantic UAST given by Normalize back to the native
+// AST, after the preprocessing and the steps in OneWay. The mappings run bottom-up, like
+// the normalization does, but the native nodes created backwards may have children in
+// the form that the other mappings give them, like the boxed strings of docstrings, so
+// they run again until nothing changes. The other steps of Normalize are undone after
+// them, in the reverse order.
+func ReverseNormalize(n nodes.Node) (nodes.Node, error) {
+	const maxPasses = 10
+	for i := 0; ; i++ {
+		if i == maxPasses {
+			return nil, fmt.Errorf("the reverse mappings changed the tree after %d passes", maxPasses)
+		}
+		nn, err := reverseNormalizers.Do(n)
+		if err != nil {
+			return nil, err
+		}
+		if nodes.Equal(nn, n) {
+			break
+		}
+		n = nn
+	}
+	for i := len(normalizeSteps) - 1; i >= 0; i-- {
+		undo := normalizeSteps[i].undo
+		if undo == nil {
+			continue
+		}
+		var err error
+		if n, err = undo.Do(n); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
 
 func funcDefMap(typ string, async bool) Mapping {
 	return MapSemantic(typ, uast.FunctionGroup{}, MapObj(
-		Fields{
-			{Name: "body", Op: Var("body")},
+		append(Fields{
+			{Name: "body", Op: OpIsGenerator{vr: "generator", op: docBody(Var("body"))}},
 			{Name: "name", Op: Var("name")},
+			{Name: "name_pos", Op: Var("name_pos")},
 			// Arguments should be converted by the uast.Arguments normalization
-			{Name: "args", Op: Obj{
-				"args":       Var("arguments"),
-				uast.KeyPos:  Var("_pos"),
-				uast.KeyType: Var("_type"),
+			{Name: "args", Op: Fields{
+				{Name: "args", Op: funcArgs.Native},
+				// only set if there are positional-only arguments (Python 3.8)
+				{Name: "posonlyargcount", Optional: "posonly_opt", Op: Var("posonly")},
+				// the arguments have no position
+				{Name: uast.KeyPos, Op: UASTType(uast.Positions{}, nil)},
+				{Name: uast.KeyType, Op: String("arguments")},
 			}},
-			// Will be filled only if there is a Python3 type annotation. If the field is
-			// missing or the value is nil it DOESNT mean that the function returns None,
-			// just that there is no annotation (but the function could still return some
-			// other type!).
-			{Name: "returns", Optional: "ret_opt", Op: Cases("ret_case",
+			// Will be filled only if there is a Python3 type annotation. If the value is
+			// nil it DOESNT mean that the function returns None, just that there is no
+			// annotation (but the function could still return some other type!).
+			{Name: "returns", Op: Cases("ret_case",
 				// case 1: no type annotations for the return
 				Is(nil),
 				// case 2: boxed identifier
 				Fields{
 					{Name: uast.KeyType, Op: String("BoxedName")},
 					{Name: "boxed_value", Op: Var("ret_type")},
-					// No problem dropping this one, it's used by an internal interpreter optimization/cache
-					// without semantic meaning
-					{Name: "ctx", Op: Any()},
-					// FIXME: change this once we've a way to store other nodes on semantic objects
-					// See: https://github.com/bblfsh/sdk/issues/361
-					// See: https://github.com/bblfsh/python-driver/issues/178
-					{Name: "noops_previous", Drop: true, Op: Any()},
-					{Name: "noops_sameline", Drop: true, Op: Any()},
+					// the annotations are always loaded
+					{Name: "ctx", Op: String("Load")},
 				},
 				// case 3: everything else
-				// TODO: not reversible
 				Var("ret_type"),
 			)},
 			{Name: "decorator_list", Op: Var("func_decorators")},
+			// set by applyTypeComments
+			{Name: "returns_type_comment", Optional: "returns_type_comment_opt", Op: Var("returns_type_comment")},
 			{Name: "noops_previous", Optional: "np_opt", Op: Var("noops_previous")},
 			{Name: "noops_sameline", Optional: "ns_opt", Op: Var("noops_sameline")},
-		},
+		}, decoratorFields...),
 		Obj{
 			"Nodes": Arr(
-				Fields{
-					// FIXME: generator=true if it uses yield anywhere in the body
+				append(Fields{
 					{Name: "async", Op: Bool(async)},
+					// async generators are async functions that are also generators
+					{Name: "generator", Op: Var("generator")},
 					{Name: "decorators", Op: Var("func_decorators")},
+					docField,
 					{Name: "comments", Op: Fields{
 						{Name: "noops_previous", Optional: "np_opt", Op: Var("noops_previous")},
 						{Name: "noops_sameline", Optional: "ns_opt", Op: Var("noops_sameline")},
 					}},
-				},
+				}, decoratorFields...),
 				UASTType(uast.Alias{}, Obj{
-					// FIXME: can't call identifierWithPos because it would take the position of the
-					//        function node that is not exactly the same as the position of the function name
 					"Name": UASTType(uast.Identifier{}, Obj{
-						"Name": Var("name"),
+						uast.KeyPos: Var("name_pos"),
+						"Name":      Var("name"),
 					}),
 					"Node": UASTType(uast.Function{}, Obj{
-						"Type": UASTType(uast.FunctionType{}, Fields{
-							{Name: "Arguments", Op: Var("arguments")},
-							{Name: "Returns", Optional: "ret_opt", Op: Cases("ret_case",
+						"Type": withPosonlyCount(UASTType(uast.FunctionType{}, Fields{
+							{Name: "Arguments", Op: funcArgs.Semantic},
+							{Name: "Returns", Op: Cases("ret_case",
 								// Python always adds an implicit return of None if function
 								// returns nothing, so we will set Init on our returns to None.
 
 								// case 1: no type annotations for the return
-								Arr(UASTType(uast.Argument{},
+								Arr(withTypeComment("returns_type_comment", UASTType(uast.Argument{},
 									Obj{
 										"Init": UASTType(uast.Identifier{},
 											Obj{"Name": String("None")},
 										),
 									},
-								)),
+								))),
 								// case 2: boxed identifier
-								Arr(UASTType(uast.Argument{},
+								Arr(withTypeComment("returns_type_comment", UASTType(uast.Argument{},
 									Obj{
-										"Type": Var("ret_type"),
+										"Type": isIdentifier(Var("ret_type")),
 										"Init": UASTType(uast.Identifier{},
 											Obj{"Name": String("None")},
 										),
 									},
-								)),
+								))),
 								// case 3: everything else
-								Arr(UASTType(uast.Argument{},
+								Arr(withTypeComment("returns_type_comment", UASTType(uast.Argument{},
 									Obj{
 										"Type": Var("ret_type"),
 										"Init": UASTType(uast.Identifier{},
 											Obj{"Name": String("None")},
 										),
 									},
-								)),
+								))),
 							)},
-						}),
+						})),
 						"Body": UASTType(uast.Block{}, Obj{
 							"Statements": Var("body"),
 						}),
@@ -131,16 +223,237 @@ func funcDefMap(typ string, async bool) Mapping {
 	))
 }
 
+// withPosonlyCount adds to the type of a function or lambda the number of its
+// positional-only arguments (Python 3.8), that are the first ones and are marked with
+// "positional_only". It is only set if there are any. The fields are not in the UAST
+// schema, like the bindings of the identifiers, so they are joined after the type is
+// checked.
+func withPosonlyCount(typ ObjectOp) ObjectOp {
+	return JoinObj(typ, Fields{
+		{Name: "posonlyargcount", Optional: "posonly_opt", Op: Var("posonly")},
+	})
+}
+
+// withTypeComment adds to a semantic argument the "type_comment" field, that is set to
+// true if its type comes from a type comment (see applyTypeComments). The variable
+// is the one of the native field. Like the count of positional-only arguments, the
+// field is not in the UAST schema.
+func withTypeComment(vr string, typ ObjectOp) ObjectOp {
+	return JoinObj(typ, Fields{
+		{Name: "type_comment", Optional: vr + "_opt", Op: Var(vr)},
+	})
+}
+
+// argTypeMap adds the "type_comment" field to the semantic side of an argument
+// mapping.
+func argTypeMap(m ObjMapping) ObjMapping {
+	so, do := m.ObjMapping()
+	return MapObj(so, withTypeComment("type_comment", do))
+}
+
+// argMap converts an argument of the given type, with the extra fields of the
+// semantic argument.
+func argMap(typ string, ext ...Field) ObjMapping {
+	so, do := argTypeMap(MapSemantic(typ, uast.Argument{}, MapObj(
+		Fields{
+			{Name: uast.KeyToken, Op: Var("name")},
+			{Name: "type_comment", Optional: "type_comment_opt", Op: Var("type_comment")},
+			{Name: "default", Optional: "opt_def", Op: Var("init")},
+			// the Python 2 arguments are folded by foldPython2
+			{Name: "annotation", Op: annotationType.Native},
+		},
+		Fields{
+			{Name: "Name", Op: identifierWithPos("name")},
+			{Name: "Init", Optional: "opt_def", Op: Var("init")},
+			{Name: "Type", Op: annotationType.Semantic},
+		},
+	))).ObjMapping()
+	if len(ext) == 0 {
+		return MapObj(so, do)
+	}
+	return MapObj(so, JoinObj(do, Fields(ext)))
+}
+
+// argMappings are the mappings of the arguments of functions and lambdas. The
+// positional-only and keyword-only arguments go first, so the other ones don't match
+// their semantic form when they run backwards.
+var argMappings = []ObjMapping{
+	// see withPosonlyCount
+	argMap("posonly_arg", Field{Name: "positional_only", Op: Bool(true)}),
+	argMap("kwonly_arg", Field{Name: "keyword_only", Op: Bool(true)}),
+	argMap("arg"),
+
+	argTypeMap(MapSemantic("vararg", uast.Argument{}, MapObj(
+		Fields{
+			{Name: uast.KeyToken, Op: Var("name")},
+			{Name: "type_comment", Optional: "type_comment_opt", Op: Var("type_comment")},
+			{Name: "annotation", Op: annotationType.Native},
+		},
+		Obj{
+			"Name":     identifierWithPos("name"),
+			"Type":     annotationType.Semantic,
+			"Variadic": Bool(true),
+		},
+	))),
+
+	argTypeMap(MapSemantic("kwarg", uast.Argument{}, MapObj(
+		Fields{
+			{Name: uast.KeyToken, Op: Var("name")},
+			{Name: "type_comment", Optional: "type_comment_opt", Op: Var("type_comment")},
+			{Name: "annotation", Op: annotationType.Native},
+		},
+		Obj{
+			"Name":        identifierWithPos("name"),
+			"Type":        annotationType.Semantic,
+			"MapVariadic": Bool(true),
+		},
+	))),
+}
+
+// funcArgs converts the arguments of functions and lambdas with argMappings. Like the
+// keywords of calls, they are only converted as a part of their function, so the
+// reverse transformation doesn't confuse the arguments with default values with the
+// keywords.
+var funcArgs = func() (ops struct{ Native, Semantic Op }) {
+	var native, semantic []Op
+	for _, m := range argMappings {
+		so, do := m.ObjMapping()
+		native = append(native, so)
+		semantic = append(semantic, do)
+	}
+	ops.Native = Each("arguments", Cases("arg_type", native...))
+	ops.Semantic = Each("arguments", Cases("arg_type", semantic...))
+	return ops
+}()
+
 func identifierWithPos(nameVar string) ObjectOp {
 	return UASTType(uast.Identifier{}, Obj{
-		uast.KeyPos: UASTType(uast.Positions{}, Obj{
-			uast.KeyStart: Var(uast.KeyStart),
-			uast.KeyEnd:   Var(uast.KeyEnd),
-		}),
-		"Name": Var(nameVar),
+		uast.KeyPos: positions(),
+		"Name":      Var(nameVar),
 	})
 }
 
+// positions matches the positions of a node, with the same variables as MapSemantic,
+// so the identifiers of the mapped nodes can have the same positions. The start and
+// end positions are optional.
+func positions() ObjectOp {
+	return UASTType(uast.Positions{}, Fields{
+		{Name: uast.KeyStart, Op: Var(uast.KeyStart), Optional: uast.KeyStart + "_exists"},
+		{Name: uast.KeyEnd, Op: Var(uast.KeyEnd), Optional: uast.KeyEnd + "_exists"},
+	})
+}
+
+// annotationType converts the type annotations of arguments and variables to their
+// type, unboxing the names.
+var annotationType = struct {
+	Native, Semantic Op
+}{
+	Native: Cases("ann_case",
+		Is(nil),
+		Check(Has{uast.KeyType: String("BoxedName")}, Fields{
+			{Name: uast.KeyType, Op: String("BoxedName")},
+			{Name: "boxed_value", Op: Var("ann")},
+			// the annotations are always loaded
+			{Name: "ctx", Op: String("Load")},
+		}),
+		Var("ann"),
+	),
+	Semantic: Cases("ann_case",
+		Is(nil),
+		isIdentifier(Var("ann")),
+		Var("ann"),
+	),
+}
+
+// isIdentifier checks that the node is a uast:Identifier, like the unboxed names, so
+// the reverse transformation boxes them again and only them.
+func isIdentifier(op Op) Op {
+	return Check(Has{uast.KeyType: String(uast.TypeOf(uast.Identifier{}))}, op)
+}
+
+// importAlias converts the alias nodes of import statements. Aliases are only
+// converted as a part of the import statement, so the reverse transformation
+// doesn't confuse other identifiers with import paths.
+var importAlias = struct {
+	Native, Semantic Op
+}{
+	Native: Cases("alias_case",
+		// aliases with no alias are useless, only the path is kept
+		Obj{
+			uast.KeyType: String("alias"),
+			uast.KeyPos:  Var("name_pos"),
+			"name":       Check(OfKind(nodes.KindString), Var("name")),
+			"name_pos":   Var("name_pos"),
+			"asname":     Is(nil),
+			"asname_pos": UASTType(uast.Positions{}, nil),
+		},
+		Obj{
+			uast.KeyType: String("alias"),
+			uast.KeyPos:  Var("alias_pos"),
+			"name":       Check(OfKind(nodes.KindString), Var("name")),
+			"name_pos":   Var("name_pos"),
+			"asname":     Check(OfKind(nodes.KindString), Var("alias")),
+			"asname_pos": Var("asname_pos"),
+		},
+	),
+	Semantic: Cases("alias_case",
+		OpSplitPath{
+			path: Var("name"),
+			pos:  Var("name_pos"),
+		},
+		UASTType(uast.Alias{}, Obj{
+			uast.KeyPos: Var("alias_pos"),
+			"Name": UASTType(uast.Identifier{}, Obj{
+				uast.KeyPos: Var("asname_pos"),
+				"Name":      Var("alias"),
+			}),
+			"Node": OpSplitPath{
+				path: Var("name"),
+				pos:  Var("name_pos"),
+			},
+		}),
+	),
+}
+
+// docBody matches a list of statements, taking the docstring out of it if the first
+// statement is a string literal. The "doc_opt" variable is set if there is one, so
+// docField can be used to store it in the semantic node.
+func docBody(body Op) Op {
+	return If("doc_opt",
+		PrependOne(Fields{
+			{Name: uast.KeyType, Op: String("Expr")},
+			{Name: uast.KeyPos, Op: Var("doc_stmt_pos")},
+			{Name: "value", Op: Fields{
+				{Name: uast.KeyType, Op: String("BoxedStr")},
+				{Name: "boxed_value", Op: UASTType(uast.String{}, Obj{
+					uast.KeyPos: Var("doc_pos"),
+					"Value":     OpDocstring{vr: "doc"},
+				})},
+			}},
+		}, body),
+		body,
+	)
+}
+
+// docField is the field with the docstring taken by docBody, as a block comment. The
+// position of the statement, that is not the one of the string for the multiline
+// docstrings, is kept in the "statement_pos" field, that is not in the UAST schema.
+var docField = Field{Name: "docstring", Optional: "doc_opt", Op: JoinObj(
+	CommentNode(true, "doc", Var("doc_pos")),
+	Obj{"statement_pos": Var("doc_stmt_pos")},
+)}
+
+// decoratorFields are the qualified names of the decorators of a function and the
+// flags of the well-known ones, set by the decorators transformer. They are kept in
+// the same fields in the first node of the function group.
+var decoratorFields = func() Fields {
+	fields := Fields{{Name: "decorator_names", Op: Var("decorator_names")}}
+	for _, f := range decoratorFlags {
+		fields = append(fields, Field{Name: f, Op: Var("dec_" + f)})
+	}
+	return fields
+}()
+
 // mapStr factorizes the common annotation for string types (Byte, Str, StrLiteral)
 func mapStr(nativeType string) Mapping {
 	return Map(
@@ -213,10 +526,6 @@ var Normalizers = []Mapping{
 			{Name: uast.KeyType, Op: String("Attribute")},
 			{Name: uast.KeyPos, Op: Var("pos_")},
 			{Name: "attr", Op: Var("aname")},
-			// No problem dropping this one, it's used by an internal interpreter optimization
-			// cache without semantic meaning
-			// without semantic meaning
-			{Name: "ctx", Op: Any()},
 			{Name: "noops_previous", Optional: "np_opt", Op: Var("noops_previous")},
 			{Name: "noops_sameline", Optional: "ns_opt", Op: Var("noops_sameline")},
 		}),
@@ -235,234 +544,311 @@ var Normalizers = []Mapping{
 	mapStr("Str"),
 	mapStr("StringLiteral"),
 
-	MapSemantic("NoopLine", uast.Comment{}, MapObj(
-		Obj{
-			"noop_line": CommentTextTrimmed([2]string{"#", ""}, "comm"),
-		},
-		CommentNode(false, "comm", nil),
-	)),
-
-	MapSemantic("NoopSameLine", uast.Comment{}, MapObj(
-		Obj{
-			"s": CommentText([2]string{"#", ""}, "comm"),
-		},
-		CommentNode(false, "comm", nil),
-	)),
+	// Numeric literals use their spelling in the source code as the token, with the
+	// kind and the typed value of the number in separate fields. The native value is
+	// restored from them.
+	Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Num")},
+			{Name: "literal", Op: OpNumLiteral{kind: "kind", value: "value", op: Var("literal")}},
+			{Name: "n", Op: OpNumNative{kind: "kind", value: "value"}},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Num")},
+			{Name: "n", Op: OpNumLiteral{kind: "kind", value: "value", op: Var("literal")}},
+			{Name: "kind", Op: Var("kind")},
+			{Name: "value", Op: Var("value")},
+		}),
+	),
+	// The numbers without a literal (like the ones in f-strings) keep the native value
+	// as the token, and the kind and value are taken from it
+	Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Num")},
+			{Name: "n", Op: OpNumValue{kind: "kind", value: "value", op: Var("n")}},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Num")},
+			{Name: "n", Op: OpNumValue{kind: "kind", value: "value", op: Var("n")}},
+			{Name: "kind", Op: Var("kind")},
+			{Name: "value", Op: Var("value")},
+		}),
+	),
 
-	// remove empty noops
-	Map(Obj{
-		uast.KeyType: String("NoopSameLine"),
-		uast.KeyPos:  Any(),
-		"s":          String(""),
-	}, Is(nil)),
-	Map(Obj{
-		uast.KeyType: String("SameLineNoops"),
-		uast.KeyPos:  Any(),
-		"noop_lines": Check(All(Is(nil)), Any()),
-	}, Is(nil)),
-
-	// FIXME: no positions for keywords in the native AST
-	AnnotateType("keyword", MapObj(
+	// the comments at the end of the lines are folded to NoopLine by foldNoopLines, and
+	// the ones moved by moveDroppedNoops keep their owner, that is not in the UAST schema
+	Map(
 		Fields{
-			{Name: "arg", Op: Var("name")},
-			// FIXME: change this once we've a way to store other nodes on semantic objects
-			// See: https://github.com/bblfsh/sdk/issues/361
-			// See: https://github.com/bblfsh/python-driver/issues/178
-			{Name: "noops_previous", Drop: true, Op: Any()},
-			{Name: "noops_sameline", Drop: true, Op: Any()},
+			{Name: uast.KeyType, Op: String("NoopLine")},
+			{Name: uast.KeyPos, Op: positions()},
+			{Name: "noop_line", Op: CommentTextTrimmed([2]string{"#", ""}, "comm")},
+			{Name: "noops_owner", Optional: "owner_opt", Op: Var("noops_owner")},
 		},
-		Fields{
-			{Name: "arg",
-				Op: UASTType(uast.Identifier{}, Obj{
-					"Name": Var("name"),
-				})},
+		JoinObj(CommentNode(false, "comm", positions()), Fields{
+			{Name: "noops_owner", Optional: "owner_opt", Op: Var("noops_owner")},
 		}),
-		role.Name),
+	),
 
-	MapSemantic("arg", uast.Argument{}, MapObj(
-		Fields{
-			{Name: uast.KeyToken, Op: Var("name")},
-			{Name: "default", Optional: "opt_def", Op: Var("init")},
-			// No problem dropping this one, it's used by an internal interpreter optimization/cache
-			// without semantic meaning
-			{Name: "ctx", Optional: "opt_ctx", Op: Any()},
-			// FIXME: change this once we've a way to store other nodes on semantic objects
-			// See: https://github.com/bblfsh/sdk/issues/361
-			// See: https://github.com/bblfsh/python-driver/issues/178
-			{Name: "noops_previous", Drop: true, Op: Any()},
-			{Name: "noops_sameline", Drop: true, Op: Any()},
-			// This one is pesky - they're ignored by the runtime, could have typing from
-			// mypy, or could have anything else, so we can assign to the semantic type
-			{Name: "annotation", Optional: "ann_opt", Op: Any()},
-		},
-		Fields{
-			{Name: "Name", Op: identifierWithPos("name")},
-			{Name: "Init", Optional: "opt_def", Op: Var("init")},
-		},
-	)),
+	// Calls keep their Python type, with the function in the "callee" field and the
+	// arguments converted to uast:Argument nodes. The spread arguments of Python 2 are
+	// moved to the arguments by foldSpreadArgs.
+	Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Call")},
+			{Name: "func", Op: Var("callee")},
+			{Name: "args", Op: callArgs.Native},
+			{Name: "keywords", Op: keywordArgs.Native},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Call")},
+			{Name: "callee", Op: Var("callee")},
+			{Name: "args", Op: callArgs.Semantic},
+			{Name: "keywords", Op: keywordArgs.Semantic},
+		}),
+	),
 
-	MapSemantic("kwonly_arg", uast.Argument{}, MapObj(
-		Fields{
-			{Name: uast.KeyToken, Op: Var("name")},
-			{Name: "default", Op: Var("init")},
-			// FIXME: change this once we've a way to store other nodes on semantic objects
-			// See: https://github.com/bblfsh/sdk/issues/361
-			// See: https://github.com/bblfsh/python-driver/issues/178
-			{Name: "noops_previous", Drop: true, Op: Any()},
-			{Name: "noops_sameline", Drop: true, Op: Any()},
-			// This one is pesky - they're ignored by the runtime, could have typing from
-			// mypy, or could have anything else, so we can assign to the semantic type
-			{Name: "annotation", Op: Any()},
-		},
-		Obj{
-			"Init": Var("init"),
-			"Name": identifierWithPos("name"),
-		},
-	)),
+	funcDefMap("FunctionDef", false),
+	funcDefMap("AsyncFunctionDef", true),
 
-	MapSemantic("vararg", uast.Argument{}, MapObj(
+	// Lambdas are anonymous functions with a single expression as the body, that is
+	// returned implicitly, so they are emitted as a Function with a block returning it.
+	// The comments of the lambda are kept in the return statement.
+	MapSemantic("Lambda", uast.Function{}, MapObj(
 		Fields{
-			{Name: uast.KeyToken, Op: Var("name")},
-			// FIXME: change this once we've a way to store other nodes on semantic objects
-			// See: https://github.com/bblfsh/sdk/issues/361
-			// See: https://github.com/bblfsh/python-driver/issues/178
-			{Name: "noops_previous", Drop: true, Op: Any()},
-			{Name: "noops_sameline", Drop: true, Op: Any()},
-			// This one is pesky - they're ignored by the runtime, could have typing from
-			// mypy, or could have anything else, so we can assign to the semantic type
-			{Name: "annotation", Op: Any()},
+			{Name: "args", Op: Fields{
+				{Name: "args", Op: funcArgs.Native},
+				{Name: "posonlyargcount", Optional: "posonly_opt", Op: Var("posonly")},
+				// the arguments have no position
+				{Name: uast.KeyPos, Op: UASTType(uast.Positions{}, nil)},
+				{Name: uast.KeyType, Op: String("arguments")},
+			}},
+			{Name: "body", Op: Var("body")},
+			{Name: "noops_previous", Optional: "np_opt", Op: Var("noops_previous")},
+			{Name: "noops_sameline", Optional: "ns_opt", Op: Var("noops_sameline")},
 		},
 		Obj{
-			"Name":     identifierWithPos("name"),
-			"Variadic": Bool(true),
+			"Type": withPosonlyCount(UASTType(uast.FunctionType{}, Obj{
+				"Arguments": funcArgs.Semantic,
+			})),
+			"Body": UASTType(uast.Block{}, Obj{
+				"Statements": Arr(Fields{
+					{Name: uast.KeyType, Op: String("Return")},
+					{Name: "value", Op: Var("body")},
+					{Name: "noops_previous", Optional: "np_opt", Op: Var("noops_previous")},
+					{Name: "noops_sameline", Optional: "ns_opt", Op: Var("noops_sameline")},
+				}),
+			}),
 		},
 	)),
 
-	MapSemantic("kwarg", uast.Argument{}, MapObj(
+	// Classes are emitted as a Group with the Python-specific parts (bases, keywords
+	// including the metaclass, decorators and comments) in the first node and an Alias
+	// binding the class name to its body in the second one, mirroring the FunctionGroup
+	// emitted for functions.
+	MapSemantic("ClassDef", uast.Group{}, MapObj(
 		Fields{
-			{Name: uast.KeyToken, Op: Var("name")},
-			// FIXME: change this once we've a way to store other nodes on semantic objects
-			// See: https://github.com/bblfsh/sdk/issues/361
-			// See: https://github.com/bblfsh/python-driver/issues/178
-			{Name: "noops_previous", Drop: true, Op: Any()},
-			{Name: "noops_sameline", Drop: true, Op: Any()},
-			// This one is pesky - they're ignored by the runtime, could have typing from
-			// mypy, or could have anything else, so we can assign to the semantic type
-			{Name: "annotation", Op: Any()},
+			{Name: "name", Op: Var("name")},
+			{Name: "name_pos", Op: Var("name_pos")},
+			{Name: "body", Op: docBody(Var("body"))},
+			{Name: "bases", Op: Var("bases")},
+			// Python 2 classes have no keywords
+			{Name: "keywords", Optional: "kw_opt", Op: keywordArgs.Native},
+			{Name: "decorator_list", Op: Var("decorators")},
+			{Name: "decorator_names", Op: Var("decorator_names")},
+			{Name: "noops_previous", Optional: "np_opt", Op: Var("noops_previous")},
+			{Name: "noops_sameline", Optional: "ns_opt", Op: Var("noops_sameline")},
 		},
 		Obj{
-			"Name":        identifierWithPos("name"),
-			"MapVariadic": Bool(true),
+			"Nodes": Arr(
+				Fields{
+					{Name: "bases", Op: Var("bases")},
+					{Name: "keywords", Optional: "kw_opt", Op: keywordArgs.Semantic},
+					{Name: "decorators", Op: Var("decorators")},
+					{Name: "decorator_names", Op: Var("decorator_names")},
+					docField,
+					{Name: "comments", Op: Fields{
+						{Name: "noops_previous", Optional: "np_opt", Op: Var("noops_previous")},
+						{Name: "noops_sameline", Optional: "ns_opt", Op: Var("noops_sameline")},
+					}},
+				},
+				UASTType(uast.Alias{}, Obj{
+					"Name": UASTType(uast.Identifier{}, Obj{
+						uast.KeyPos: Var("name_pos"),
+						"Name":      Var("name"),
+					}),
+					"Node": UASTType(uast.Block{}, Obj{
+						"Statements": Var("body"),
+					}),
+				}),
+			),
 		},
 	)),
 
-	funcDefMap("FunctionDef", false),
-	funcDefMap("AsyncFunctionDef", true),
+	// the type comments of assignments are types too
+	Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Assign")},
+			{Name: "type_comment", Op: annotationType.Native},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Assign")},
+			{Name: "type_comment", Op: annotationType.Semantic},
+		}),
+	),
+
+	// Assignment expressions (Python 3.8) are converted to an Assign node too, that is
+	// an expression: it has the "expression" field, that other assignments don't have.
+	Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("NamedExpr")},
+			{Name: "target", Op: Var("target")},
+			{Name: "value", Op: Var("value")},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Assign")},
+			{Name: "targets", Op: Arr(Var("target"))},
+			{Name: "value", Op: Var("value")},
+			{Name: "operator", Op: Is(nil)},
+			{Name: "annotation", Op: Is(nil)},
+			{Name: "expression", Op: Bool(true)},
+		}),
+	),
+
+	// All the assignments are converted to an Assign node with the list of targets
+	// (more than one for chained assignments like "a = b = 1"), the value, the
+	// operator of augmented assignments and the type of annotated ones. The targets of
+	// destructuring assignments are kept as tuples or lists, with the starred target
+	// taking the rest of the values.
+	Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Assign")},
+			{Name: "targets", Op: Var("targets")},
+			{Name: "value", Op: Var("value")},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Assign")},
+			{Name: "targets", Op: Var("targets")},
+			{Name: "value", Op: Var("value")},
+			{Name: "operator", Op: Is(nil)},
+			{Name: "annotation", Op: Is(nil)},
+		}),
+	),
+	Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("AugAssign")},
+			{Name: "target", Op: Var("target")},
+			{Name: "op", Op: Var("op")},
+			{Name: "value", Op: Var("value")},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Assign")},
+			{Name: "targets", Op: Arr(Var("target"))},
+			{Name: "value", Op: Var("value")},
+			{Name: "operator", Op: Check(NotNil(), Var("op"))},
+			{Name: "annotation", Op: Is(nil)},
+		}),
+	),
+	// annotated assignments declare the type of the variable, and may have no value
+	Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("AnnAssign")},
+			{Name: "target", Op: Var("target")},
+			{Name: "annotation", Op: annotationType.Native},
+			{Name: "value", Op: Var("value")},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Assign")},
+			{Name: "targets", Op: Arr(Var("target"))},
+			{Name: "value", Op: Var("value")},
+			{Name: "operator", Op: Is(nil)},
+			{Name: "annotation", Op: Check(NotNil(), annotationType.Semantic)},
+		}),
+	),
+
+	// Control flow statements, see controlflow.go
+	ifMap(),
+	forMap("For", false),
+	forMap("AsyncFor", true),
+	whileMap(),
+	tryMap(),
+	exceptHandlerMap(),
+	withMap("With", false),
+	withMap("AsyncWith", true),
+
+	// the module docstring is stored in the module node
+	Map(
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Module")},
+			{Name: "body", Op: docBody(Var("body"))},
+		}),
+		Part("_", Fields{
+			{Name: uast.KeyType, Op: String("Module")},
+			{Name: "body", Op: Var("body")},
+			docField,
+		}),
+	),
 
 	// import statements may have multiple paths
 	// if there is only one path, we emit RuntimeImport directly
 	MapSemantic("Import", uast.RuntimeImport{}, MapObj(
 		Fields{
-			// FIXME: change this once we've a way to store other nodes on semantic objects
-			// See: https://github.com/bblfsh/sdk/issues/361
-			// See: https://github.com/bblfsh/python-driver/issues/178
-			{Name: "noops_previous", Drop: true, Op: Any()},
-			{Name: "noops_sameline", Drop: true, Op: Any()},
-			{Name: "names", Op: One(Var("name"))},
+			{Name: "names", Op: One(importAlias.Native)},
 		},
 		Obj{
-			"Path": Var("name"),
+			"Path": importAlias.Semantic,
 		},
 	)),
 	// for grouped statement, we emit a Group with multiple RuntimeImports
 	MapSemantic("Import", uast.Group{}, MapObj(
 		Fields{
-			// FIXME: change this once we've a way to store other nodes on semantic objects
-			// See: https://github.com/bblfsh/sdk/issues/361
-			// See: https://github.com/bblfsh/python-driver/issues/178
-			{Name: "noops_previous", Drop: true, Op: Any()},
-			{Name: "noops_sameline", Drop: true, Op: Any()},
-			{Name: "names", Op: Each("vals", Var("name"))},
+			{Name: "names", Op: Each("vals", importAlias.Native)},
 		},
 		Obj{
 			"Nodes": Each("vals", UASTType(uast.RuntimeImport{},
-				Obj{"Path": Var("name")},
+				Obj{"Path": importAlias.Semantic},
 			)),
 		},
 	)),
 
-	// pre-process aliases: remove ones with no alias (useless)
-	Map(Obj{
-		uast.KeyType: String("alias"),
-		uast.KeyPos:  Any(),
-		"name":       Check(OfKind(nodes.KindString), Var("name")),
-		"asname":     Is(nil),
-	}, OpSplitPath{
-		path: Var("name"),
-	}),
-
-	// FIXME: aliases doesn't have a position (can't be currently fixed by the tokenizer
-	//        because they don't even have a line in the native AST)
-	MapSemantic("alias", uast.Alias{}, MapObj(
-		Obj{
-			"name":   Var("name"),
-			"asname": Var("alias"),
-		},
-		Obj{
-			"Name": UASTType(uast.Identifier{}, Obj{
-				uast.KeyPos: UASTType(uast.Positions{}, nil),
-				"Name":      Var("alias"),
-			}),
-			"Node": OpSplitPath{
-				path: Var("name"),
-			},
-		},
-	)),
-
 	// Star imports
 	MapSemantic("ImportFrom", uast.RuntimeImport{}, MapObj(
 		Fields{
 			{Name: "names", Op: Arr(
-				Obj{
-					uast.KeyType: String("uast:Identifier"),
-					uast.KeyPos:  Any(),
-					"Name":       String("*"),
-				},
+				// check the name first, other aliases have positions
+				Check(Has{"name": String("*")}, Obj{
+					uast.KeyType: String("alias"),
+					uast.KeyPos:  UASTType(uast.Positions{}, nil),
+					"name":       String("*"),
+					"asname":     Is(nil),
+				}),
 			)},
 			{Name: "level", Op: Var("level")},
 			{Name: "module", Op: Var("module")},
-			// FIXME: change this once we've a way to store other nodes on semantic objects
-			// See: https://github.com/bblfsh/sdk/issues/361
-			// See: https://github.com/bblfsh/python-driver/issues/178
-			{Name: "noops_previous", Drop: true, Op: Any()},
-			{Name: "noops_sameline", Drop: true, Op: Any()},
+			{Name: "module_pos", Op: Var("module_pos")},
 		},
 		Obj{
 			"All": Bool(true),
 			"Path": OpSplitPath{
-				// FIXME: no position for the module (path) in the native AST, only when the import starts
 				numLevel: Var("level"),
 				path:     Var("module"),
+				pos:      Var("module_pos"),
 			},
 		},
 	)),
 
 	MapSemantic("ImportFrom", uast.RuntimeImport{}, MapObj(
 		Fields{
-			{Name: "names", Op: Var("names")},
+			{Name: "names", Op: Each("names", importAlias.Native)},
 			{Name: "module", Op: Var("module")},
+			{Name: "module_pos", Op: Var("module_pos")},
 			{Name: "level", Op: Var("level")},
-			// FIXME: change this once we've a way to store other nodes on semantic objects
-			// See: https://github.com/bblfsh/sdk/issues/361
-			// See: https://github.com/bblfsh/python-driver/issues/178
-			{Name: "noops_previous", Drop: true, Op: Any()},
-			{Name: "noops_sameline", Drop: true, Op: Any()},
 		},
 		Obj{
-			"Names": Var("names"),
+			"Names": Each("names", importAlias.Semantic),
 			"Path": OpSplitPath{
 				numLevel: Var("level"),
 				path:     Var("module"),
+				pos:      Var("module_pos"),
 			},
 		},
 	)),
@@ -0,0 +1,439 @@
+package normalizer
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/bblfsh/python-driver/driver/parser"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+)
+
+// source is the code of a request, that is decoded and tokenized on first use, so
+// the code transformers run by sourceTransformers share them.
+type source struct {
+	code string
+
+	decoded   *parser.Source
+	decodeErr error
+	index     *tokenIndex
+}
+
+// decode returns the decoded code, decoding it on the first call.
+func (s *source) decode() (*parser.Source, error) {
+	if s.decoded == nil && s.decodeErr == nil {
+		s.decoded, s.decodeErr = parser.Decode(s.code)
+	}
+	return s.decoded, s.decodeErr
+}
+
+// text returns the code decoded to UTF-8, or the code as is if it cannot be decoded.
+func (s *source) text() string {
+	if src, err := s.decode(); err == nil {
+		return src.Text
+	}
+	return s.code
+}
+
+// tokens returns the tokens of the code, tokenizing it on the first call. The index
+// is empty if the code cannot be tokenized, because the transformations using it are
+// best-effort.
+func (s *source) tokens() *tokenIndex {
+	if s.index == nil {
+		var toks []parser.Token
+		if src, err := s.decode(); err == nil {
+			toks, _ = src.Tokens()
+		}
+		s.index = indexTokens(toks)
+	}
+	return s.index
+}
+
+// sourceTransformer is a code transformer that uses the decoded or tokenized code.
+type sourceTransformer interface {
+	onSource(src *source) Transformer
+}
+
+// sourceTransformers runs the transformers in order, sharing a single source for each
+// request, so the code is decoded and tokenized at most once.
+type sourceTransformers []sourceTransformer
+
+var _ CodeTransformer = sourceTransformers{}
+
+func (ts sourceTransformers) OnCode(code string) Transformer {
+	return sourceRun{src: &source{code: code}, list: ts}
+}
+
+// sourceRun runs the transformers of sourceTransformers on the AST of the source.
+type sourceRun struct {
+	src  *source
+	list sourceTransformers
+}
+
+func (r sourceRun) Do(n nodes.Node) (nodes.Node, error) {
+	for _, t := range r.list {
+		var err error
+		if n, err = t.onSource(r.src).Do(n); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// sourcePositions fills the offsets of the positions from their lines and columns.
+// The columns of the native AST are in characters of the code decoded to UTF-8, so the
+// offsets are converted to bytes of the original code, in any encoding. It replaces
+// positioner.FromLineCol, that takes the columns as bytes.
+type sourcePositions struct{}
+
+var _ sourceTransformer = sourcePositions{}
+
+func (sourcePositions) onSource(s *source) Transformer {
+	src, err := s.decode()
+	if err != nil {
+		// the native driver fails with the same error
+		return TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+			return obj, false, err
+		})
+	}
+	return TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+		pos := uast.AsPosition(obj)
+		if pos == nil {
+			return obj, false, nil
+		}
+		pos.Offset = uint32(src.Offset(int(pos.Line), int(pos.Col)))
+		obj = obj.CloneObject()
+		for k, v := range pos.ToObject() {
+			obj[k] = v
+		}
+		return obj, true, nil
+	})
+}
+
+// tokenPositions recovers the positions that are missing or inaccurate in the native
+// AST from the tokens of the source code:
+//
+//   - "name_pos" is set to the position of the name of functions and classes (the
+//     node is positioned at the first decorator for decorated ones);
+//   - keywords are positioned at the argument name (or at "**" for the unpacked ones);
+//   - aliases are positioned at the imported path and alias, with the positions of
+//     both in "name_pos" and "asname_pos";
+//   - "module_pos" is set to the position of the module path of ImportFrom.
+//
+// The fields are always set for the given node types, with empty positions if the
+// tokens cannot be found, for example when the code is not valid Python 3.
+type tokenPositions struct{}
+
+var _ sourceTransformer = tokenPositions{}
+
+func (tokenPositions) onSource(s *source) Transformer {
+	return TransformObjFunc(s.fixPositions)
+}
+
+type tokenIndex struct {
+	toks  []parser.Token
+	byPos map[[2]int]int
+}
+
+// indexTokens indexes the tokens by their start position.
+func indexTokens(toks []parser.Token) *tokenIndex {
+	t := &tokenIndex{toks: toks, byPos: make(map[[2]int]int, len(toks))}
+	for i, tok := range toks {
+		t.byPos[[2]int{tok.Start.Line, tok.Start.Col}] = i
+	}
+	return t
+}
+
+// at returns the index of the token at the start of the node, or -1.
+func (t *tokenIndex) at(n nodes.Node) int {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return -1
+	}
+	start := uast.PositionsOf(obj).Start()
+	if start == nil {
+		return -1
+	}
+	i, ok := t.byPos[[2]int{int(start.Line), int(start.Col)}]
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+// value returns the value of the i-th token, or an empty string.
+func (t *tokenIndex) value(i int) string {
+	if i < 0 || i >= len(t.toks) {
+		return ""
+	}
+	return t.toks[i].Value
+}
+
+// number returns the spelling of the numeric literal starting at the i-th token, or
+// an empty string. The tokenizer splits the Python 2 octal ("0777") and long ("10L")
+// literals in several tokens, that are joined back.
+func (t *tokenIndex) number(i int) string {
+	lit := t.value(i)
+	if lit == "" {
+		return ""
+	}
+	for j := i + 1; j < len(t.toks) && t.toks[j].Start.Offset == t.toks[j-1].End.Offset; j++ {
+		next := t.toks[j].Value
+		switch {
+		case next == "L" || next == "l":
+		case strings.Trim(lit, "0") == "" && next != "" && next[0] >= '0' && next[0] <= '9':
+		default:
+			return lit
+		}
+		lit += next
+	}
+	return lit
+}
+
+// paramsEnd returns the line of the parenthesis closing the parameter list of a
+// function definition, or 0.
+func (t *tokenIndex) paramsEnd(obj nodes.Object) int {
+	i := t.at(obj)
+	if i > 0 && t.value(i-1) == "def" {
+		// the position was already fixed to the name by the native driver
+		i--
+	}
+	for ; i >= 0 && i < len(t.toks) && t.value(i) != "def"; i++ {
+	}
+	if i < 0 || t.value(i+2) != "(" {
+		return 0
+	}
+	depth := 0
+	for i += 2; i < len(t.toks); i++ {
+		switch t.value(i) {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth == 0 {
+				return t.toks[i].Start.Line
+			}
+		}
+	}
+	return 0
+}
+
+// span returns the positions of the tokens from i to j, both included. Empty
+// positions are returned if the indexes are out of range.
+func (t *tokenIndex) span(i, j int) nodes.Object {
+	if i < 0 || j < i || j >= len(t.toks) {
+		return uast.Positions{}.ToObject()
+	}
+	conv := func(p parser.Position) uast.Position {
+		return uast.Position{Offset: uint32(p.Offset), Line: uint32(p.Line), Col: uint32(p.Col)}
+	}
+	return uast.Positions{
+		uast.KeyStart: conv(t.toks[i].Start),
+		uast.KeyEnd:   conv(t.toks[j].End),
+	}.ToObject()
+}
+
+// fixPositions sets the positions of the nodes listed in tokenPositions, so the code
+// is only tokenized if there is one.
+func (s *source) fixPositions(obj nodes.Object) (nodes.Object, bool, error) {
+	switch uast.TypeOf(obj) {
+	case "FunctionDef", "AsyncFunctionDef":
+		return s.tokens().fixDef(obj, "def"), true, nil
+	case "ClassDef":
+		return s.tokens().fixDef(obj, "class"), true, nil
+	case "keyword":
+		return s.tokens().fixKeyword(obj), true, nil
+	case "Import":
+		return s.tokens().fixImport(obj), true, nil
+	case "ImportFrom":
+		return s.tokens().fixImportFrom(obj), true, nil
+	}
+	return obj, false, nil
+}
+
+// fixDef sets the position of the name of a function or class definition.
+func (t *tokenIndex) fixDef(obj nodes.Object, keyword string) nodes.Object {
+	obj = obj.CloneObject()
+	name, _ := obj["name"].(nodes.String)
+	i := t.at(obj)
+	if i > 0 && t.value(i-1) == keyword && t.value(i) == string(name) {
+		// the position was already fixed to the name by the native driver
+		obj["name_pos"] = t.span(i, i)
+		return obj
+	}
+	for ; i >= 0 && i < len(t.toks); i++ {
+		if t.value(i) == keyword {
+			break
+		}
+	}
+	if i < 0 || t.value(i+1) != string(name) {
+		obj["name_pos"] = t.span(-1, -1)
+		return obj
+	}
+	obj["name_pos"] = t.span(i+1, i+1)
+	return obj
+}
+
+// fixKeyword sets the position of a keyword argument, searching for the name and
+// the equal sign before its value.
+func (t *tokenIndex) fixKeyword(obj nodes.Object) nodes.Object {
+	i := t.at(obj["value"]) - 1
+	for i >= 0 && t.value(i) == "(" {
+		i--
+	}
+	var pos nodes.Object
+	switch arg := obj["arg"].(type) {
+	case nodes.String:
+		if t.value(i) == "=" && t.value(i-1) == string(arg) {
+			pos = t.span(i-1, i-1)
+		}
+	case nil:
+		if t.value(i) == "**" {
+			pos = t.span(i, i)
+		}
+	}
+	if pos == nil {
+		return obj
+	}
+	obj = obj.CloneObject()
+	obj[uast.KeyPos] = pos
+	return obj
+}
+
+// dottedName returns the index of the last token of the dotted name starting at the
+// i-th token, or -1 if there is no name there.
+func (t *tokenIndex) dottedName(i int) int {
+	if !isName(t.value(i)) {
+		return -1
+	}
+	for t.value(i+1) == "." && isName(t.value(i+2)) {
+		i += 2
+	}
+	return i
+}
+
+// fixAliases sets the positions of the aliases of an import statement, starting at
+// the i-th token. Aliases are set to empty positions if the tokens don't match them.
+func (t *tokenIndex) fixAliases(names nodes.Array, i int, dotted bool) nodes.Array {
+	out := make(nodes.Array, 0, len(names))
+	for k, n := range names {
+		alias, ok := n.(nodes.Object)
+		if !ok {
+			out = append(out, n)
+			continue
+		}
+		if alias["name"] == nodes.String("*") {
+			// star imports have no path to position
+			out = append(out, alias)
+			i = -1
+			continue
+		}
+		alias = alias.CloneObject()
+		end := -1
+		if dotted {
+			end = t.dottedName(i)
+		} else if isName(t.value(i)) {
+			end = i
+		}
+		name, _ := alias["name"].(nodes.String)
+		if end < 0 || joinTokens(t.toks[i:end+1]) != string(name) {
+			// don't try to match the following aliases
+			i = -1
+			alias["name_pos"] = t.span(-1, -1)
+			alias["asname_pos"] = t.span(-1, -1)
+			out = append(out, alias)
+			continue
+		}
+		alias["name_pos"] = t.span(i, end)
+		alias["asname_pos"] = t.span(-1, -1)
+		last := end
+		if asname, ok := alias["asname"].(nodes.String); ok && t.value(end+1) == "as" && t.value(end+2) == string(asname) {
+			last = end + 2
+			alias["asname_pos"] = t.span(last, last)
+		}
+		alias[uast.KeyPos] = t.span(i, last)
+		out = append(out, alias)
+
+		i = last + 1
+		if k != len(names)-1 {
+			if t.value(i) != "," {
+				i = -1
+				continue
+			}
+			i++
+		}
+	}
+	return out
+}
+
+func (t *tokenIndex) fixImport(obj nodes.Object) nodes.Object {
+	obj = obj.CloneObject()
+	names, _ := obj["names"].(nodes.Array)
+	i := t.at(obj)
+	if t.value(i) == "import" {
+		i++
+	} else {
+		i = -1
+	}
+	obj["names"] = t.fixAliases(names, i, true)
+	return obj
+}
+
+func (t *tokenIndex) fixImportFrom(obj nodes.Object) nodes.Object {
+	obj = obj.CloneObject()
+	names, _ := obj["names"].(nodes.Array)
+	obj["module_pos"] = t.span(-1, -1)
+	i := t.at(obj)
+	if t.value(i) != "from" {
+		obj["names"] = t.fixAliases(names, -1, false)
+		return obj
+	}
+	i++
+	for t.value(i) == "." || t.value(i) == "..." {
+		i++
+	}
+	if module, ok := obj["module"].(nodes.String); ok {
+		end := t.dottedName(i)
+		if end < 0 || joinTokens(t.toks[i:end+1]) != string(module) {
+			obj["names"] = t.fixAliases(names, -1, false)
+			return obj
+		}
+		obj["module_pos"] = t.span(i, end)
+		i = end + 1
+	}
+	if t.value(i) != "import" {
+		obj["names"] = t.fixAliases(names, -1, false)
+		return obj
+	}
+	i++
+	if t.value(i) == "(" {
+		i++
+	}
+	obj["names"] = t.fixAliases(names, i, false)
+	return obj
+}
+
+func joinTokens(toks []parser.Token) string {
+	s := ""
+	for _, t := range toks {
+		s += t.Value
+	}
+	return s
+}
+
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	switch s {
+	case "import", "as", "from":
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+package normalizer
+
+import (
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+)
+
+// noopKeys are the fields with the comments of the native nodes.
+var noopKeys = []string{"noops_previous", "noops_sameline"}
+
+// foldPython2 converts the Python 2 statements and expressions that have a Python 3
+// equivalent to the native nodes of it, so they have the same semantic form:
+//
+//   - print and exec statements are calls to the print and exec functions, with the
+//     arguments given by 2to3: `print >>f, x,` is `print(x, end=" ", file=f)`;
+//   - backquotes are calls to repr;
+//   - the arguments of functions have no context and no annotation, and the functions
+//     have no return annotation;
+//   - the names bound by the exception handlers are strings;
+//   - TryExcept and TryFinally are a single Try, and the TryFinally with a TryExcept
+//     body made of the same statement are merged;
+//   - with statements have a list of items, and the ones with several items, that are
+//     nested statements in Python 2, are merged.
+//
+// The nodes are transformed bottom-up, so the children are converted first.
+var foldPython2 = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	switch uast.TypeOf(obj) {
+	case "Print":
+		return foldPrint(obj), true, nil
+	case "Exec":
+		args := nodes.Array{obj["body"]}
+		for _, k := range []string{"globals", "locals"} {
+			if v, ok := obj[k].(nodes.Object); ok {
+				args = append(args, v)
+			}
+		}
+		return callStmt(obj, "exec", args, nodes.Array{}), true, nil
+	case "Repr":
+		call := newCall(obj, "repr", nodes.Array{obj["value"]}, nodes.Array{})
+		moveNoops(call, obj)
+		return call, true, nil
+	case "TryExcept":
+		return newTry(obj, obj["body"], obj["handlers"], obj["orelse"], nodes.Array{}), true, nil
+	case "TryFinally":
+		return foldTryFinally(obj), true, nil
+	case "arg":
+		if _, ok := obj["ctx"]; !ok {
+			return obj, false, nil
+		}
+		obj = obj.CloneObject()
+		delete(obj, "ctx")
+		obj["annotation"] = nil
+		return obj, true, nil
+	case "FunctionDef":
+		if _, ok := obj["returns"]; ok {
+			return obj, false, nil
+		}
+		obj = obj.CloneObject()
+		obj["returns"] = nil
+		return obj, true, nil
+	case "ExceptHandler":
+		name, ok := obj["name"].(nodes.Object)
+		if !ok || uast.TypeOf(name) != "Name" {
+			return obj, false, nil
+		}
+		obj = obj.CloneObject()
+		obj["name"] = name["id"]
+		moveNoops(obj, name)
+		return obj, true, nil
+	case "With":
+		if _, ok := obj["context_expr"]; !ok {
+			return obj, false, nil
+		}
+		return foldWith(obj), true, nil
+	}
+	return obj, false, nil
+})
+
+func foldPrint(obj nodes.Object) nodes.Object {
+	values, _ := obj["values"].(nodes.Array)
+	kws := nodes.Array{}
+	if obj["nl"] == nodes.Bool(false) {
+		kws = append(kws, keyword("end", nodes.Object{
+			uast.KeyType: nodes.String("Str"),
+			uast.KeyPos:  uast.Positions{}.ToObject(),
+			"s":          nodes.String(" "),
+		}))
+	}
+	if dest, ok := obj["dest"].(nodes.Object); ok {
+		kws = append(kws, keyword("file", dest))
+	}
+	return callStmt(obj, "print", append(nodes.Array{}, values...), kws)
+}
+
+// foldTryFinally converts a TryFinally to a Try, merging it with the TryExcept of a
+// try/except/finally statement, that is its only statement and has its position.
+func foldTryFinally(obj nodes.Object) nodes.Object {
+	body, _ := obj["body"].(nodes.Array)
+	if len(body) == 1 {
+		inner, ok := body[0].(nodes.Object)
+		if final, _ := inner["finalbody"].(nodes.Array); ok && uast.TypeOf(inner) == "Try" &&
+			len(final) == 0 && samePos(inner, obj) {
+			try := inner.CloneObject()
+			try["finalbody"] = obj["finalbody"]
+			moveNoops(try, obj)
+			return try
+		}
+	}
+	return newTry(obj, body, nodes.Array{}, nodes.Array{}, obj["finalbody"])
+}
+
+// foldWith converts a Python 2 with statement to one with a list of items. The
+// statement with several items is a nested statement for each of them, positioned at
+// the item, and only the innermost one is positioned at the with keyword by the
+// parser: the statement with no end position is merged with the one of its body.
+func foldWith(obj nodes.Object) nodes.Object {
+	with := nodes.Object{
+		uast.KeyType: nodes.String("With"),
+		"items": nodes.Array{nodes.Object{
+			uast.KeyType:    nodes.String("withitem"),
+			"context_expr":  obj["context_expr"],
+			"optional_vars": obj["optional_vars"],
+		}},
+		"body": obj["body"],
+	}
+	copyPos(with, obj)
+	moveNoops(with, obj)
+	body, _ := obj["body"].(nodes.Array)
+	if len(body) != 1 {
+		return with
+	}
+	inner, ok := body[0].(nodes.Object)
+	items, ok2 := inner["items"].(nodes.Array)
+	if !ok || !ok2 || uast.TypeOf(inner) != "With" || uast.PositionsOf(obj).End() != nil {
+		return with
+	}
+	with["items"] = append(with["items"].(nodes.Array), items...)
+	with["body"] = inner["body"]
+	copyPos(with, inner)
+	moveNoops(with, inner)
+	return with
+}
+
+func newTry(obj nodes.Object, body, handlers, orelse, final nodes.Node) nodes.Object {
+	try := nodes.Object{
+		uast.KeyType: nodes.String("Try"),
+		"body":       body,
+		"handlers":   handlers,
+		"orelse":     orelse,
+		"finalbody":  final,
+	}
+	copyPos(try, obj)
+	moveNoops(try, obj)
+	return try
+}
+
+// callStmt converts a statement to an expression statement calling a function, that
+// is positioned at the keyword of the statement.
+func callStmt(obj nodes.Object, fnc string, args, kws nodes.Array) nodes.Object {
+	expr := nodes.Object{
+		uast.KeyType: nodes.String("Expr"),
+		"value":      newCall(obj, fnc, args, kws),
+	}
+	copyStart(expr, obj)
+	moveNoops(expr, obj)
+	return expr
+}
+
+// newCall creates a call to a builtin function, that is named by the token of the
+// node.
+func newCall(obj nodes.Object, fnc string, args, kws nodes.Array) nodes.Object {
+	name := nodes.Object{
+		uast.KeyType: nodes.String("Name"),
+		"id":         nodes.String(fnc),
+		"ctx":        nodes.String("Load"),
+	}
+	copyPos(name, obj)
+	call := nodes.Object{
+		uast.KeyType: nodes.String("Call"),
+		"func":       name,
+		"args":       args,
+		"keywords":   kws,
+	}
+	copyStart(call, obj)
+	return call
+}
+
+// keyword creates a keyword argument, that has no position, like the nodes that are not
+// in the code.
+func keyword(name string, value nodes.Node) nodes.Object {
+	return nodes.Object{
+		uast.KeyType: nodes.String("keyword"),
+		uast.KeyPos:  uast.Positions{}.ToObject(),
+		"arg":        nodes.String(name),
+		"value":      value,
+	}
+}
+
+func copyPos(dst, src nodes.Object) {
+	if pos, ok := src[uast.KeyPos]; ok {
+		dst[uast.KeyPos] = pos
+	}
+}
+
+// copyStart copies the start position of the node, like the one of the Python nodes
+// that have no token.
+func copyStart(dst, src nodes.Object) {
+	if start := uast.PositionsOf(src).Start(); start != nil {
+		dst[uast.KeyPos] = uast.Positions{uast.KeyStart: *start}.ToObject()
+	}
+}
+
+// moveNoops moves the comments of a node to the node that replaces it, unless it
+// already has them.
+func moveNoops(dst, src nodes.Object) {
+	for _, k := range noopKeys {
+		if v, ok := src[k]; ok {
+			if _, ok := dst[k]; !ok {
+				dst[k] = v
+			}
+		}
+	}
+}
+
+func samePos(a, b nodes.Object) bool {
+	sa, sb := uast.PositionsOf(a).Start(), uast.PositionsOf(b).Start()
+	return sa != nil && sb != nil && *sa == *sb
+}
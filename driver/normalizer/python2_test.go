@@ -0,0 +1,78 @@
+package normalizer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bblfsh/python-driver/driver/parser"
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+	"github.com/stretchr/testify/require"
+)
+
+// semanticBody returns the statements of the semantic UAST of the code parsed with
+// the version, without their positions.
+func semanticBody(t *testing.T, src string, v parser.Version) nodes.Node {
+	ast, got, err := parser.ParseVersion(src, v)
+	require.NoError(t, err)
+	require.Equal(t, v, got)
+	ast, err = Transforms.Do(context.Background(), driver.ModeSemantic, src, ast)
+	require.NoError(t, err)
+	return dropPositions(ast.(nodes.Object)["body"])
+}
+
+func dropPositions(n nodes.Node) nodes.Node {
+	switch n := n.(type) {
+	case nodes.Object:
+		out := make(nodes.Object, len(n))
+		for k, v := range n {
+			if k != uast.KeyPos {
+				out[k] = dropPositions(v)
+			}
+		}
+		return out
+	case nodes.Array:
+		out := make(nodes.Array, 0, len(n))
+		for _, e := range n {
+			out = append(out, dropPositions(e))
+		}
+		return out
+	}
+	return n
+}
+
+func TestFoldPython2(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		py2, py3 string
+	}{
+		{"print", "print x, y\n", "print(x, y)\n"},
+		{"print empty", "print\n", "print()\n"},
+		{"print comma", "print >>sys.stderr, x,\n", "print(x, end=' ', file=sys.stderr)\n"},
+		{"exec", "exec code in g, l\n", "exec(code, g, l)\n"},
+		{"repr", "s = `a, b`\n", "s = repr((a, b))\n"},
+		{"try except", "try:\n    f()\nexcept E, e:\n    g(e)\nelse:\n    h()\n",
+			"try:\n    f()\nexcept E as e:\n    g(e)\nelse:\n    h()\n"},
+		{"try finally", "try:\n    f()\nfinally:\n    h()\n", "try:\n    f()\nfinally:\n    h()\n"},
+		{"try except finally", "try:\n    f()\nexcept E:\n    g()\nfinally:\n    h()\n",
+			"try:\n    f()\nexcept E:\n    g()\nfinally:\n    h()\n"},
+		{"nested try", "try:\n    try:\n        f()\n    except E:\n        g()\nfinally:\n    h()\n",
+			"try:\n    try:\n        f()\n    except E:\n        g()\nfinally:\n    h()\n"},
+		{"with", "with open(a) as f, b:\n    with c:\n        pass\n",
+			"with open(a) as f, b:\n    with c:\n        pass\n"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			exp := semanticBody(t, c.py3, parser.Python3)
+			got := semanticBody(t, c.py2, parser.Python2)
+			if !nodes.Equal(exp, got) {
+				e, err := uastyaml.Marshal(exp)
+				require.NoError(t, err)
+				g, err := uastyaml.Marshal(got)
+				require.NoError(t, err)
+				require.Equal(t, string(e), string(g))
+			}
+		})
+	}
+}
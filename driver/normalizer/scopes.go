@@ -0,0 +1,452 @@
+package normalizer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+)
+
+// Scopes is an optional stage that runs after Normalize and resolves the names to the
+// variables they refer to, following the scoping rules of Python 3 (LEGB): modules,
+// classes, functions (including lambdas) and comprehensions have their own scope, the
+// names bound in a class are not visible from the nested scopes, and the names
+// declared with global or nonlocal are bound in the module or in the enclosing
+// function.
+//
+// Each variable gets an ID with its name and the position of its first binding site,
+// like "x@3:5", and the "binding" field of every identifier that binds or uses it is
+// set to it. The names that are not bound in the module get a "builtins." prefix.
+// Names stored as strings are annotated in their node: the "binding" field of match
+// captures, and the "rest_binding" field of mapping patterns.
+//
+// Python 2 comprehensions are resolved as the Python 3 ones, even if they don't have
+// their own scope. The stage can also run on the semantic UAST returned by
+// Transforms, whose types have the namespace of the driver.
+var Scopes Transformer = scopes{}
+
+// ScopeTransforms are the Transforms with the Scopes stage, for the clients that need
+// the bindings of the names.
+var ScopeTransforms = func() driver.Transforms {
+	t := Transforms
+	t.Normalize = append(Normalize[:len(Normalize):len(Normalize)], Scopes)
+	return t
+}()
+
+type scopes struct{}
+
+func (scopes) Do(root nodes.Node) (nodes.Node, error) {
+	// the nodes are annotated in place
+	root = root.Clone()
+	a := &scopeAnalysis{}
+	mod := a.newScope(scopeModule, nil, nil)
+	a.walk(root, mod)
+	a.resolve()
+	return root, nil
+}
+
+type scopeKind int
+
+const (
+	scopeModule scopeKind = iota
+	scopeClass
+	scopeFunction
+	scopeComprehension
+)
+
+type scope struct {
+	kind   scopeKind
+	parent *scope
+	// pos is the position of the node of the scope, used when the variables have no
+	// binding site with a position
+	pos string
+	// bound are the names bound in the scope, including the ones declared global or
+	// nonlocal
+	bound              map[string]bool
+	globals, nonlocals map[string]bool
+	vars               map[string]*variable
+}
+
+type variable struct {
+	name  string
+	scope *scope
+	// first is the start of the first binding site with a position
+	first *uast.Position
+}
+
+// nameRef is an occurrence of a name in the tree, that is annotated with the ID of the
+// variable in the field of the node.
+type nameRef struct {
+	scope *scope
+	name  string
+	node  nodes.Object
+	field string
+	// bind is set for the binding sites
+	bind bool
+}
+
+type scopeAnalysis struct {
+	refs []nameRef
+}
+
+func (a *scopeAnalysis) newScope(kind scopeKind, parent *scope, n nodes.Node) *scope {
+	pos := "0:0"
+	if obj, ok := n.(nodes.Object); ok {
+		if start := uast.PositionsOf(obj).Start(); start != nil {
+			pos = fmt.Sprintf("%d:%d", start.Line, start.Col)
+		}
+	}
+	return &scope{
+		kind: kind, parent: parent, pos: pos,
+		bound:     make(map[string]bool),
+		globals:   make(map[string]bool),
+		nonlocals: make(map[string]bool),
+		vars:      make(map[string]*variable),
+	}
+}
+
+// bind records a binding site of the name in the node.
+func (a *scopeAnalysis) bind(s *scope, obj nodes.Object, name nodes.Node, field string) {
+	str, ok := name.(nodes.String)
+	if !ok {
+		return
+	}
+	s.bound[string(str)] = true
+	a.refs = append(a.refs, nameRef{scope: s, name: string(str), node: obj, field: field, bind: true})
+}
+
+// bindIdent records the identifier as a binding site.
+func (a *scopeAnalysis) bindIdent(s *scope, n nodes.Node) {
+	if id, ok := n.(nodes.Object); ok && scopeType(id) == uast.TypeOf(uast.Identifier{}) {
+		a.bind(s, id, id["Name"], "binding")
+	}
+}
+
+// use records the identifier as a use of the name.
+func (a *scopeAnalysis) use(s *scope, id nodes.Object) {
+	if name, ok := id["Name"].(nodes.String); ok {
+		a.refs = append(a.refs, nameRef{scope: s, name: string(name), node: id, field: "binding"})
+	}
+}
+
+func (a *scopeAnalysis) walk(n nodes.Node, s *scope) {
+	switch n := n.(type) {
+	case nodes.Array:
+		for _, e := range n {
+			a.walk(e, s)
+		}
+	case nodes.Object:
+		switch scopeType(n) {
+		case "BoxedName":
+			id, _ := n["boxed_value"].(nodes.Object)
+			switch n["ctx"] {
+			case nodes.String("Store"), nodes.String("Del"):
+				a.bindIdent(s, id)
+			default:
+				a.use(s, id)
+			}
+			return
+		case "BoxedAttribute", "keyword":
+			// attribute names and keyword arguments are not variables
+			a.walk(n["value"], s)
+			return
+		case "Global", "Nonlocal":
+			decl := s.globals
+			if scopeType(n) == "Nonlocal" {
+				decl = s.nonlocals
+			}
+			names, _ := n["names"].(nodes.Array)
+			for _, b := range names {
+				b, _ := b.(nodes.Object)
+				id, _ := b["boxed_value"].(nodes.Object)
+				if name, ok := id["Name"].(nodes.String); ok {
+					decl[string(name)] = true
+					s.module().bound[string(name)] = true
+					a.bindIdent(s, id)
+				}
+			}
+			return
+		case "Assign":
+			if n["expression"] == nodes.Bool(true) {
+				// assignment expressions in comprehensions bind in the enclosing scope
+				t := s
+				for t.kind == scopeComprehension {
+					t = t.parent
+				}
+				a.walk(n["targets"], t)
+				a.walk(n["value"], s)
+				return
+			}
+			a.expr(n["annotation"], s)
+			a.expr(n["type_comment"], s)
+			a.walkFields(n, s, "annotation", "type_comment")
+			return
+		case "ExceptHandler":
+			// Python 2 handlers bind an expression
+			if name, ok := n["name"].(nodes.Object); ok && scopeType(name) == uast.TypeOf(uast.Identifier{}) {
+				a.bindIdent(s, name)
+				a.walkFields(n, s, "name")
+				return
+			}
+			a.walkFields(n, s)
+			return
+		case "MatchAs", "MatchStar":
+			a.bind(s, n, n["name"], "binding")
+			a.walkFields(n, s, "name")
+			return
+		case "MatchMapping":
+			a.bind(s, n, n["rest"], "rest_binding")
+			a.walkFields(n, s, "rest")
+			return
+		case "ListComp", "SetComp", "GeneratorExp", "DictComp":
+			a.comprehension(n, s)
+			return
+		case uast.TypeOf(uast.RuntimeImport{}):
+			a.imports(n, s)
+			return
+		case uast.TypeOf(uast.FunctionGroup{}):
+			list, _ := n["Nodes"].(nodes.Array)
+			if len(list) == 2 {
+				alias, _ := list[1].(nodes.Object)
+				a.header(list[0], s)
+				a.bindIdent(s, alias["Name"])
+				a.function(alias["Node"], s)
+				return
+			}
+		case uast.TypeOf(uast.Group{}):
+			if alias, ok := classAlias(n); ok {
+				a.header(n["Nodes"].(nodes.Array)[0], s)
+				a.bindIdent(s, alias["Name"])
+				a.walk(alias["Node"], a.newScope(scopeClass, s, n))
+				return
+			}
+		case uast.TypeOf(uast.Function{}):
+			// lambdas
+			a.function(n, s)
+			return
+		}
+		a.walkFields(n, s)
+	}
+}
+
+// scopeType returns the type of the node without the namespace of the driver.
+func scopeType(n nodes.Node) string {
+	return strings.TrimPrefix(uast.TypeOf(n), Transforms.Namespace+":")
+}
+
+// walkFields walks the fields of the node in a stable order, except the given ones.
+func (a *scopeAnalysis) walkFields(n nodes.Object, s *scope, skip ...string) {
+	for _, k := range n.Keys() {
+		if k == uast.KeyPos || contains(skip, k) {
+			continue
+		}
+		a.walk(n[k], s)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// expr walks an expression that may be an unboxed identifier, like the decorators and
+// the type annotations.
+func (a *scopeAnalysis) expr(n nodes.Node, s *scope) {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		a.walk(n, s)
+		return
+	}
+	switch scopeType(obj) {
+	case uast.TypeOf(uast.Identifier{}):
+		a.use(s, obj)
+	case uast.TypeOf(uast.QualifiedIdentifier{}):
+		if names, _ := obj["Names"].(nodes.Array); len(names) != 0 {
+			a.expr(names[0], s)
+		}
+	case "Call":
+		a.expr(obj["callee"], s)
+		a.walkFields(obj, s, "callee")
+	default:
+		a.walk(n, s)
+	}
+}
+
+// header walks the first node of a function or class group, with the decorators, the
+// base classes and the comments.
+func (a *scopeAnalysis) header(n nodes.Node, s *scope) {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return
+	}
+	decs, _ := obj["decorators"].(nodes.Array)
+	for _, d := range decs {
+		a.expr(d, s)
+	}
+	a.walkFields(obj, s, "decorators")
+}
+
+// classAlias returns the alias binding the class name to its body, if the group is a
+// class.
+func classAlias(n nodes.Object) (nodes.Object, bool) {
+	list, _ := n["Nodes"].(nodes.Array)
+	if len(list) != 2 {
+		return nil, false
+	}
+	alias, ok := list[1].(nodes.Object)
+	if !ok || scopeType(alias) != uast.TypeOf(uast.Alias{}) {
+		return nil, false
+	}
+	return alias, scopeType(alias["Node"]) == uast.TypeOf(uast.Block{})
+}
+
+// function walks a function in the scope where it's defined. The default values and
+// the annotations are evaluated in that scope, and the arguments are bound in the
+// scope of the function.
+func (a *scopeAnalysis) function(n nodes.Node, s *scope) {
+	fn, ok := n.(nodes.Object)
+	if !ok {
+		return
+	}
+	fs := a.newScope(scopeFunction, s, fn)
+	typ, _ := fn["Type"].(nodes.Object)
+	args, _ := typ["Arguments"].(nodes.Array)
+	for _, arg := range args {
+		arg, _ := arg.(nodes.Object)
+		a.expr(arg["Init"], s)
+		a.expr(arg["Type"], s)
+		a.bindIdent(fs, arg["Name"])
+	}
+	rets, _ := typ["Returns"].(nodes.Array)
+	for _, ret := range rets {
+		// the initial value is the implicit None
+		ret, _ := ret.(nodes.Object)
+		a.expr(ret["Type"], s)
+	}
+	a.walk(fn["Body"], fs)
+}
+
+// comprehension walks a comprehension. The iterable of the first generator is evaluated
+// in the enclosing scope, and the rest in the scope of the comprehension.
+func (a *scopeAnalysis) comprehension(n nodes.Object, s *scope) {
+	cs := a.newScope(scopeComprehension, s, n)
+	gens, _ := n["generators"].(nodes.Array)
+	for i, g := range gens {
+		g, ok := g.(nodes.Object)
+		if !ok {
+			continue
+		}
+		if i == 0 {
+			a.walk(g["iter"], s)
+		} else {
+			a.walk(g["iter"], cs)
+		}
+		a.walkFields(g, cs, "iter")
+	}
+	a.walkFields(n, cs, "generators")
+}
+
+// imports binds the names imported by the statement. The paths of the modules are not
+// variables, except the first name of "import a.b", that binds "a".
+func (a *scopeAnalysis) imports(n nodes.Object, s *scope) {
+	bindAlias := func(n nodes.Node) {
+		obj, _ := n.(nodes.Object)
+		switch scopeType(obj) {
+		case uast.TypeOf(uast.Alias{}):
+			a.bindIdent(s, obj["Name"])
+		case uast.TypeOf(uast.Identifier{}):
+			a.bindIdent(s, obj)
+		case uast.TypeOf(uast.QualifiedIdentifier{}):
+			if names, _ := obj["Names"].(nodes.Array); len(names) != 0 {
+				a.bindIdent(s, names[0])
+			}
+		}
+	}
+	names, _ := n["Names"].(nodes.Array)
+	if len(names) == 0 && n["All"] != nodes.Bool(true) {
+		bindAlias(n["Path"])
+	}
+	for _, name := range names {
+		bindAlias(name)
+	}
+}
+
+// resolve annotates the names with the IDs of the variables they refer to, once all the
+// binding sites are known.
+func (a *scopeAnalysis) resolve() {
+	vars := make([]*variable, len(a.refs))
+	for i, ref := range a.refs {
+		v := ref.scope.lookup(ref.name)
+		vars[i] = v
+		if v == nil || !ref.bind {
+			continue
+		}
+		start := uast.PositionsOf(ref.node).Start()
+		if start != nil && (v.first == nil || start.Offset < v.first.Offset) {
+			v.first = start
+		}
+	}
+	for i, ref := range a.refs {
+		id := "builtins." + ref.name
+		if v := vars[i]; v != nil {
+			id = v.id()
+		}
+		ref.node[ref.field] = nodes.String(id)
+	}
+}
+
+func (v *variable) id() string {
+	if v.first == nil {
+		return v.name + "@" + v.scope.pos
+	}
+	return fmt.Sprintf("%s@%d:%d", v.name, v.first.Line, v.first.Col)
+}
+
+// lookup returns the variable the name refers to in the scope, or nil if it's not bound
+// in the module.
+func (s *scope) lookup(name string) *variable {
+	switch {
+	case s.globals[name]:
+		return s.module().variable(name)
+	case s.nonlocals[name]:
+		return s.parent.enclosing(name)
+	case s.bound[name]:
+		return s.variable(name)
+	}
+	return s.parent.enclosing(name)
+}
+
+// enclosing returns the variable the name refers to in a nested scope of this one.
+func (s *scope) enclosing(name string) *variable {
+	for ; s != nil; s = s.parent {
+		if s.kind != scopeClass && (s.bound[name] || s.globals[name] || s.nonlocals[name]) {
+			return s.lookup(name)
+		}
+	}
+	return nil
+}
+
+func (s *scope) module() *scope {
+	for s.parent != nil {
+		s = s.parent
+	}
+	return s
+}
+
+// variable returns the variable bound in the scope, creating it if it's the first
+// reference.
+func (s *scope) variable(name string) *variable {
+	v, ok := s.vars[name]
+	if !ok {
+		v = &variable{name: name, scope: s}
+		s.vars[name] = v
+	}
+	return v
+}
@@ -0,0 +1,184 @@
+package normalizer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/python-driver/driver/parser"
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/stretchr/testify/require"
+)
+
+// bindings returns the bindings of the names in the semantic UAST of the code, by the
+// name and the position of each occurrence.
+func bindings(t *testing.T, src string) map[string]string {
+	ast, err := parser.Parse(src)
+	require.NoError(t, err)
+	ast, err = ScopeTransforms.Do(context.Background(), driver.ModeSemantic, src, ast)
+	require.NoError(t, err)
+
+	out := make(map[string]string)
+	nodes.WalkPreOrder(ast, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok {
+			return true
+		}
+		for _, field := range []string{"binding", "rest_binding"} {
+			b, ok := obj[field].(nodes.String)
+			if !ok {
+				continue
+			}
+			name, _ := obj["Name"].(nodes.String)
+			if name == "" {
+				name, _ = obj[uast.KeyToken].(nodes.String)
+			}
+			if field == "rest_binding" {
+				name = "**"
+			}
+			start := uast.PositionsOf(obj).Start()
+			require.NotNil(t, start, "%v", obj)
+			out[fmt.Sprintf("%s@%d:%d", name, start.Line, start.Col)] = string(b)
+		}
+		return true
+	})
+	return out
+}
+
+func TestScopes(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		exp  map[string]string
+	}{
+		{
+			name: "locals and globals",
+			src: strings.Join([]string{
+				"x = 1",
+				"def f(a, b=x):",
+				"    x = a",
+				"    return x + b + len(a)",
+				"print(x)",
+			}, "\n"),
+			exp: map[string]string{
+				"x@1:1":     "x@1:1",
+				"f@2:5":     "f@2:5",
+				"a@2:7":     "a@2:7",
+				"b@2:10":    "b@2:10",
+				"x@2:12":    "x@1:1",
+				"x@3:5":     "x@3:5",
+				"a@3:9":     "a@2:7",
+				"x@4:12":    "x@3:5",
+				"b@4:16":    "b@2:10",
+				"len@4:20":  "builtins.len",
+				"a@4:24":    "a@2:7",
+				"print@5:1": "builtins.print",
+				"x@5:7":     "x@1:1",
+			},
+		},
+		{
+			name: "global and nonlocal",
+			src: strings.Join([]string{
+				"def counter():",
+				"    global total",
+				"    total = 0",
+				"    n = 0",
+				"    def inc():",
+				"        nonlocal n",
+				"        n += 1",
+				"    return inc",
+				"total",
+			}, "\n"),
+			exp: map[string]string{
+				"counter@1:5": "counter@1:5",
+				"total@2:12":  "total@2:12",
+				"total@3:5":   "total@2:12",
+				"n@4:5":       "n@4:5",
+				"inc@5:9":     "inc@5:9",
+				"n@6:18":      "n@4:5",
+				"n@7:9":       "n@4:5",
+				"inc@8:12":    "inc@5:9",
+				"total@9:1":   "total@2:12",
+			},
+		},
+		{
+			name: "class scope",
+			src: strings.Join([]string{
+				"class A:",
+				"    y = 1",
+				"    z = [y for _ in range(y)]",
+				"    def m(self):",
+				"        return y",
+			}, "\n"),
+			exp: map[string]string{
+				"A@1:7":      "A@1:7",
+				"y@2:5":      "y@2:5",
+				"z@3:5":      "z@3:5",
+				"y@3:10":     "builtins.y",
+				"_@3:16":     "_@3:16",
+				"range@3:21": "builtins.range",
+				"y@3:27":     "y@2:5",
+				"m@4:9":      "m@4:9",
+				"self@4:11":  "self@4:11",
+				"y@5:16":     "builtins.y",
+			},
+		},
+		{
+			name: "comprehensions and assignment expressions",
+			src: strings.Join([]string{
+				"import os.path as p, sys",
+				"from abc import ABC as Base",
+				"found = [last := f for f in sys.argv if p.exists(f)]",
+				"print(last, f)",
+			}, "\n"),
+			exp: map[string]string{
+				"p@1:19":    "p@1:19",
+				"sys@1:22":  "sys@1:22",
+				"Base@2:24": "Base@2:24",
+				"found@3:1": "found@3:1",
+				"last@3:10": "last@3:10",
+				"f@3:18":    "f@3:24",
+				"f@3:24":    "f@3:24",
+				"sys@3:29":  "sys@1:22",
+				"p@3:41":    "p@1:19",
+				"f@3:50":    "f@3:24",
+				"print@4:1": "builtins.print",
+				"last@4:7":  "last@3:10",
+				"f@4:13":    "builtins.f",
+			},
+		},
+		{
+			name: "handlers and patterns",
+			src: strings.Join([]string{
+				"try:",
+				"    pass",
+				"except Exception as e:",
+				"    print(e)",
+				"match e:",
+				"    case {'k': v, **rest}:",
+				"        print(v, rest)",
+			}, "\n"),
+			exp: map[string]string{
+				"Exception@3:8": "builtins.Exception",
+				"e@3:21":        "e@3:21",
+				"print@4:5":     "builtins.print",
+				"e@4:11":        "e@3:21",
+				"e@5:7":         "e@3:21",
+				"v@6:16":        "v@6:16",
+				"**@6:10":       "rest@6:10",
+				"print@7:9":     "builtins.print",
+				"v@7:15":        "v@6:16",
+				"rest@7:18":     "rest@6:10",
+			},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.exp, bindings(t, c.src))
+		})
+	}
+}
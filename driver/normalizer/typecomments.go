@@ -0,0 +1,456 @@
+package normalizer
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+)
+
+// typeComments parses the type comments (PEP 484) of assignments and functions and
+// sets their "type_comment" field, as the Python AST does when asked to keep them:
+//
+//   - assignments get the preprocessed AST of the type, from the comment at the end
+//     of the statement, like "x = []  # type: List[int]";
+//   - functions get a FunctionType node with the types of the arguments in
+//     "argtypes" and the return type in "returns", from the comment at the end of
+//     the signature or in the lines following it, like "# type: (int, str) -> bool".
+//     The stars of the variadic arguments are dropped;
+//   - the arguments of functions with a signature split in several lines get the
+//     type in the comment at the end of their line, if the parameter list goes on
+//     in the following lines, like "a,  # type: int".
+//
+// The comments are kept, and the comments that cannot be parsed are ignored. As with
+// the forward references, the types are positioned in the comment, so it must run
+// before the positions are converted. The types of the functions are moved to their
+// arguments by applyTypeComments in the semantic mode.
+type typeComments struct{}
+
+var _ sourceTransformer = typeComments{}
+
+func (typeComments) onSource(s *source) Transformer {
+	lines := strings.Split(s.text(), "\n")
+	// the tokens are only needed for the functions with argument comments
+	paramsEnd := func(obj nodes.Object) int {
+		return s.tokens().paramsEnd(obj)
+	}
+	return TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+		var (
+			tc   nodes.Node
+			args nodes.Object
+		)
+		switch uast.TypeOf(obj) {
+		case "Assign":
+			if c, ok := lastTypeComment(obj); ok {
+				tc = c.parse(lines)
+			}
+		case "FunctionDef", "AsyncFunctionDef":
+			for _, c := range signatureComments(obj) {
+				if tc = c.parseSignature(lines); tc != nil {
+					break
+				}
+			}
+			args = argTypeComments(obj, lines, paramsEnd)
+		}
+		if tc == nil && args == nil {
+			return obj, false, nil
+		}
+		obj = obj.CloneObject()
+		if tc != nil {
+			obj["type_comment"] = tc
+		}
+		if args != nil {
+			obj["args"] = args
+		}
+		return obj, true, nil
+	})
+}
+
+// argTypeComments returns the arguments of a function with the "type_comment" field
+// of the ones with a type comment set, or nil if there are none. Only the comments in
+// the lines before the one closing the parameter list, returned by paramsEnd, are
+// argument comments: the comment at the end of the signature is the type comment of
+// the function.
+func argTypeComments(obj nodes.Object, lines []string, paramsEnd func(nodes.Object) int) nodes.Object {
+	args, _ := obj["args"].(nodes.Object)
+	list, _ := args["args"].(nodes.Array)
+	var out nodes.Array
+	end := -1
+	for i, a := range list {
+		arg, ok := a.(nodes.Object)
+		if !ok {
+			continue
+		}
+		var cl []typeComment
+		collectComments(arg, &cl, false)
+		if len(cl) != 0 && end < 0 {
+			end = paramsEnd(obj)
+		}
+		for _, c := range cl {
+			if c.line >= end {
+				break
+			}
+			tc := c.parse(lines)
+			if tc == nil {
+				continue
+			}
+			if out == nil {
+				out = append(nodes.Array{}, list...)
+			}
+			arg = arg.CloneObject()
+			arg["type_comment"] = tc
+			out[i] = arg
+			break
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	args = args.CloneObject()
+	args["args"] = out
+	return args
+}
+
+// reTypeComment matches a type comment, capturing the type. Other comments may follow
+// the type, but "type: ignore" comments are not type comments.
+var reTypeComment = regexp.MustCompile(`^#\s*type:\s*([^#]*?)\s*(?:#.*)?$`)
+
+type typeComment struct {
+	// text is the whole comment, with no trailing newline
+	text string
+	line int
+}
+
+// typ returns the type in the comment and its offset in the text.
+func (c typeComment) typ() (string, int, bool) {
+	m := reTypeComment.FindStringSubmatchIndex(c.text)
+	if m == nil {
+		return "", 0, false
+	}
+	typ := c.text[m[2]:m[3]]
+	if typ == "" || typ == "ignore" || strings.HasPrefix(typ, "ignore[") {
+		return "", 0, false
+	}
+	return typ, m[2], true
+}
+
+// column returns the column of the byte at the offset of the comment text in the
+// source code, or 0 if the comment is not in its line.
+func (c typeComment) column(lines []string, off int) int {
+	if c.line < 1 || c.line > len(lines) {
+		return 0
+	}
+	line := lines[c.line-1]
+	i := strings.Index(line, c.text)
+	if i < 0 {
+		return 0
+	}
+	return utf8.RuneCountInString(line[:i]) + utf8.RuneCountInString(c.text[:off]) + 1
+}
+
+// position returns the line and column of the byte at the offset of the comment
+// text, or zeros if it's unknown.
+func (c typeComment) position(lines []string, off int) (int, int) {
+	col := c.column(lines, off)
+	if col == 0 {
+		return 0, 0
+	}
+	return c.line, col
+}
+
+// parse returns the type of an assignment.
+func (c typeComment) parse(lines []string) nodes.Node {
+	typ, off, ok := c.typ()
+	if !ok {
+		return nil
+	}
+	line, col := c.position(lines, off)
+	return parseTypeExpr(typ, line, col)
+}
+
+// parseSignature returns the FunctionType of a function signature.
+func (c typeComment) parseSignature(lines []string) nodes.Node {
+	typ, off, ok := c.typ()
+	if !ok || !strings.HasPrefix(typ, "(") {
+		return nil
+	}
+	end := closingParen(typ)
+	if end < 0 {
+		return nil
+	}
+	rest := strings.TrimLeft(typ[end+1:], " \t")
+	if !strings.HasPrefix(rest, "->") {
+		return nil
+	}
+	argtypes := nodes.Array{}
+	if params := strings.TrimSpace(typ[1:end]); params != "..." && params != "" {
+		// offset of the item in the type
+		pos := 1
+		for _, item := range splitTopLevel(typ[1:end]) {
+			lead := len(item) - len(strings.TrimLeft(item, " \t*"))
+			line, col := c.position(lines, off+pos+lead)
+			ast := parseTypeExpr(strings.TrimSpace(item[lead:]), line, col)
+			if ast == nil {
+				return nil
+			}
+			argtypes = append(argtypes, ast)
+			pos += len(item) + 1
+		}
+	}
+	i := len(typ) - len(strings.TrimLeft(rest[2:], " \t"))
+	line, col := c.position(lines, off+i)
+	ret := parseTypeExpr(typ[i:], line, col)
+	if ret == nil {
+		return nil
+	}
+	return nodes.Object{
+		uast.KeyType: nodes.String("FunctionType"),
+		"argtypes":   argtypes,
+		"returns":    ret,
+	}
+}
+
+// closingParen returns the index of the parenthesis closing the one at the start of
+// the string, or -1.
+func closingParen(s string) int {
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits the string at the commas that are not nested in brackets.
+func splitTopLevel(s string) []string {
+	var (
+		out   []string
+		depth int
+		last  int
+	)
+	for i, r := range s {
+		switch r {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(out, s[last:])
+}
+
+// lastTypeComment returns the type comment at the end of a statement, that is the
+// last one of the same line comments of its nodes.
+func lastTypeComment(obj nodes.Object) (typeComment, bool) {
+	var list []typeComment
+	collectComments(obj, &list, false)
+	for i := len(list) - 1; i >= 0; i-- {
+		if _, _, ok := list[i].typ(); ok {
+			return list[i], true
+		}
+	}
+	return typeComment{}, false
+}
+
+// signatureComments returns the comments that may have the type of a function
+// signature, in order: the same line comments of the header, and the comment lines
+// before the first statement of the body.
+func signatureComments(obj nodes.Object) []typeComment {
+	header := make(nodes.Object, len(obj))
+	for k, v := range obj {
+		switch k {
+		case "body", "decorator_list", "noops_previous":
+		default:
+			header[k] = v
+		}
+	}
+	var list []typeComment
+	collectComments(header, &list, false)
+	body, _ := obj["body"].(nodes.Array)
+	if len(body) == 0 {
+		return list
+	}
+	var prev []typeComment
+	collectComments(body[0], &prev, true)
+	return append(list, prev...)
+}
+
+// collectComments appends the same line comments (or the comment lines before the
+// nodes, if prev is set) of the node and its children, without going into nested
+// statement lists. The list is sorted by line.
+func collectComments(n nodes.Node, list *[]typeComment, prev bool) {
+	var walk func(n nodes.Node)
+	walk = func(n nodes.Node) {
+		switch n := n.(type) {
+		case nodes.Array:
+			for _, e := range n {
+				walk(e)
+			}
+		case nodes.Object:
+			for k, v := range n {
+				if isStmtList(n, k) {
+					continue
+				}
+				obj, _ := v.(nodes.Object)
+				switch {
+				case k == "noops_sameline" && !prev:
+					line := commentLine(obj)
+					arr, _ := obj["noop_lines"].(nodes.Array)
+					for _, l := range arr {
+						if l, ok := l.(nodes.Object); ok {
+							s, _ := l["s"].(nodes.String)
+							*list = append(*list, typeComment{text: string(s), line: line})
+						}
+					}
+				case k == "noops_previous" && prev:
+					arr, _ := obj["lines"].(nodes.Array)
+					for _, l := range arr {
+						if l, ok := l.(nodes.Object); ok {
+							s, _ := l["noop_line"].(nodes.String)
+							text := strings.TrimSpace(string(s))
+							*list = append(*list, typeComment{text: text, line: commentLine(l)})
+						}
+					}
+				case k != "noops_sameline" && k != "noops_previous":
+					walk(v)
+				}
+			}
+		}
+	}
+	walk(n)
+	sort.SliceStable(*list, func(i, j int) bool {
+		return (*list)[i].line < (*list)[j].line
+	})
+}
+
+// commentLine returns the line of the comment node, or 0.
+func commentLine(obj nodes.Object) int {
+	start := uast.PositionsOf(obj).Start()
+	if start == nil {
+		return 0
+	}
+	return int(start.Line)
+}
+
+// applyTypeComments sets the types of the arguments and the return type of the
+// functions from their type comments, parsed by typeComments, unless they are
+// annotated. The types of the arguments in the comment of the signature are used if
+// their number matches (the type of the first argument of methods can be omitted),
+// and the comments of the arguments take precedence over them.
+//
+// The "type_comment" field of the arguments whose type is set is replaced with true,
+// and so is the "returns_type_comment" field of the function if the return type is
+// set, so the semantic UAST keeps the origin of the types. The other type comments
+// are dropped.
+var applyTypeComments = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	switch uast.TypeOf(obj) {
+	case "FunctionDef", "AsyncFunctionDef":
+	default:
+		return obj, false, nil
+	}
+	args, _ := obj["args"].(nodes.Object)
+	list, _ := args["args"].(nodes.Array)
+	tc, _ := obj["type_comment"].(nodes.Object)
+	if uast.TypeOf(tc) != "FunctionType" {
+		tc = nil
+	}
+	if tc == nil && !hasTypeComment(list) {
+		return obj, false, nil
+	}
+	obj = obj.CloneObject()
+	delete(obj, "type_comment")
+	if tc != nil && obj["returns"] == nil {
+		obj["returns"] = tc["returns"]
+		obj["returns_type_comment"] = nodes.Bool(true)
+	}
+
+	// the types of the signature comment, by argument index
+	types := make([]nodes.Node, len(list))
+	if tc != nil {
+		argtypes, _ := tc["argtypes"].(nodes.Array)
+		order := sourceOrder(list)
+		switch len(argtypes) {
+		case len(order):
+		case len(order) - 1:
+			// self or cls
+			order = order[1:]
+		default:
+			order = nil
+		}
+		for i, j := range order {
+			types[j] = argtypes[i]
+		}
+	}
+	list = append(nodes.Array{}, list...)
+	for i, a := range list {
+		arg, ok := a.(nodes.Object)
+		if !ok {
+			continue
+		}
+		typ, ok := arg["type_comment"]
+		if !ok && types[i] == nil {
+			continue
+		} else if !ok {
+			typ = types[i]
+		}
+		arg = arg.CloneObject()
+		delete(arg, "type_comment")
+		if arg["annotation"] == nil {
+			arg["annotation"] = typ
+			arg["type_comment"] = nodes.Bool(true)
+		}
+		list[i] = arg
+	}
+	args = args.CloneObject()
+	args["args"] = list
+	obj["args"] = args
+	return obj, true, nil
+})
+
+// hasTypeComment reports whether any of the arguments has a type comment.
+func hasTypeComment(args nodes.Array) bool {
+	for _, a := range args {
+		if arg, ok := a.(nodes.Object); ok && arg["type_comment"] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// argOrder is the order of the native argument types in the function signature.
+var argOrder = map[string]int{
+	"posonly_arg": 0,
+	"arg":         0,
+	"vararg":      1,
+	"kwonly_arg":  2,
+	"kwarg":       3,
+}
+
+// sourceOrder returns the indexes of the function arguments in the order they are
+// declared. The native AST keeps the variadic arguments after the keyword ones.
+func sourceOrder(args nodes.Array) []int {
+	order := make([]int, len(args))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return argOrder[uast.TypeOf(args[order[i]])] < argOrder[uast.TypeOf(args[order[j]])]
+	})
+	return order
+}
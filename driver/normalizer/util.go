@@ -3,6 +3,8 @@ package normalizer
 import (
 	"github.com/bblfsh/sdk/v3/uast"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/bblfsh/sdk/v3/uast/nodes"
 	. "github.com/bblfsh/sdk/v3/uast/transformer"
@@ -19,15 +21,67 @@ func num2dots(n nodes.Value, prefix string) nodes.Value {
 type OpSplitPath struct {
 	numLevel Op
 	path     Op
+	// pos is the position of the path, if known
+	pos Op
 }
 
 func (op OpSplitPath) Kinds() nodes.Kind {
 	return nodes.KindObject
 }
 
+// Check joins the identifiers back to the module path, converting the leading ".."
+// identifiers to the relative import level.
 func (op OpSplitPath) Check(st *State, n nodes.Node) (bool, error) {
-	panic("TODO") // TODO: not reversible
-	return true, nil
+	var idents []uast.Identifier
+	switch uast.TypeOf(n) {
+	case uast.TypeOf(uast.Identifier{}):
+		var id uast.Identifier
+		if err := uast.NodeAs(n, &id); err != nil {
+			return false, err
+		}
+		idents = append(idents, id)
+	case uast.TypeOf(uast.QualifiedIdentifier{}):
+		var qid uast.QualifiedIdentifier
+		if err := uast.NodeAs(n, &qid); err != nil {
+			return false, err
+		}
+		idents = qid.Names
+	default:
+		return false, nil
+	}
+
+	levels := 0
+	for levels < len(idents) && idents[levels].Name == ".." {
+		levels++
+	}
+	names := make([]string, 0, len(idents)-levels)
+	for _, id := range idents[levels:] {
+		names = append(names, id.Name)
+	}
+
+	if op.numLevel != nil {
+		if ok, err := op.numLevel.Check(st, nodes.Int(levels)); err != nil || !ok {
+			return false, err
+		}
+	} else if levels != 0 {
+		return false, nil
+	}
+
+	if op.pos != nil {
+		pos, _ := n.(nodes.Object)[uast.KeyPos].(nodes.Object)
+		if pos == nil {
+			pos = uast.Positions{}.ToObject()
+		}
+		if ok, err := op.pos.Check(st, pos); err != nil || !ok {
+			return false, err
+		}
+	}
+
+	var path nodes.Node
+	if len(names) != 0 && !(len(names) == 1 && names[0] == ".") {
+		path = nodes.String(strings.Join(names, "."))
+	}
+	return op.path.Check(st, path)
 }
 
 func (op OpSplitPath) Construct(st *State, n nodes.Node) (nodes.Node, error) {
@@ -46,6 +100,15 @@ func (op OpSplitPath) Construct(st *State, n nodes.Node) (nodes.Node, error) {
 		}
 	}
 
+	var pos uast.Positions
+	if op.pos != nil {
+		nd, err := op.pos.Construct(st, nil)
+		if err != nil {
+			return nil, err
+		}
+		pos = uast.PositionsOf(nodes.Object{uast.KeyPos: nd})
+	}
+
 	nd, err := op.path.Construct(st, n)
 	if err != nil {
 		return nil, err
@@ -59,14 +122,45 @@ func (op OpSplitPath) Construct(st *State, n nodes.Node) (nodes.Node, error) {
 		if !ok {
 			return nil, ErrUnexpectedType.New(nodes.String(""), nd)
 		}
-		for _, name := range strings.Split(string(path), ".") {
-			idents = append(idents, uast.Identifier{Name: name})
+		names := strings.Split(string(path), ".")
+		parts := splitPositions(pos, string(path), names)
+		for i, name := range names {
+			id := uast.Identifier{Name: name}
+			if parts != nil {
+				id.Positions = parts[i]
+			}
+			idents = append(idents, id)
 		}
 	}
 	if len(idents) == 1 {
+		idents[0].Positions = pos
 		return uast.ToNode(idents[0])
 	}
-	return uast.ToNode(uast.QualifiedIdentifier{Names: idents})
+	qid := uast.QualifiedIdentifier{Names: idents}
+	qid.Positions = pos
+	return uast.ToNode(qid)
+}
+
+// splitPositions returns the positions of each name of the dotted path, if the path
+// is written with no spaces or line continuations.
+func splitPositions(pos uast.Positions, path string, names []string) []uast.Positions {
+	start, end := pos.Start(), pos.End()
+	if start == nil || end == nil || int(end.Offset-start.Offset) != len(path) {
+		return nil
+	}
+	out := make([]uast.Positions, 0, len(names))
+	cur := *start
+	for _, name := range names {
+		next := cur
+		next.Offset += uint32(len(name))
+		next.Col += uint32(utf8.RuneCountInString(name))
+		out = append(out, uast.Positions{uast.KeyStart: cur, uast.KeyEnd: next})
+		// skip the dot
+		cur = next
+		cur.Offset++
+		cur.Col++
+	}
+	return out
 }
 
 type OpLevelDotsNumConv struct {
@@ -114,3 +208,249 @@ func (op OpLevelDotsNumConv) Construct(st *State, n nodes.Node) (nodes.Node, err
 }
 
 var _ Op = OpLevelDotsNumConv{}
+
+// OpIsGenerator checks the body of a function and sets the variable to true if the
+// function is a generator (it has a yield expression in the body of the function,
+// excluding the nested functions, lambdas and classes).
+type OpIsGenerator struct {
+	vr string
+	op Op
+}
+
+func (op OpIsGenerator) Kinds() nodes.Kind {
+	return op.op.Kinds()
+}
+
+func (op OpIsGenerator) Check(st *State, n nodes.Node) (bool, error) {
+	if err := st.SetVar(op.vr, nodes.Bool(hasYield(n))); err != nil {
+		return false, err
+	}
+	return op.op.Check(st, n)
+}
+
+func (op OpIsGenerator) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	return op.op.Construct(st, n)
+}
+
+var _ Op = OpIsGenerator{}
+
+// OpNumLiteral checks the spelling of a numeric literal and sets the variables to
+// its kind and typed value, as returned by numberValue. The spelling is passed to
+// the operation.
+type OpNumLiteral struct {
+	kind, value string
+	op          Op
+}
+
+func (op OpNumLiteral) Kinds() nodes.Kind {
+	return nodes.KindString
+}
+
+func (op OpNumLiteral) Check(st *State, n nodes.Node) (bool, error) {
+	s, ok := n.(nodes.String)
+	if !ok {
+		return false, nil
+	}
+	kind, val, err := numberValue(string(s))
+	if err != nil {
+		return false, nil
+	}
+	if err := st.SetVar(op.kind, nodes.String(kind)); err != nil {
+		return false, err
+	}
+	if err := st.SetVar(op.value, val); err != nil {
+		return false, err
+	}
+	return op.op.Check(st, n)
+}
+
+func (op OpNumLiteral) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	return op.op.Construct(st, n)
+}
+
+var _ Op = OpNumLiteral{}
+
+// OpNumNative checks that the native value of a number is the one of the kind and
+// typed value in the variables, as returned by nativeNumber, and constructs it from
+// them.
+type OpNumNative struct {
+	kind, value string
+}
+
+func (op OpNumNative) Kinds() nodes.Kind {
+	return nodes.KindsValues | nodes.KindObject
+}
+
+func (op OpNumNative) Check(st *State, n nodes.Node) (bool, error) {
+	exp, err := op.Construct(st, nil)
+	if err != nil {
+		return false, nil
+	}
+	return nodes.NodeEqual(exp, n), nil
+}
+
+func (op OpNumNative) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	var kind nodes.String
+	if err := st.MustGetVars(VarsPtrs{op.kind: &kind}); err != nil {
+		return nil, err
+	}
+	val, ok := st.GetVar(op.value)
+	if !ok {
+		return nil, ErrVariableNotDefined.New(op.value)
+	}
+	v, _ := val.(nodes.Value)
+	return nativeNumber(string(kind), v)
+}
+
+var _ Op = OpNumNative{}
+
+// OpNumValue sets the variables to the kind and typed value of a number, taken from
+// its native value as returned by nativeNumberValue. The native value is passed to
+// the operation.
+type OpNumValue struct {
+	kind, value string
+	op          Op
+}
+
+func (op OpNumValue) Kinds() nodes.Kind {
+	return nodes.KindsValues | nodes.KindObject
+}
+
+func (op OpNumValue) Check(st *State, n nodes.Node) (bool, error) {
+	kind, val, ok := nativeNumberValue(n)
+	if !ok {
+		return false, nil
+	}
+	if err := st.SetVar(op.kind, nodes.String(kind)); err != nil {
+		return false, err
+	}
+	if err := st.SetVar(op.value, val); err != nil {
+		return false, err
+	}
+	return op.op.Check(st, n)
+}
+
+func (op OpNumValue) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	return op.op.Construct(st, n)
+}
+
+var _ Op = OpNumValue{}
+
+// OpDocstring checks the value of a docstring and sets the variables used by
+// CommentNode: the text is cleaned up like Python's inspect.cleandoc does, with the
+// leading and trailing whitespace in the prefix and suffix and the indentation
+// common to the lines after the first one (ignoring the blank ones) in the tab.
+// The whitespace of blank lines is not kept.
+type OpDocstring struct {
+	vr string
+}
+
+func (op OpDocstring) Kinds() nodes.Kind {
+	return nodes.KindString
+}
+
+func (op OpDocstring) Check(st *State, n nodes.Node) (bool, error) {
+	s, ok := n.(nodes.String)
+	if !ok {
+		return false, nil
+	}
+	text, pref, suff, tab := splitDocstring(string(s))
+	err := st.SetVars(Vars{
+		op.vr + "_text": nodes.String(text),
+		op.vr + "_pref": nodes.String(pref),
+		op.vr + "_suff": nodes.String(suff),
+		op.vr + "_tab":  nodes.String(tab),
+	})
+	return err == nil, err
+}
+
+func (op OpDocstring) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	var text, pref, suff, tab nodes.String
+	err := st.MustGetVars(VarsPtrs{
+		op.vr + "_text": &text,
+		op.vr + "_pref": &pref,
+		op.vr + "_suff": &suff,
+		op.vr + "_tab":  &tab,
+	})
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(text), "\n")
+	for i, line := range lines {
+		if i != 0 && strings.TrimSpace(line) != "" {
+			lines[i] = string(tab) + line
+		}
+	}
+	return nodes.String(string(pref) + strings.Join(lines, "\n") + string(suff)), nil
+}
+
+var _ Op = OpDocstring{}
+
+// splitDocstring returns the text of the docstring without indentation and the
+// whitespace removed from it.
+func splitDocstring(s string) (text, pref, suff, tab string) {
+	text = strings.TrimLeftFunc(s, unicode.IsSpace)
+	pref = s[:len(s)-len(text)]
+	text = strings.TrimRightFunc(text, unicode.IsSpace)
+	suff = s[len(pref)+len(text):]
+
+	lines := strings.Split(text, "\n")
+	first := true
+	for _, line := range lines[1:] {
+		content := strings.TrimLeftFunc(line, unicode.IsSpace)
+		if content == "" {
+			continue
+		}
+		indent := line[:len(line)-len(content)]
+		if first {
+			tab, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, tab) {
+			tab = tab[:len(tab)-1]
+		}
+	}
+	for i, line := range lines[1:] {
+		// the blank lines are kept as is, so the docstring can be restored
+		if strings.TrimSpace(line) != "" {
+			lines[i+1] = line[len(tab):]
+		}
+	}
+	return strings.Join(lines, "\n"), pref, suff, tab
+}
+
+// hasYield checks if there is a yield expression in the node, without going into
+// the scopes of the nested functions, lambdas and classes. Only the bodies of those
+// are skipped, because the default values of arguments, decorators and base classes
+// are evaluated in the current scope.
+func hasYield(n nodes.Node) bool {
+	switch n := n.(type) {
+	case nodes.Array:
+		for _, e := range n {
+			if hasYield(e) {
+				return true
+			}
+		}
+	case nodes.Object:
+		skip := ""
+		switch uast.TypeOf(n) {
+		case "Yield", "YieldFrom":
+			return true
+		case "Lambda":
+			skip = "body"
+		case uast.TypeOf(uast.Function{}):
+			skip = "Body"
+		case uast.TypeOf(uast.Alias{}):
+			// classes are bound to their body with an alias
+			if uast.TypeOf(n["Node"]) == uast.TypeOf(uast.Block{}) {
+				skip = "Node"
+			}
+		}
+		for k, v := range n {
+			if k != skip && hasYield(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
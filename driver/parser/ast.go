@@ -0,0 +1,195 @@
+package parser
+
+// node is a Python AST node in the form exported by the pydetector package:
+// a dictionary with an "ast_type" key, all the AST fields and position attributes.
+type node = map[string]interface{}
+
+// position is a position of the AST node as reported by CPython 3.6: 1-based line
+// and 0-based byte column.
+type position struct {
+	Line int
+	Col  int
+}
+
+// astFields lists the fields of the Python 3.6 AST nodes in the grammar order.
+var astFields = map[string][]string{
+	"Module": {"body"},
+
+	"FunctionDef":      {"name", "args", "body", "decorator_list", "returns"},
+	"AsyncFunctionDef": {"name", "args", "body", "decorator_list", "returns"},
+	"ClassDef":         {"name", "bases", "keywords", "body", "decorator_list"},
+	"Return":           {"value"},
+	"Delete":           {"targets"},
+	"Assign":           {"targets", "value"},
+	"AugAssign":        {"target", "op", "value"},
+	"AnnAssign":        {"target", "annotation", "value", "simple"},
+	"For":              {"target", "iter", "body", "orelse"},
+	"AsyncFor":         {"target", "iter", "body", "orelse"},
+	"While":            {"test", "body", "orelse"},
+	"If":               {"test", "body", "orelse"},
+	"With":             {"items", "body"},
+	"AsyncWith":        {"items", "body"},
+	"Raise":            {"exc", "cause"},
+	"Try":              {"body", "handlers", "orelse", "finalbody"},
+	"Assert":           {"test", "msg"},
+	"Import":           {"names"},
+	"ImportFrom":       {"module", "names", "level"},
+	"Global":           {"names"},
+	"Nonlocal":         {"names"},
+	"Expr":             {"value"},
+	"Pass":             {},
+	"Break":            {},
+	"Continue":         {},
+	"Match":            {"subject", "cases"},
+
+	"BoolOp":         {"op", "values"},
+	"NamedExpr":      {"target", "value"},
+	"BinOp":          {"left", "op", "right"},
+	"UnaryOp":        {"op", "operand"},
+	"Lambda":         {"args", "body"},
+	"IfExp":          {"test", "body", "orelse"},
+	"Dict":           {"keys", "values"},
+	"Set":            {"elts"},
+	"ListComp":       {"elt", "generators"},
+	"SetComp":        {"elt", "generators"},
+	"DictComp":       {"key", "value", "generators"},
+	"GeneratorExp":   {"elt", "generators"},
+	"Await":          {"value"},
+	"Yield":          {"value"},
+	"YieldFrom":      {"value"},
+	"Compare":        {"left", "ops", "comparators"},
+	"Call":           {"func", "args", "keywords"},
+	"Num":            {"n"},
+	"Str":            {"s"},
+	"FormattedValue": {"value", "conversion", "format_spec"},
+	"JoinedStr":      {"values"},
+	"Bytes":          {"s"},
+	"NameConstant":   {"value"},
+	"Ellipsis":       {},
+	"Attribute":      {"value", "attr", "ctx"},
+	"Subscript":      {"value", "slice", "ctx"},
+	"Starred":        {"value", "ctx"},
+	"Name":           {"id", "ctx"},
+	"List":           {"elts", "ctx"},
+	"Tuple":          {"elts", "ctx"},
+
+	"Slice":    {"lower", "upper", "step"},
+	"ExtSlice": {"dims"},
+	"Index":    {"value"},
+
+	"comprehension": {"target", "iter", "ifs", "is_async"},
+	"ExceptHandler": {"type", "name", "body"},
+	"arguments":     {"posonlyargs", "args", "vararg", "kwonlyargs", "kw_defaults", "kwarg", "defaults"},
+	"arg":           {"arg", "annotation"},
+	"keyword":       {"arg", "value"},
+	"alias":         {"name", "asname"},
+	"withitem":      {"context_expr", "optional_vars"},
+	"match_case":    {"pattern", "guard", "body"},
+
+	"MatchValue":     {"value"},
+	"MatchSingleton": {"value"},
+	"MatchSequence":  {"patterns"},
+	"MatchMapping":   {"keys", "patterns", "rest"},
+	"MatchClass":     {"cls", "patterns", "kwd_attrs", "kwd_patterns"},
+	"MatchStar":      {"name"},
+	"MatchAs":        {"pattern", "name"},
+	"MatchOr":        {"patterns"},
+}
+
+// astFields2 lists the fields of the Python 2.7 AST nodes that are not in the Python
+// 3.6 AST or that have other fields there.
+var astFields2 = map[string][]string{
+	"FunctionDef": {"name", "args", "body", "decorator_list"},
+	"ClassDef":    {"name", "bases", "body", "decorator_list"},
+	"With":        {"context_expr", "optional_vars", "body"},
+	"Raise":       {"type", "inst", "tback"},
+	"TryExcept":   {"body", "handlers", "orelse"},
+	"TryFinally":  {"body", "finalbody"},
+	"Print":       {"dest", "values", "nl"},
+	"Exec":        {"body", "globals", "locals"},
+
+	"Repr": {"value"},
+	"Call": {"func", "args", "keywords", "starargs", "kwargs"},
+
+	"comprehension": {"target", "iter", "ifs"},
+	"arguments":     {"args", "vararg", "kwarg", "defaults"},
+}
+
+const (
+	ctxLoad  = "Load"
+	ctxStore = "Store"
+	ctxDel   = "Del"
+	// ctxParam is the context of the parameters in the Python 2 AST
+	ctxParam = "Param"
+)
+
+// newNode creates an AST node of a given type. Values are assigned to fields in the
+// order listed in astFields. Position is only set for nodes that have one.
+func newNode(typ string, pos *position, values ...interface{}) node {
+	return makeNode(typ, astFields[typ], pos, values)
+}
+
+// newNode2 creates a Python 2 AST node, with the fields listed in astFields2 or in
+// astFields for the nodes that are the same in both versions.
+func newNode2(typ string, pos *position, values ...interface{}) node {
+	fields, ok := astFields2[typ]
+	if !ok {
+		fields = astFields[typ]
+	}
+	return makeNode(typ, fields, pos, values)
+}
+
+func makeNode(typ string, fields []string, pos *position, values []interface{}) node {
+	if len(values) != len(fields) {
+		panic("wrong number of fields for " + typ)
+	}
+	n := node{
+		"ast_type": typ,
+		"_fields":  fields,
+	}
+	for i, f := range fields {
+		v := values[i]
+		if l, ok := v.([]node); ok {
+			// store lists in a generic form
+			arr := make([]interface{}, 0, len(l))
+			for _, e := range l {
+				if e == nil {
+					arr = append(arr, nil)
+				} else {
+					arr = append(arr, e)
+				}
+			}
+			v = arr
+		}
+		if e, ok := v.(node); ok && e == nil {
+			v = nil
+		}
+		n[f] = v
+	}
+	if pos != nil {
+		n["lineno"] = pos.Line
+		n["col_offset"] = pos.Col
+	}
+	return n
+}
+
+// opNode creates an operator or a comparator node.
+func opNode(typ string) node {
+	return node{"ast_type": typ, "_fields": []string{}}
+}
+
+func nodeType(n node) string {
+	s, _ := n["ast_type"].(string)
+	return s
+}
+
+func nodePos(n node) position {
+	line, _ := n["lineno"].(int)
+	col, _ := n["col_offset"].(int)
+	return position{Line: line, Col: col}
+}
+
+func setPos(n node, p position) {
+	n["lineno"] = p.Line
+	n["col_offset"] = p.Col
+}
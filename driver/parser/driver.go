@@ -0,0 +1,244 @@
+// Package parser implements the native Python driver in Go.
+//
+// It produces the same AST as the Python part of the driver (pydetector output
+// processed by the AstImprover), so the native driver process is not required to
+// parse Python 3 and Python 2 code.
+package parser
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+var _ driver.Native = (*Driver)(nil)
+
+// Driver is a native Python driver that does not require an external process.
+type Driver struct {
+	// Version is the version of the grammar used for the requests that don't select
+	// one with WithVersion or with the MetadataKey of the gRPC metadata.
+	Version Version
+}
+
+// NewDriver creates a new native Python driver.
+func NewDriver() *Driver {
+	return &Driver{}
+}
+
+// Start implements driver.Native.
+func (d *Driver) Start() error {
+	return nil
+}
+
+// Close implements driver.Native.
+func (d *Driver) Close() error {
+	return nil
+}
+
+// Parse implements driver.Native. The partial AST of the code with syntax errors is
+// returned with them, and the SDK reports each of them as an error of the response.
+//
+// The version of the grammar used is sent in the MetadataKey header of the gRPC
+// response, and is also the root key of the AST.
+func (d *Driver) Parse(ctx context.Context, src string) (nodes.Node, error) {
+	v, ok, err := requestedVersion(ctx)
+	if err != nil {
+		return nil, driver.ErrDriverFailure.Wrap(err)
+	} else if !ok {
+		v = d.Version
+	}
+	ast, v, err := ParseVersion(src, v)
+	if ast != nil {
+		sendVersion(ctx, v)
+	}
+	if err != nil {
+		if !isSyntaxError(err) {
+			return nil, driver.ErrDriverFailure.Wrap(err)
+		}
+		return ast, err
+	}
+	return ast, nil
+}
+
+// isSyntaxError checks if the error is a SyntaxError or a list of them.
+func isSyntaxError(err error) bool {
+	if e, ok := err.(*driver.ErrMulti); ok {
+		for _, err := range e.Errors {
+			if !isSyntaxError(err) {
+				return false
+			}
+		}
+		return true
+	}
+	_, ok := err.(*SyntaxError)
+	return ok
+}
+
+// Parse parses the Python source and returns the native AST. A SyntaxError is
+// returned if the code cannot be parsed. The statements with syntax errors are left
+// out of the AST, that is returned with the errors: a SyntaxError, or a
+// driver.ErrMulti with one SyntaxError for each statement. If the code cannot be
+// tokenized, the AST has the statements before the error.
+//
+// The source is decoded as described in Source, and the lines and columns of the AST
+// are the ones of the decoded code. The version of the grammar is detected as
+// described in Auto.
+func Parse(src string) (nodes.Node, error) {
+	ast, _, err := ParseVersion(src, Auto)
+	return ast, err
+}
+
+// ParseVersion is like Parse, with the version of the grammar given. It also returns
+// the version used, that is never Auto. The code that has syntax errors in both
+// versions is reported as Python 3.
+func ParseVersion(src string, v Version) (nodes.Node, Version, error) {
+	s, err := Decode(src)
+	if err != nil {
+		return nil, Python3, err
+	}
+	switch v {
+	case Python2, Python3:
+		ast, err := parse(s.Text, v)
+		return ast, v, err
+	}
+	ast, err := parse(s.Text, Python3)
+	if err == nil || !isSyntaxError(err) {
+		return ast, Python3, err
+	}
+	if ast2, err := parse(s.Text, Python2); err == nil {
+		return ast2, Python2, nil
+	}
+	return ast, Python3, err
+}
+
+// parse parses the source decoded to UTF-8 with the grammar of the version.
+func parse(src string, v Version) (nodes.Node, error) {
+	if src == "" {
+		// module with an empty code (like __init__.py) still has a semantic meaning
+		return nodes.Object{v.rootKey(): nodes.Object{
+			"ast_type":   nodes.String("Module"),
+			"lineno":     nodes.Int(1),
+			"col_offset": nodes.Int(1),
+		}}, nil
+	}
+	toks, tokErr := tokenize(src)
+	mod, errs := parseModule(toks, v == Python2)
+	if e, ok := tokErr.(*SyntaxError); ok {
+		errs = append(errs, e)
+	} else if tokErr != nil {
+		return nil, tokErr
+	}
+	imp, err := newImprover(src, toks)
+	if err != nil {
+		return nil, err
+	}
+	mod, err = imp.improve(mod)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) != 0 {
+		mod["syntax_errors"] = errorNodes(errs)
+	}
+	ast, err := nodes.ToNode(map[string]interface{}{
+		v.rootKey(): toValue(mod),
+	}, nil)
+	if err != nil || len(errs) == 0 {
+		return ast, err
+	}
+	list := make([]error, 0, len(errs))
+	for _, e := range errs {
+		list = append(list, e)
+	}
+	return ast, driver.JoinErrors(list)
+}
+
+// errorNodes returns the SyntaxError nodes of the errors, that are added to the module
+// of a partial AST, so the positions of the errors are structured and transformed like
+// the other ones.
+func errorNodes(errs []*SyntaxError) []interface{} {
+	out := make([]interface{}, 0, len(errs))
+	for _, e := range errs {
+		n := node{
+			"ast_type":   "SyntaxError",
+			"msg":        e.Msg,
+			"lineno":     e.Line,
+			"col_offset": e.Col,
+		}
+		if e.EndLine != 0 {
+			n["end_lineno"] = e.EndLine
+			n["end_col_offset"] = e.EndCol
+		}
+		normalizePosition(n)
+		out = append(out, n)
+	}
+	return out
+}
+
+// ParseExpression parses a single Python 3 expression, like the string annotations
+// evaluated by the typing module, and returns its native AST. A SyntaxError is
+// returned if the code is not an expression.
+func ParseExpression(src string) (nodes.Node, error) {
+	ast, err := parse(src, Python3)
+	if err != nil {
+		return nil, err
+	}
+	mod, _ := ast.(nodes.Object)["PY3AST"].(nodes.Object)
+	body, _ := mod["body"].(nodes.Array)
+	if len(body) != 1 {
+		return nil, &SyntaxError{Msg: "invalid syntax", Line: 1, Col: 1}
+	}
+	st, _ := body[0].(nodes.Object)
+	if st["ast_type"] != nodes.String("Expr") {
+		return nil, &SyntaxError{Msg: "invalid syntax", Line: 1, Col: 1}
+	}
+	// the improver adds an empty comment at the end of the line
+	return dropNoops(st["value"]), nil
+}
+
+// dropNoops removes the comment nodes from the AST.
+func dropNoops(n nodes.Node) nodes.Node {
+	switch n := n.(type) {
+	case nodes.Object:
+		out := make(nodes.Object, len(n))
+		for k, v := range n {
+			if k != "noops_previous" && k != "noops_sameline" {
+				out[k] = dropNoops(v)
+			}
+		}
+		return out
+	case nodes.Array:
+		out := make(nodes.Array, 0, len(n))
+		for _, e := range n {
+			out = append(out, dropNoops(e))
+		}
+		return out
+	}
+	return n
+}
+
+// toValue converts the AST to the form it would have after JSON serialization.
+func toValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case node:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = toValue(e)
+		}
+		return m
+	case []interface{}:
+		arr := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			arr = append(arr, toValue(e))
+		}
+		return arr
+	case *pyNumber:
+		if v.Int == nil {
+			return v.Float
+		}
+		f, _ := new(big.Float).SetInt(v.Int).Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,288 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+)
+
+// utf8BOM is the byte order mark of UTF-8, that the Python tokenizer skips.
+const utf8BOM = "\xef\xbb\xbf"
+
+// reCodingCookie matches the encoding declaration of PEP 263 in one of the first two
+// lines of the source.
+var reCodingCookie = regexp.MustCompile(`^[ \t\f]*#.*?coding[:=][ \t]*([-\w.]+)`)
+
+// reBlankLine matches the lines that are empty or have only a comment. The encoding
+// declaration is only searched in the second line if the first one is like this.
+var reBlankLine = regexp.MustCompile(`^[ \t\f]*(?:[#\r\n]|$)`)
+
+// Source is Python source code decoded to UTF-8 as the interpreter does it: the UTF-8
+// byte order mark and the encoding declarations of PEP 263 are honoured, and the code
+// without them must be valid UTF-8.
+//
+// The lines of the decoded code are the ones of the original code, and the columns in
+// characters of the decoded code can be converted to byte offsets in the original one
+// with Offset.
+type Source struct {
+	// Text is the decoded code, without the byte order mark.
+	Text string
+	// Encoding is the name of the encoding of the code, normalized as the tokenize
+	// module does: "utf-8", "iso-8859-1" or the name in the declaration.
+	Encoding string
+
+	// starts are the offsets of the lines of the original code, after the byte order
+	// mark. The lines with characters that are not ASCII have the offsets of their
+	// characters (and of the end of the line) in cols, relative to the line start.
+	starts []int
+	cols   [][]int
+	size   int
+}
+
+// Decode detects the encoding of the Python source and decodes it. A SyntaxError is
+// returned if the declared encoding is unknown, does not match the byte order mark or
+// cannot decode the code.
+func Decode(src string) (*Source, error) {
+	s := &Source{Encoding: "utf-8", size: len(src)}
+	bom := strings.HasPrefix(src, utf8BOM)
+	body := src
+	if bom {
+		body = src[len(utf8BOM):]
+		s.Encoding = "utf-8-sig"
+	}
+	var enc encoding.Encoding
+	name, line, declared := codingCookie(body)
+	if declared {
+		norm := normalEncoding(name)
+		if bom && norm != "utf-8" {
+			return nil, &SyntaxError{Msg: fmt.Sprintf("encoding problem: %s with BOM", name), Line: line}
+		}
+		var ok bool
+		if enc, ok = lookupEncoding(norm); !ok {
+			return nil, &SyntaxError{Msg: fmt.Sprintf("unknown encoding: %s", name), Line: line}
+		}
+		if enc != nil {
+			s.Encoding = norm
+		}
+	}
+	switch {
+	case enc != nil:
+		text, err := enc.NewDecoder().String(body)
+		if err != nil {
+			return nil, &SyntaxError{Msg: fmt.Sprintf("(unicode error) '%s' codec can't decode the source: %v", s.Encoding, err), Line: 1}
+		}
+		s.Text = text
+	case utf8.ValidString(body):
+		s.Text = body
+	default:
+		return nil, decodeError(body)
+	}
+
+	var e *encoding.Encoder
+	if enc != nil {
+		e = enc.NewEncoder()
+	}
+	off := len(src) - len(body)
+	lines := splitLines(body)
+	s.starts = make([]int, 0, len(lines))
+	s.cols = make([][]int, 0, len(lines))
+	for _, l := range lines {
+		s.starts = append(s.starts, off)
+		off += len(l)
+	}
+	for _, l := range splitLines(s.Text) {
+		s.cols = append(s.cols, lineCols(l, e))
+	}
+	return s, nil
+}
+
+// lineCols returns the offsets of the characters of a line of the decoded code in the
+// original line, followed by its size, or nil if the line is ASCII. The encoder is
+// the one of the original code, or nil for UTF-8.
+func lineCols(line string, e *encoding.Encoder) []int {
+	if isASCII(line) {
+		return nil
+	}
+	cols := make([]int, 1, len(line)+1)
+	off := 0
+	for _, r := range line {
+		off += runeSize(r, e)
+		cols = append(cols, off)
+	}
+	return cols
+}
+
+// runeSize returns the size of a character in the original code.
+func runeSize(r rune, e *encoding.Encoder) int {
+	if e == nil || r < utf8.RuneSelf {
+		return utf8.RuneLen(r)
+	}
+	b, err := e.String(string(r))
+	if err != nil || b == "" {
+		// the character replaces a byte that cannot be decoded
+		return 1
+	}
+	return len(b)
+}
+
+// Offset converts a position in the decoded code, with a 1-based line and a 1-based
+// column in characters, to a 0-based byte offset in the original code. The positions
+// out of the code are moved to the closest end of it.
+func (s *Source) Offset(line, col int) int {
+	switch {
+	case line < 1:
+		return 0
+	case line > len(s.starts):
+		return s.size
+	}
+	start := s.starts[line-1]
+	if col < 1 {
+		col = 1
+	}
+	if cols := s.cols[line-1]; cols != nil {
+		if col > len(cols) {
+			col = len(cols)
+		}
+		return start + cols[col-1]
+	}
+	end := s.size
+	if line < len(s.starts) {
+		end = s.starts[line]
+	}
+	if off := start + col - 1; off < end {
+		return off
+	}
+	return end
+}
+
+// codingCookie returns the encoding declared in the first two lines of the code, and
+// the line of the declaration.
+func codingCookie(src string) (string, int, bool) {
+	lines := splitLines(src)
+	for i := 0; i < 2 && i < len(lines); i++ {
+		if m := reCodingCookie.FindStringSubmatch(lines[i]); m != nil {
+			return m[1], i + 1, true
+		}
+		if !reBlankLine.MatchString(lines[i]) {
+			break
+		}
+	}
+	return "", 0, false
+}
+
+// normalEncoding normalizes the name of an encoding like the get_normal_name function
+// of the tokenize module, that only knows the aliases of UTF-8 and Latin-1.
+func normalEncoding(name string) string {
+	enc := strings.Replace(strings.ToLower(name), "_", "-", -1)
+	if len(enc) > 12 {
+		enc = enc[:12]
+	}
+	if enc == "utf-8" || strings.HasPrefix(enc, "utf-8-") {
+		return "utf-8"
+	}
+	for _, p := range []string{"latin-1", "iso-8859-1", "iso-latin-1"} {
+		if enc == p || strings.HasPrefix(enc, p+"-") {
+			return "iso-8859-1"
+		}
+	}
+	return name
+}
+
+// pyEncodings are the aliases of the Python codecs that are not in the IANA and WHATWG
+// registries, or that have a different meaning there.
+var pyEncodings = map[string]encoding.Encoding{
+	"utf8":      nil,
+	"latin1":    charmap.ISO8859_1,
+	"l1":        charmap.ISO8859_1,
+	"latin":     charmap.ISO8859_1,
+	"cp819":     charmap.ISO8859_1,
+	"ascii":     asciiEncoding{},
+	"646":       asciiEncoding{},
+	"us-ascii":  asciiEncoding{},
+	"latin9":    charmap.ISO8859_15,
+	"l9":        charmap.ISO8859_15,
+	"cp437":     charmap.CodePage437,
+	"cp850":     charmap.CodePage850,
+	"cp866":     charmap.CodePage866,
+	"koi8-r":    charmap.KOI8R,
+	"koi8-u":    charmap.KOI8U,
+	"mac-roman": charmap.Macintosh,
+}
+
+// lookupEncoding returns the encoding with the normalized name, or nil for UTF-8.
+func lookupEncoding(name string) (encoding.Encoding, bool) {
+	if name == "utf-8" {
+		return nil, true
+	}
+	key := strings.ToLower(name)
+	if enc, ok := pyEncodings[strings.Replace(key, "_", "-", -1)]; ok {
+		return enc, true
+	}
+	for _, key := range []string{key, strings.Replace(key, "_", "-", -1), strings.Replace(key, "-", "_", -1)} {
+		if enc, err := ianaindex.IANA.Encoding(key); err == nil && enc != nil {
+			return enc, true
+		}
+		if enc, err := htmlindex.Get(key); err == nil {
+			return enc, true
+		}
+	}
+	return nil, false
+}
+
+// decodeError returns the error of the code that is not valid UTF-8, at the first
+// invalid byte.
+func decodeError(src string) *SyntaxError {
+	line, col := 1, 0
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return &SyntaxError{
+				Msg:  fmt.Sprintf("(unicode error) 'utf-8' codec can't decode byte 0x%02x", src[i]),
+				Line: line, Col: col,
+			}
+		}
+		if r == '\n' {
+			line, col = line+1, 0
+		} else {
+			col++
+		}
+		i += size
+	}
+	return &SyntaxError{Msg: "(unicode error) 'utf-8' codec can't decode the source", Line: line, Col: col}
+}
+
+// asciiEncoding is the ASCII codec of Python, that fails on the bytes over 0x7f. The
+// WHATWG registry decodes them as Windows-1252.
+type asciiEncoding struct{}
+
+func (asciiEncoding) NewDecoder() *encoding.Decoder {
+	return &encoding.Decoder{Transformer: asciiTransformer{}}
+}
+
+func (asciiEncoding) NewEncoder() *encoding.Encoder {
+	return &encoding.Encoder{Transformer: asciiTransformer{}}
+}
+
+// asciiTransformer copies the ASCII bytes, in both directions.
+type asciiTransformer struct{ transform.NopResetter }
+
+func (asciiTransformer) Transform(dst, src []byte, atEOF bool) (int, int, error) {
+	n := 0
+	for ; n < len(src); n++ {
+		if n == len(dst) {
+			return n, n, transform.ErrShortDst
+		}
+		if src[n] >= utf8.RuneSelf {
+			return n, n, fmt.Errorf("byte 0x%02x: ordinal not in range(128)", src[n])
+		}
+		dst[n] = src[n]
+	}
+	return n, n, nil
+}
@@ -0,0 +1,331 @@
+package parser
+
+import (
+	"strings"
+)
+
+// strings parses a sequence of adjacent string tokens into a Str, Bytes or JoinedStr node.
+func (p *parser) strings() node {
+	pos := p.pos()
+	first := p.tok()
+	var lits []*strLiteral
+	for p.is(tokString) {
+		t := p.next()
+		lit, err := decodeString(t.Value)
+		if err != nil {
+			p.errorAt(t, "%v", err)
+		}
+		lits = append(lits, lit)
+	}
+	isBytes, format := lits[0].Bytes, false
+	for _, l := range lits {
+		if l.Bytes != isBytes {
+			p.errorAt(first, "cannot mix bytes and nonbytes literals")
+		}
+		format = format || l.Format
+	}
+	if !format {
+		var b strings.Builder
+		for _, l := range lits {
+			b.WriteString(l.Value)
+		}
+		if isBytes {
+			return newNode("Bytes", &pos, b.String())
+		}
+		return newNode("Str", &pos, b.String())
+	}
+	f := &fstringParser{p: p, pos: pos, tok: first}
+	for _, l := range lits {
+		if !l.Format {
+			f.addLiteral(l.Value)
+			continue
+		}
+		s := l.Value
+		f.concat(&s, l.Raw, 0)
+		if s != "" {
+			p.errorAt(first, "f-string: single '}' is not allowed")
+		}
+	}
+	return f.finish()
+}
+
+// fstringParser is a port of the FstringParser from the CPython 3.6 compiler.
+type fstringParser struct {
+	p   *parser
+	pos position
+	// tok is the first string token of the atom
+	tok *token
+
+	last  *string
+	exprs []interface{}
+}
+
+func (f *fstringParser) errorf(format string, args ...interface{}) {
+	f.p.errorAt(f.tok, format, args...)
+}
+
+func (f *fstringParser) addLiteral(s string) {
+	if s == "" {
+		return
+	}
+	if f.last == nil {
+		f.last = &s
+		return
+	}
+	*f.last += s
+}
+
+func (f *fstringParser) flush() {
+	if f.last != nil {
+		f.exprs = append(f.exprs, newNode("Str", &f.pos, *f.last))
+		f.last = nil
+	}
+}
+
+func (f *fstringParser) finish() node {
+	f.flush()
+	if f.exprs == nil {
+		f.exprs = []interface{}{}
+	}
+	return newNode("JoinedStr", &f.pos, f.exprs)
+}
+
+// concat parses the f-string body, consuming the text from s. In format specs it stops
+// at the closing brace.
+func (f *fstringParser) concat(s *string, raw bool, lvl int) {
+	for {
+		lit := f.literal(s, raw, lvl)
+		f.addLiteral(lit)
+		if *s == "" || (*s)[0] == '}' {
+			return
+		}
+		e := f.expression(s, raw, lvl)
+		f.flush()
+		f.exprs = append(f.exprs, e)
+	}
+}
+
+// literal consumes the literal part of the f-string up to the next expression.
+func (f *fstringParser) literal(s *string, raw bool, lvl int) string {
+	var b strings.Builder
+	str := *s
+	i := 0
+	for i < len(str) {
+		c := str[i]
+		if !raw && c == '\\' && strings.HasPrefix(str[i:], `\N{`) {
+			// named unicode escapes may contain braces
+			if j := strings.IndexByte(str[i:], '}'); j >= 0 {
+				b.WriteString(str[i : i+j+1])
+				i += j + 1
+				continue
+			}
+		}
+		if c == '{' || c == '}' {
+			if lvl == 0 {
+				if i+1 < len(str) && str[i+1] == c {
+					// doubled braces are a literal brace
+					b.WriteByte(c)
+					i += 2
+					continue
+				}
+				if c == '}' {
+					f.errorf("f-string: single '}' is not allowed")
+				}
+			}
+			break
+		}
+		b.WriteByte(c)
+		i++
+	}
+	*s = str[i:]
+	lit := b.String()
+	if !raw {
+		v, err := unescape(lit, false)
+		if err != nil {
+			f.errorf("%v", err)
+		}
+		lit = v
+	}
+	return lit
+}
+
+// expression consumes the f-string expression starting at the opening brace.
+func (f *fstringParser) expression(s *string, raw bool, lvl int) node {
+	if lvl >= 2 {
+		f.errorf("f-string: expressions nested too deeply")
+	}
+	str := (*s)[1:]
+	var (
+		quote  byte
+		triple bool
+		nested int
+		i      int
+	)
+loop:
+	for ; i < len(str); i++ {
+		c := str[i]
+		if c == '\\' {
+			f.errorf("f-string expression part cannot include a backslash")
+		}
+		if quote != 0 {
+			if c == quote {
+				if !triple {
+					quote = 0
+				} else if strings.HasPrefix(str[i:], strings.Repeat(string(quote), 3)) {
+					i += 2
+					quote, triple = 0, false
+				}
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = c
+			if strings.HasPrefix(str[i:], strings.Repeat(string(c), 3)) {
+				triple = true
+				i += 2
+			}
+		case '[', '(', '{':
+			nested++
+		case ']', ')', '}':
+			if c == '}' && nested == 0 {
+				break loop
+			}
+			nested--
+		case '#':
+			f.errorf("f-string expression part cannot include '#'")
+		case '!':
+			if i+1 < len(str) && str[i+1] == '=' {
+				i++
+				continue
+			}
+			if nested == 0 {
+				break loop
+			}
+		case '=':
+			// comparisons are not the self-documenting specifier
+			if i+1 < len(str) && str[i+1] == '=' {
+				i++
+				continue
+			}
+			if i > 0 && strings.IndexByte("<>=!", str[i-1]) >= 0 {
+				continue
+			}
+			if nested == 0 {
+				break loop
+			}
+		case ':':
+			if nested == 0 {
+				break loop
+			}
+		}
+	}
+	if quote != 0 {
+		f.errorf("f-string: unterminated string")
+	}
+	if i >= len(str) {
+		f.errorf("f-string: expecting '}'")
+	}
+	text := str[:i]
+	if strings.TrimSpace(text) == "" {
+		f.errorf("f-string: empty expression not allowed")
+	}
+	expr := f.compile(text)
+	str = str[i:]
+
+	// self-documenting expressions (Python 3.8) are preceded by their own text,
+	// including the equal sign and the whitespace around it
+	debug := false
+	if str[0] == '=' {
+		j := 1
+		for j < len(str) && strings.IndexByte(" \t\f", str[j]) >= 0 {
+			j++
+		}
+		f.addLiteral(text + str[:j])
+		str = str[j:]
+		debug = true
+	}
+
+	conversion := -1
+	if str[0] == '!' {
+		if len(str) < 2 {
+			f.errorf("f-string: expecting '}'")
+		}
+		switch str[1] {
+		case 's', 'r', 'a':
+			conversion = int(str[1])
+		default:
+			f.errorf("f-string: invalid conversion character: expected 's', 'r', or 'a'")
+		}
+		str = str[2:]
+	}
+	var spec node
+	if str != "" && str[0] == ':' {
+		str = str[1:]
+		sub := &fstringParser{p: f.p, pos: f.pos, tok: f.tok}
+		sub.concat(&str, raw, lvl+1)
+		spec = sub.finish()
+	}
+	if str == "" || str[0] != '}' {
+		f.errorf("f-string: expecting '}'")
+	}
+	if debug && conversion == -1 && spec == nil {
+		conversion = 'r'
+	}
+	*s = str[1:]
+	return newNode("FormattedValue", &f.pos, expr, conversion, spec)
+}
+
+// compile parses the f-string expression and fixes the positions of its nodes
+// the same way CPython 3.6 does.
+func (f *fstringParser) compile(text string) node {
+	toks, err := tokenize("(" + text + ")")
+	if err != nil {
+		f.errorf("f-string: invalid syntax")
+	}
+	sub := newParser(toks)
+	sub.async = f.p.async
+	var e node
+	func() {
+		defer sub.recover(&err)
+		e = sub.evalInput()
+	}()
+	if err != nil {
+		f.errorf("f-string: invalid syntax")
+	}
+
+	lines := f.pos.Line - 1
+	cols := f.pos.Col
+	str := f.tok.Value
+	if idx := strings.Index(str, "{"+text+"}"); idx >= 0 {
+		start := idx
+		for start > 0 && str[start] != '\n' {
+			start--
+		}
+		cols += idx - start
+		lines -= strings.Count(str[idx+1:], "\n")
+	}
+	shiftPositions(e, lines, cols)
+	return e
+}
+
+// shiftPositions moves all the nodes of the tree. Columns are only changed for the
+// nodes on the first line.
+func shiftPositions(v interface{}, lines, cols int) {
+	switch v := v.(type) {
+	case node:
+		if line, ok := v["lineno"].(int); ok {
+			if line == 1 {
+				v["col_offset"] = v["col_offset"].(int) + cols
+			}
+			v["lineno"] = line + lines
+		}
+		for _, f := range v["_fields"].([]string) {
+			shiftPositions(v[f], lines, cols)
+		}
+	case []interface{}:
+		for _, e := range v {
+			shiftPositions(e, lines, cols)
+		}
+	}
+}
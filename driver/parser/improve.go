@@ -0,0 +1,314 @@
+package parser
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+// improver converts the Python AST to the form expected by the driver: it fixes the
+// positions of the nodes, attaches comments and blank lines to them and normalizes
+// a few node types.
+type improver struct {
+	noops *noopExtractor
+	pos   *locationFixer
+}
+
+func newImprover(code string, toks []token) (*improver, error) {
+	lines, err := tokenizedLines(code, toks)
+	if err != nil {
+		return nil, err
+	}
+	return &improver{
+		noops: newNoopExtractor(lines),
+		pos:   &locationFixer{lines: lines},
+	}, nil
+}
+
+// improve processes the root node of the AST.
+func (v *improver) improve(n node) (res node, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(improveError)
+			if !ok {
+				panic(r)
+			}
+			err = e.err
+		}
+	}()
+	return v.visit(n, true), nil
+}
+
+type improveError struct {
+	err error
+}
+
+func (v *improver) check(err error) {
+	if err != nil {
+		panic(improveError{err: err})
+	}
+}
+
+func normalizePosition(n node) {
+	// Python AST gives a 0-based column, but UAST uses 1-based ones
+	if col, ok := n["col_offset"].(int); ok {
+		n["col_offset"] = maxInt(col+1, 1)
+	}
+	if col, ok := n["end_col_offset"].(int); ok {
+		n["end_col_offset"] = maxInt(col+1, 1)
+	}
+}
+
+func removeInternal(n node) {
+	delete(n, "_fields")
+}
+
+func (v *improver) visit(n node, root bool) node {
+	var res node
+	switch nodeType(n) {
+	case "Bytes":
+		res = v.visitBytes(n)
+	case "NameConstant":
+		res = v.visitNameConstant(n)
+	case "Num":
+		res = v.visitNum(n)
+	case "Attribute":
+		res = v.visitAttribute(n)
+	case "arguments":
+		res = v.visitArguments(n)
+	case "Global", "Nonlocal":
+		res = v.promoteNames(n)
+	default:
+		res = v.visitOther(n)
+	}
+	v.noops.addNoops(n, root)
+	v.check(v.pos.syncNodePos(res))
+	normalizePosition(res)
+	removeInternal(res)
+	return res
+}
+
+// visitNone converts None values in the node lists.
+func (v *improver) visitNone() node {
+	return node{"ast_type": "NoneLiteral", "LiteralValue": "None"}
+}
+
+func (v *improver) visitBytes(n node) node {
+	s := n["s"].(string)
+	if utf8.ValidString(s) {
+		n["encoding"] = "utf8"
+	} else {
+		n["s"] = pyBase64(s)
+		n["encoding"] = "base64"
+	}
+	return n
+}
+
+// promoteNames converts the names of global and nonlocal statements, that are
+// stored as strings in the Python AST, to Name nodes.
+func (v *improver) promoteNames(n node) node {
+	names := n["names"].([]interface{})
+	out := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		out = append(out, v.visit(node{
+			"ast_type": "Name",
+			"id":       name,
+			"lineno":   n["lineno"],
+		}, false))
+	}
+	n["names"] = out
+	return n
+}
+
+func (v *improver) visitNameConstant(n node) node {
+	switch val := n["value"].(type) {
+	case bool:
+		n["ast_type"] = "BoolLiteral"
+		n["LiteralValue"] = "False"
+		if val {
+			n["LiteralValue"] = "True"
+		}
+	case nil:
+		n["ast_type"] = "NoneLiteral"
+		n["LiteralValue"] = "None"
+	}
+	return n
+}
+
+func (v *improver) visitNum(n node) node {
+	num := n["n"].(*pyNumber)
+	switch {
+	case num.Complex:
+		// complex numbers are not serializable
+		var imag interface{} = num.Float
+		if math.IsInf(num.Float, 0) || math.IsNaN(num.Float) {
+			imag = pyFloatRepr(num.Float)
+		}
+		n["n"] = node{"real": 0.0, "imag": imag}
+	case num.IsFloat && (math.IsInf(num.Float, 0) || math.IsNaN(num.Float)):
+		// neither are infinity and nan
+		n["n"] = pyFloatRepr(num.Float)
+	}
+	return n
+}
+
+// visitAttribute flattens the chain of attributes to a QualifiedIdentifier.
+func (v *improver) visitAttribute(n node) node {
+	value, _ := n["value"].(node)
+	if value == nil {
+		return n
+	}
+	var ids []interface{}
+	for value != nil {
+		var next node
+		if nv, ok := value["value"].(node); ok {
+			next = nv
+			delete(value, "value")
+		}
+		ids = append([]interface{}{v.visit(value, false)}, ids...)
+		value = next
+	}
+
+	// append a copy of this node at the end, and change the type of the original
+	delete(n, "value")
+	cp := make(node, len(n))
+	for k, val := range n {
+		cp[k] = val
+	}
+	removeInternal(cp)
+	normalizePosition(cp)
+	ids = append(ids, cp)
+
+	n["ast_type"] = "QualifiedIdentifier"
+	// copy the position of the first element
+	first := ids[0].(node)
+	for _, k := range []string{"lineno", "end_lineno", "col_offset", "end_col_offset"} {
+		if val, ok := first[k].(int); ok && val != 0 {
+			n[k] = val
+		}
+	}
+	delete(n, "attr")
+	n["identifiers"] = ids
+	return n
+}
+
+// visitArguments converts the Python arguments, stored in separate lists for each
+// argument kind and their default values, to a single list of arguments with the
+// default values as their children.
+func (v *improver) visitArguments(n node) node {
+	matchDefaults := func(args, defaults []interface{}) {
+		if len(defaults) == 0 {
+			return
+		}
+		diff := len(args) - len(defaults)
+		for i, a := range args[diff:] {
+			var def node
+			if d, ok := defaults[i].(node); ok {
+				def = v.visit(d, false)
+			} else {
+				def = v.visitNone()
+			}
+			a.(node)["default"] = def
+		}
+	}
+
+	var args []interface{}
+	posonly, _ := n["posonlyargs"].([]interface{})
+	normal, _ := n["args"].([]interface{})
+	if len(posonly)+len(normal) != 0 {
+		// the default values are shared by positional-only and normal arguments
+		defaults, _ := n["defaults"].([]interface{})
+		matchDefaults(append(posonly[:len(posonly):len(posonly)], normal...), defaults)
+		for _, a := range posonly {
+			a.(node)["ast_type"] = "posonly_arg"
+		}
+		for _, a := range posonly {
+			args = append(args, v.visit(a.(node), false))
+		}
+		for _, a := range normal {
+			args = append(args, v.visit(a.(node), false))
+		}
+	}
+	if kwonly, _ := n["kwonlyargs"].([]interface{}); len(kwonly) != 0 {
+		defaults, _ := n["kw_defaults"].([]interface{})
+		matchDefaults(kwonly, defaults)
+		for _, a := range kwonly {
+			a.(node)["ast_type"] = "kwonly_arg"
+		}
+		for _, a := range kwonly {
+			args = append(args, v.visit(a.(node), false))
+		}
+	}
+	if kwarg := variadicArg(n["kwarg"]); kwarg != nil {
+		kwarg["ast_type"] = "kwarg"
+		args = append(args, v.visit(kwarg, false))
+	}
+	if vararg := variadicArg(n["vararg"]); vararg != nil {
+		vararg["ast_type"] = "vararg"
+		args = append(args, v.visit(vararg, false))
+	}
+	for _, k := range []string{"defaults", "kw_defaults", "posonlyargs", "args", "kwonlyargs", "kwarg", "vararg"} {
+		delete(n, k)
+	}
+	if len(posonly) != 0 {
+		n["posonlyargcount"] = len(posonly)
+	}
+	for _, a := range args {
+		a := a.(node)
+		if name, ok := a["arg"]; ok {
+			a["@token"] = name
+			delete(a, "arg")
+		}
+		// the Python 2 parameters are names
+		if nodeType(a) == "Name" {
+			a["ast_type"] = "arg"
+		}
+		if id, ok := a["id"]; ok {
+			a["@token"] = id
+			delete(a, "id")
+		}
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+	n["args"] = args
+	return n
+}
+
+// variadicArg returns the node of a variadic argument. The ones of Python 2 are only
+// names, that are converted to the nodes of Python 3 positioned at the first line, so
+// they get the position of their token only if it's in that line.
+func variadicArg(arg interface{}) node {
+	switch arg := arg.(type) {
+	case node:
+		return arg
+	case string:
+		return node{
+			"arg":            arg,
+			"annotation":     nil,
+			"lineno":         1,
+			"end_lineno":     1,
+			"col_offset":     0,
+			"end_col_offset": 0,
+		}
+	}
+	return nil
+}
+
+func (v *improver) visitOther(n node) node {
+	fields, _ := n["_fields"].([]string)
+	for _, f := range fields {
+		switch child := n[f].(type) {
+		case node:
+			n[f] = v.visit(child, false)
+		case []interface{}:
+			for i, e := range child {
+				if en, ok := e.(node); ok {
+					child[i] = v.visit(en, false)
+				} else if e == nil {
+					child[i] = v.visitNone()
+				}
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,233 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenKeys are the node properties used as the token of the node.
+var tokenKeys = []string{"module", "name", "id", "attr", "arg", "LiteralValue", "s", "n"}
+
+// syntheticTokens are the tokens of the nodes that have no token property.
+var syntheticTokens = map[string]string{
+	"Add":           "+",
+	"Assert":        "assert",
+	"AugAssign":     "+=",
+	"BitAnd":        "&",
+	"BitOr":         "|",
+	"BitXor":        "^",
+	"Break":         "break",
+	"ClassDef":      "class",
+	"Continue":      "continue",
+	"Delete":        "del",
+	"Div":           "/",
+	"Ellipsis":      "...",
+	"ExceptHandler": "except",
+	"Eq":            "==",
+	"False":         "False",
+	"For":           "for",
+	"FloorDiv":      "//",
+	"Global":        "global",
+	"Gt":            ">",
+	"GtE":           ">=",
+	"If":            "if",
+	"In":            "in",
+	"Invert":        "~",
+	"Is":            "is",
+	"IsNot":         "not is",
+	"Lambda":        "lambda",
+	"LShift":        "<<",
+	"Lt":            "<",
+	"LtE":           "<=",
+	"Match":         "match",
+	"Mod":           "%%",
+	"Mult":          "*",
+	"None":          "None",
+	"Nonlocal":      "nonlocal",
+	"Not":           "not",
+	"NotEq":         "!=",
+	"NotIn":         "not in",
+	"Pass":          "pass",
+	"Pow":           "**",
+	"Print":         "print",
+	"Raise":         "raise",
+	"Return":        "return",
+	"RShift":        ">>",
+	"Sub":           "-",
+	"True":          "true",
+	"Try":           "try",
+	"UAdd":          "+",
+	"USub":          "-",
+	"While":         "while",
+	"With":          "with",
+	"Yield":         "yield",
+}
+
+// splitLinesCount returns the number of lines as reported by Python's str.splitlines.
+func splitLinesCount(s string) int {
+	n := 0
+	for len(s) != 0 {
+		i := strings.IndexAny(s, "\n\r\v\f\x1c\x1d\x1e\u0085\u2028\u2029")
+		if i < 0 {
+			n++
+			break
+		}
+		n++
+		if strings.HasPrefix(s[i:], "\r\n") {
+			s = s[i+2:]
+		} else {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			s = s[i+size:]
+		}
+	}
+	return n
+}
+
+// tokenizedLines groups the tokens by the line they start at, except for strings that
+// are stored in their last line (because they can be multiline).
+func tokenizedLines(code string, toks []token) ([][]*token, error) {
+	lines := make([][]*token, splitLinesCount(code)+1)
+	for i := range toks {
+		t := &toks[i]
+		line := t.Start.Row - 1
+		if t.Type == tokString {
+			line = t.End.Row - 1
+		}
+		if line < 0 {
+			line += len(lines)
+		}
+		if line < 0 || line >= len(lines) {
+			return nil, fmt.Errorf("list index out of range")
+		}
+		lines[line] = append(lines[line], t)
+	}
+	return lines, nil
+}
+
+// locationFixer is used to get the exact position of the node tokens, that the Python
+// AST doesn't give or gives in a questionable way (sys.stdout.write gives the same
+// column for the three names).
+type locationFixer struct {
+	lines [][]*token
+	// byValue are the tokens of the lines that were not popped yet, by their value
+	// and in the order of the line. The lines are indexed when the first token is
+	// popped from them.
+	byValue []map[string][]*token
+}
+
+func (f *locationFixer) popToken(lineno int, value string) (*token, error) {
+	idx := lineno - 1
+	if idx < 0 || idx >= len(f.lines) {
+		return nil, fmt.Errorf("no tokens at line %d", lineno)
+	}
+	if f.byValue == nil {
+		f.byValue = make([]map[string][]*token, len(f.lines))
+	}
+	index := f.byValue[idx]
+	if index == nil {
+		index = make(map[string][]*token)
+		for _, t := range f.lines[idx] {
+			lineValue := t.Value
+			if t.Type == tokString {
+				v, ok := literalEval(t.Value)
+				if !ok {
+					continue
+				}
+				lineValue = v
+			}
+			index[lineValue] = append(index[lineValue], t)
+		}
+		f.byValue[idx] = index
+	}
+	toks := index[value]
+	if len(toks) == 0 {
+		return nil, nil
+	}
+	index[value] = toks[1:]
+	return toks[0], nil
+}
+
+// literalEval returns the string representation of the value of the string token.
+// F-strings are evaluated as normal strings if they have only the "f" prefix and
+// cannot be evaluated otherwise.
+func literalEval(tok string) (string, bool) {
+	if len(tok) > 1 && tok[0] == 'f' && (tok[1] == '"' || tok[1] == '\'') {
+		tok = tok[1:]
+	}
+	lit, err := decodeString(tok)
+	if err != nil || lit.Format {
+		return "", false
+	}
+	if lit.Bytes {
+		return pyBytesRepr(lit.Value), true
+	}
+	return lit.Value, true
+}
+
+// pyStr is an equivalent of Python's str function for the values of the node tokens.
+func pyStr(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "None"
+	case string:
+		return v
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case int:
+		return fmt.Sprint(v)
+	case float64:
+		return pyFloatRepr(v)
+	case *big.Int:
+		return v.String()
+	case *pyNumber:
+		if v.Int != nil {
+			return v.Int.String()
+		}
+		return pyFloatRepr(v.Float)
+	case node:
+		return fmt.Sprintf("{'real': %s, 'imag': %s}", pyStr(v["real"]), pyStr(v["imag"]))
+	}
+	return fmt.Sprint(v)
+}
+
+// syncNodePos fixes the position of the node using the position of its token.
+// The token is removed from its line, so the next node with the same token will not
+// consume it again.
+func (f *locationFixer) syncNodePos(n node) error {
+	line, ok := n["lineno"].(int)
+	if !ok {
+		return nil
+	}
+	var value string
+	found := false
+	for _, k := range tokenKeys {
+		if v, ok := n[k]; ok {
+			value, found = pyStr(v), true
+			break
+		}
+	}
+	if !found {
+		value = syntheticTokens[nodeType(n)]
+		if value == "" {
+			return nil
+		}
+	}
+	t, err := f.popToken(line, value)
+	if err != nil || t == nil {
+		return err
+	}
+	if typ := nodeType(n); typ != "ImportFrom" && !(typ == "MatchAs" && n["pattern"] != nil) {
+		// ImportFrom takes the module as the token, but its position is fine, and
+		// the same happens with the name of the capture patterns with a subpattern
+		n["lineno"] = t.Start.Row
+		n["col_offset"] = t.Start.Col
+	}
+	n["end_lineno"] = t.End.Row
+	n["end_col_offset"] = t.End.Col
+	return nil
+}
@@ -0,0 +1,311 @@
+package parser
+
+// isMatch checks if the current token starts a match statement. "match" is a soft
+// keyword, so the statement header is parsed ahead and the parser is rewound.
+func (p *parser) isMatch() (ok bool) {
+	if !p.isKw("match") {
+		return false
+	}
+	start := p.i
+	defer func() {
+		p.i = start
+		if r := recover(); r != nil {
+			if _, isErr := r.(*SyntaxError); !isErr {
+				panic(r)
+			}
+			ok = false
+		}
+	}()
+	p.next()
+	p.matchSubject()
+	p.expectOp(":")
+	p.expect(tokNewline)
+	if !p.is(tokIndent) {
+		return false
+	}
+	p.next()
+	return p.isKw("case")
+}
+
+func (p *parser) matchStmt() node {
+	pos := p.pos()
+	p.next()
+	subject := p.matchSubject()
+	p.expectOp(":")
+	p.expect(tokNewline)
+	p.expect(tokIndent)
+	var cases []interface{}
+	for !p.is(tokDedent) {
+		if !p.isKw("case") {
+			p.errorf("invalid syntax")
+		}
+		cases = append(cases, p.matchCase())
+	}
+	p.next()
+	return newNode("Match", &pos, subject, cases)
+}
+
+// matchSubject parses the subject of the match statement, that may be an unparenthesized
+// tuple.
+func (p *parser) matchSubject() node {
+	pos := p.pos()
+	elem := func() node {
+		if p.isOp("*") {
+			return p.starExpr()
+		}
+		return p.namedExprTest()
+	}
+	first := elem()
+	if !p.isOp(",") {
+		if nodeType(first) == "Starred" {
+			p.errorf("invalid syntax")
+		}
+		return first
+	}
+	elts := []interface{}{first}
+	for p.acceptOp(",") {
+		if p.isOp(":") {
+			break
+		}
+		elts = append(elts, elem())
+	}
+	return newNode("Tuple", &pos, elts, ctxLoad)
+}
+
+func (p *parser) matchCase() node {
+	p.next()
+	pattern := p.patterns()
+	var guard node
+	if p.acceptKw("if") {
+		guard = p.namedExprTest()
+	}
+	body := p.block()
+	return newNode("match_case", nil, pattern, guard, body)
+}
+
+// patterns parses the pattern of a case clause, that may be an unparenthesized sequence.
+func (p *parser) patterns() node {
+	pos := p.pos()
+	first := p.maybeStarPattern()
+	if !p.isOp(",") {
+		if nodeType(first) == "MatchStar" {
+			p.errorf("invalid syntax")
+		}
+		return first
+	}
+	pats := []interface{}{first}
+	for p.acceptOp(",") {
+		if p.isOp(":") || p.isKw("if") {
+			break
+		}
+		pats = append(pats, p.maybeStarPattern())
+	}
+	return newNode("MatchSequence", &pos, pats)
+}
+
+func (p *parser) maybeStarPattern() node {
+	if !p.isOp("*") {
+		return p.pattern()
+	}
+	pos := p.pos()
+	p.next()
+	var name interface{}
+	if n := p.name(); n != "_" {
+		name = n
+	}
+	return newNode("MatchStar", &pos, name)
+}
+
+func (p *parser) pattern() node {
+	pos := p.pos()
+	pat := p.orPattern()
+	if !p.acceptKw("as") {
+		return pat
+	}
+	name := p.name()
+	if name == "_" {
+		p.errorf("cannot use '_' as a target")
+	}
+	return newNode("MatchAs", &pos, pat, name)
+}
+
+func (p *parser) orPattern() node {
+	pos := p.pos()
+	pat := p.closedPattern()
+	if !p.isOp("|") {
+		return pat
+	}
+	pats := []interface{}{pat}
+	for p.acceptOp("|") {
+		pats = append(pats, p.closedPattern())
+	}
+	return newNode("MatchOr", &pos, pats)
+}
+
+func (p *parser) closedPattern() node {
+	pos := p.pos()
+	t := p.tok()
+	switch {
+	case p.isOp("("):
+		p.next()
+		if p.acceptOp(")") {
+			return newNode("MatchSequence", &pos, []interface{}{})
+		}
+		first := p.maybeStarPattern()
+		if p.acceptOp(")") {
+			if nodeType(first) == "MatchStar" {
+				return newNode("MatchSequence", &pos, []interface{}{first})
+			}
+			// group pattern
+			return first
+		}
+		pats := []interface{}{first}
+		for p.acceptOp(",") {
+			if p.isOp(")") {
+				break
+			}
+			pats = append(pats, p.maybeStarPattern())
+		}
+		p.expectOp(")")
+		return newNode("MatchSequence", &pos, pats)
+	case p.isOp("["):
+		p.next()
+		pats := []interface{}{}
+		for !p.isOp("]") {
+			pats = append(pats, p.maybeStarPattern())
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		p.expectOp("]")
+		return newNode("MatchSequence", &pos, pats)
+	case p.isOp("{"):
+		return p.mappingPattern()
+	case t.Type == tokName && (t.Value == "None" || t.Value == "True" || t.Value == "False"):
+		p.next()
+		var value interface{}
+		if t.Value != "None" {
+			value = t.Value == "True"
+		}
+		return newNode("MatchSingleton", &pos, value)
+	case t.Type == tokNumber || t.Type == tokString || p.isOp("-"):
+		return newNode("MatchValue", &pos, p.literalPattern())
+	case p.isName():
+		name := p.name()
+		if !p.isOp(".") && !p.isOp("(") {
+			// capture and wildcard patterns
+			var target interface{}
+			if name != "_" {
+				target = name
+			}
+			return newNode("MatchAs", &pos, nil, target)
+		}
+		var value node = newNode("Name", &pos, name, ctxLoad)
+		for p.acceptOp(".") {
+			value = newNode("Attribute", &pos, value, p.name(), ctxLoad)
+		}
+		if p.isOp("(") {
+			return p.classPattern(pos, value)
+		}
+		return newNode("MatchValue", &pos, value)
+	}
+	p.errorf("invalid syntax")
+	return nil
+}
+
+// literalPattern parses a string, a signed number or a complex number literal.
+func (p *parser) literalPattern() node {
+	if p.is(tokString) {
+		return p.strings()
+	}
+	pos := p.pos()
+	number := func() node {
+		if !p.is(tokNumber) {
+			p.errorf("invalid syntax")
+		}
+		return p.atom()
+	}
+	var e node
+	if p.acceptOp("-") {
+		e = newNode("UnaryOp", &pos, opNode("USub"), number())
+	} else {
+		e = number()
+	}
+	if p.isOp("+") || p.isOp("-") {
+		op := "Add"
+		if p.next().Value == "-" {
+			op = "Sub"
+		}
+		e = newNode("BinOp", &pos, e, opNode(op), number())
+	}
+	return e
+}
+
+func (p *parser) mappingPattern() node {
+	pos := p.pos()
+	p.expectOp("{")
+	var (
+		keys, pats []interface{}
+		rest       interface{}
+	)
+	for !p.isOp("}") {
+		if p.acceptOp("**") {
+			rest = p.name()
+			p.acceptOp(",")
+			break
+		}
+		keys = append(keys, p.mappingKey())
+		p.expectOp(":")
+		pats = append(pats, p.pattern())
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	p.expectOp("}")
+	if keys == nil {
+		keys, pats = []interface{}{}, []interface{}{}
+	}
+	return newNode("MatchMapping", &pos, keys, pats, rest)
+}
+
+// mappingKey parses the key of a mapping pattern: a literal or a dotted name.
+func (p *parser) mappingKey() node {
+	pos := p.pos()
+	t := p.tok()
+	switch {
+	case t.Type == tokName && (t.Value == "None" || t.Value == "True" || t.Value == "False"):
+		return p.atom()
+	case p.isName():
+		var value node = newNode("Name", &pos, p.name(), ctxLoad)
+		if !p.isOp(".") {
+			p.errorf("invalid syntax")
+		}
+		for p.acceptOp(".") {
+			value = newNode("Attribute", &pos, value, p.name(), ctxLoad)
+		}
+		return value
+	}
+	return p.literalPattern()
+}
+
+func (p *parser) classPattern(pos position, cls node) node {
+	p.expectOp("(")
+	pats, attrs, kwPats := []interface{}{}, []interface{}{}, []interface{}{}
+	for !p.isOp(")") {
+		if nt := p.peek(1); p.isName() && nt.Type == tokOp && nt.Value == "=" {
+			attrs = append(attrs, p.name())
+			p.next()
+			kwPats = append(kwPats, p.pattern())
+		} else {
+			if len(attrs) != 0 {
+				p.errorf("positional patterns follow keyword patterns")
+			}
+			pats = append(pats, p.pattern())
+		}
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	p.expectOp(")")
+	return newNode("MatchClass", &pos, cls, pats, attrs, kwPats)
+}
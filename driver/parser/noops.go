@@ -0,0 +1,190 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+)
+
+// noopExtractor extracts the tokens ignored by the Python AST, like blank lines and
+// comments, and attaches them to the nodes.
+type noopExtractor struct {
+	lines   [][]*token
+	missing []*token
+	cur     int
+	// lines with the sameline noops already added to some node, to avoid duplicating
+	// them on all the nodes of the line
+	added map[int]bool
+}
+
+// nlToken is used as a marker of the blank lines.
+var nlToken = &token{Type: tokNewline, Value: "\n", Line: "\n"}
+
+func newNoopExtractor(lines [][]*token) *noopExtractor {
+	e := &noopExtractor{lines: lines, added: make(map[int]bool)}
+	for _, toks := range lines {
+		var tok *token
+		if len(toks) == 1 && toks[0].Type == tokNL {
+			tok = nlToken
+		} else {
+			for _, t := range toks {
+				if t.Type == tokComment && strings.HasPrefix(strings.TrimLeftFunc(t.Line, unicode.IsSpace), "#") {
+					tok = t
+					break
+				}
+			}
+		}
+		e.missing = append(e.missing, tok)
+	}
+	e.cur = len(e.missing)
+	for i, t := range e.missing {
+		if t != nil {
+			e.cur = i
+			break
+		}
+	}
+	return e
+}
+
+func noopLines(typ string, start int, lines []string) []interface{} {
+	out := []interface{}{}
+	cur := start
+	for _, l := range lines {
+		if l != "\n" {
+			out = append(out, node{
+				"ast_type":   typ,
+				"noop_line":  l,
+				"lineno":     cur,
+				"col_offset": 1,
+			})
+		}
+		cur++
+	}
+	return out
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (e *noopExtractor) addNoops(n node, root bool) {
+	// all the noop lines between the last node and this one
+	if prev, start, end, endCol := e.previous(n); len(prev) != 0 {
+		n["noops_previous"] = node{
+			"ast_type":       "PreviousNoops",
+			"lineno":         start,
+			"col_offset":     1,
+			"end_lineno":     end,
+			"end_col_offset": maxInt(endCol, 1),
+			"lines":          noopLines("NoopLine", start, prev),
+		}
+	}
+
+	// other noops at the end of its line, except the implicit finishing newline
+	if same := e.sameline(n); len(same) != 0 {
+		lines := make([]interface{}, 0, len(same))
+		for _, t := range same {
+			lines = append(lines, node{
+				"ast_type": "NoopSameLine",
+				"s":        strings.TrimSpace(t.Value),
+			})
+		}
+		line, _ := n["lineno"].(int)
+		n["noops_sameline"] = node{
+			"ast_type":       "SameLineNoops",
+			"lineno":         line,
+			"col_offset":     same[0].Start.Col,
+			"noop_lines":     lines,
+			"end_lineno":     line,
+			"end_col_offset": maxInt(same[len(same)-1].End.Col, 1),
+		}
+	}
+
+	// finally, the root node gets all the noops after the last node
+	if root {
+		if rem, start, end, endCol := e.remainder(); len(rem) != 0 {
+			n["noops_remainder"] = node{
+				"ast_type":       "RemainderNoops",
+				"lineno":         start,
+				"col_offset":     1,
+				"end_lineno":     end,
+				"end_col_offset": maxInt(endCol, 1),
+				"lines":          noopLines("NoopLine", start, rem),
+			}
+		}
+	}
+}
+
+// previous returns the comment and blank lines preceding the node.
+func (e *noopExtractor) previous(n node) (lines []string, first, last, lastCol int) {
+	first, last, lastCol = -1, -1, -1
+	lineno, _ := n["lineno"].(int)
+	if lineno == 0 {
+		return
+	}
+	for e.cur < lineno && e.cur < len(e.missing) {
+		t := e.missing[e.cur]
+		if t != nil {
+			lines = append(lines, strings.TrimRightFunc(t.Line, unicode.IsSpace)+"\n")
+			if first == -1 {
+				first = e.cur + 1
+			}
+			last = e.cur + 1
+			lastCol = t.End.Col
+		}
+		e.cur++
+	}
+	return
+}
+
+// sameline returns the trailing noop tokens of the node line, if any.
+func (e *noopExtractor) sameline(n node) []*token {
+	lineno, _ := n["lineno"].(int)
+	if lineno <= 0 || lineno > len(e.lines) || e.added[lineno] {
+		return nil
+	}
+	// the first line of a module is "1", so the comments on that line would be
+	// wrongly assigned to the module
+	if nodeType(n) == "Module" {
+		return nil
+	}
+	var trailing []*token
+	for _, t := range e.lines[lineno-1] {
+		switch t.Type {
+		case tokComment, tokIndent, tokNL, tokNewline:
+			trailing = append(trailing, t)
+		default:
+			trailing = nil
+		}
+	}
+	if len(trailing) == 0 {
+		return nil
+	}
+	e.added[lineno] = true
+	if trailing[len(trailing)-1].Value == "\n" {
+		trailing = trailing[:len(trailing)-1]
+	}
+	return trailing
+}
+
+// remainder returns the remaining ignored lines.
+func (e *noopExtractor) remainder() (lines []string, first, last, lastCol int) {
+	last, lastCol = -1, 1
+	first = e.cur + 1
+	i := e.cur
+	for i < len(e.missing) {
+		t := e.missing[i]
+		i++
+		if t != nil {
+			lines = append(lines, t.Line)
+			last = i
+			lastCol = t.End.Col
+		} else {
+			lastCol = 1
+		}
+	}
+	e.cur = i
+	return
+}
@@ -0,0 +1,1843 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+var keywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true,
+	"break": true, "class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true, "global": true,
+	"if": true, "import": true, "in": true, "is": true, "lambda": true, "nonlocal": true,
+	"not": true, "or": true, "pass": true, "raise": true, "return": true, "try": true,
+	"while": true, "with": true, "yield": true,
+}
+
+var augAssignOps = map[string]string{
+	"+=": "Add", "-=": "Sub", "*=": "Mult", "@=": "MatMult", "/=": "Div", "%=": "Mod",
+	"&=": "BitAnd", "|=": "BitOr", "^=": "BitXor", "<<=": "LShift", ">>=": "RShift",
+	"**=": "Pow", "//=": "FloorDiv",
+}
+
+// parser is a recursive descent parser for the Python 3 grammar, up to Python 3.10,
+// or for the Python 2.7 one. It builds the AST directly, reproducing the node
+// positions reported by the CPython 3.6 parser, or by the later ones for the syntax
+// that 3.6 doesn't have.
+type parser struct {
+	toks []token
+	i    int
+	// py2 is set for the Python 2.7 grammar, that has the keywords in keywords
+	py2      bool
+	keywords map[string]bool
+	// async is set inside the body of "async def", where async and await are keywords
+	async bool
+	// errs are the syntax errors of the statements left out of the AST
+	errs []*SyntaxError
+}
+
+// parseModule parses the source of a Python module and returns its AST, with the
+// Python 2.7 grammar if py2 is set. The statements with syntax errors are left out of
+// it, and their errors are returned.
+func parseModule(toks []token, py2 bool) (node, []*SyntaxError) {
+	p := newParser(toks)
+	if py2 {
+		p.py2, p.keywords = true, keywords2
+		p.toks = tokens2(p.toks)
+	}
+	mod := p.fileInput()
+	return mod, p.errs
+}
+
+func newParser(toks []token) *parser {
+	p := &parser{keywords: keywords}
+	for _, t := range toks {
+		switch t.Type {
+		case tokComment, tokNL, tokEncoding:
+			continue
+		}
+		p.toks = append(p.toks, t)
+	}
+	return p
+}
+
+func (p *parser) recover(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(*SyntaxError)
+		if !ok {
+			panic(r)
+		}
+		*err = e
+	}
+}
+
+func (p *parser) errorAt(t *token, format string, args ...interface{}) {
+	panic(&SyntaxError{
+		Msg:  fmt.Sprintf(format, args...),
+		Line: t.Start.Row, Col: t.Start.Col,
+		EndLine: t.End.Row, EndCol: t.End.Col,
+	})
+}
+
+func (p *parser) errorf(format string, args ...interface{}) {
+	p.errorAt(p.tok(), format, args...)
+}
+
+func (p *parser) tok() *token {
+	return &p.toks[p.i]
+}
+
+func (p *parser) peek(n int) *token {
+	if p.i+n >= len(p.toks) {
+		return &p.toks[len(p.toks)-1]
+	}
+	return &p.toks[p.i+n]
+}
+
+func (p *parser) next() *token {
+	t := p.tok()
+	if t.Type == tokErrorToken {
+		p.errorf("invalid syntax")
+	}
+	if p.i < len(p.toks)-1 {
+		p.i++
+	}
+	return t
+}
+
+// pos returns the position of the current token.
+func (p *parser) pos() position {
+	return tokenPosition(p.tok())
+}
+
+func tokenPosition(t *token) position {
+	if t.Type == tokString && t.multiline() {
+		// CPython reports multiline strings at the last line with an unknown column
+		return position{Line: t.End.Row, Col: -1}
+	}
+	return position{Line: t.Start.Row, Col: t.Start.Byte}
+}
+
+func (p *parser) is(typ tokenType) bool {
+	return p.tok().Type == typ
+}
+
+func (p *parser) isOp(v string) bool {
+	t := p.tok()
+	return t.Type == tokOp && t.Value == v
+}
+
+func (p *parser) isKw(v string) bool {
+	t := p.tok()
+	return t.Type == tokName && t.Value == v
+}
+
+// isAsync checks if the current token is the "async" keyword.
+func (p *parser) isAsync() bool {
+	if p.py2 || !p.isKw("async") {
+		return false
+	}
+	if nt := p.peek(1); nt.Type == tokName && nt.Value == "def" {
+		return true
+	}
+	return p.async
+}
+
+func (p *parser) isName() bool {
+	t := p.tok()
+	if t.Type != tokName || p.keywords[t.Value] {
+		return false
+	}
+	if p.async && (t.Value == "async" || t.Value == "await") {
+		return false
+	}
+	return !p.isAsync()
+}
+
+func (p *parser) acceptOp(v string) bool {
+	if p.isOp(v) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) acceptKw(v string) bool {
+	if p.isKw(v) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(v string) *token {
+	if !p.isOp(v) {
+		p.errorf("invalid syntax")
+	}
+	return p.next()
+}
+
+func (p *parser) expectKw(v string) *token {
+	if !p.isKw(v) {
+		p.errorf("invalid syntax")
+	}
+	return p.next()
+}
+
+func (p *parser) expect(typ tokenType) *token {
+	if !p.is(typ) {
+		p.errorf("invalid syntax")
+	}
+	return p.next()
+}
+
+func (p *parser) name() string {
+	if !p.isName() {
+		p.errorf("invalid syntax")
+	}
+	return p.next().Value
+}
+
+// atTest checks if the current token may start a test (expression).
+func (p *parser) atTest() bool {
+	t := p.tok()
+	switch t.Type {
+	case tokNumber, tokString:
+		return true
+	case tokName:
+		switch t.Value {
+		case "None", "True", "False", "not", "lambda", "await":
+			return true
+		}
+		return p.isName()
+	case tokOp:
+		switch t.Value {
+		case "(", "[", "{", "-", "+", "~", "...":
+			return true
+		case "`":
+			return p.py2
+		}
+	}
+	return false
+}
+
+func (p *parser) fileInput() node {
+	var body []interface{}
+	for !p.is(tokEndMarker) {
+		if p.is(tokNewline) {
+			p.next()
+			continue
+		}
+		body = append(body, p.stmtOrSkip()...)
+	}
+	return newNode("Module", nil, body)
+}
+
+// evalInput parses an expression list, as used for f-string expressions.
+func (p *parser) evalInput() node {
+	e := p.testList()
+	for p.is(tokNewline) {
+		p.next()
+	}
+	p.expect(tokEndMarker)
+	return e
+}
+
+// stmtOrSkip parses a statement of a block. If it has a syntax error, the error is
+// recorded and the statement is skipped, so the rest of the block is still parsed.
+func (p *parser) stmtOrSkip() (out []interface{}) {
+	start := p.i
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			p.errs = append(p.errs, e)
+			p.skipStmt(start)
+			out = nil
+		}
+	}()
+	return p.stmt()
+}
+
+// skipStmt skips the tokens of a statement starting at the start token, up to the
+// start of the next statement of the block. The indented block following a compound
+// statement header is skipped too.
+func (p *parser) skipStmt(start int) {
+	for {
+		t := p.tok()
+		switch {
+		case t.Type == tokEndMarker:
+			return
+		case t.Type == tokIndent:
+			p.skipBlock()
+			return
+		case p.i > start && (t.Type == tokDedent || p.toks[p.i-1].Type == tokNewline):
+			return
+		}
+		p.i++
+	}
+}
+
+// skipBlock skips an indented block, from its INDENT token to the matching DEDENT.
+func (p *parser) skipBlock() {
+	depth := 0
+	for !p.is(tokEndMarker) {
+		switch p.tok().Type {
+		case tokIndent:
+			depth++
+		case tokDedent:
+			depth--
+		}
+		p.i++
+		if depth == 0 {
+			return
+		}
+	}
+}
+
+func (p *parser) stmt() []interface{} {
+	t := p.tok()
+	if t.Type == tokIndent {
+		p.errorf("unexpected indent")
+	}
+	if t.Type == tokName {
+		switch t.Value {
+		case "if":
+			return []interface{}{p.ifStmt()}
+		case "while":
+			return []interface{}{p.whileStmt()}
+		case "for":
+			return []interface{}{p.forStmt(false)}
+		case "try":
+			return []interface{}{p.tryStmt()}
+		case "with":
+			return []interface{}{p.withStmt(false)}
+		case "def":
+			return []interface{}{p.funcDef(nil, false)}
+		case "class":
+			return []interface{}{p.classDef(nil)}
+		}
+		if p.isAsync() {
+			return []interface{}{p.asyncStmt(nil)}
+		}
+		if p.isMatch() {
+			return []interface{}{p.matchStmt()}
+		}
+	} else if t.Type == tokOp && t.Value == "@" {
+		return []interface{}{p.decorated()}
+	}
+	return p.simpleStmt()
+}
+
+func (p *parser) simpleStmt() []interface{} {
+	var out []interface{}
+	for {
+		out = append(out, p.smallStmt())
+		if !p.acceptOp(";") || p.is(tokNewline) {
+			break
+		}
+	}
+	p.expect(tokNewline)
+	return out
+}
+
+func (p *parser) smallStmt() node {
+	pos := p.pos()
+	t := p.tok()
+	if t.Type == tokName && p.keywords[t.Value] {
+		switch t.Value {
+		case "print":
+			return p.printStmt()
+		case "exec":
+			return p.execStmt()
+		case "del":
+			p.next()
+			targets := p.exprList()
+			for _, e := range targets {
+				setContext(p, e.(node), ctxDel, t)
+			}
+			return newNode("Delete", &pos, targets)
+		case "pass":
+			p.next()
+			return newNode("Pass", &pos)
+		case "break":
+			p.next()
+			return newNode("Break", &pos)
+		case "continue":
+			p.next()
+			return newNode("Continue", &pos)
+		case "return":
+			p.next()
+			var v node
+			if p.atTest() || p.isOp("*") {
+				// unparenthesized starred expressions are allowed since Python 3.8
+				v = p.testListStarExpr()
+			}
+			return newNode("Return", &pos, v)
+		case "raise":
+			if p.py2 {
+				return p.raiseStmt2()
+			}
+			p.next()
+			var exc, cause node
+			if p.atTest() {
+				exc = p.test()
+				if p.acceptKw("from") {
+					cause = p.test()
+				}
+			}
+			return newNode("Raise", &pos, exc, cause)
+		case "yield":
+			e := p.yieldExpr()
+			return newNode("Expr", &pos, e)
+		case "import":
+			return p.importName()
+		case "from":
+			return p.importFrom()
+		case "global", "nonlocal":
+			p.next()
+			var names []interface{}
+			for {
+				names = append(names, p.name())
+				if !p.acceptOp(",") {
+					break
+				}
+			}
+			typ := "Global"
+			if t.Value == "nonlocal" {
+				typ = "Nonlocal"
+			}
+			return newNode(typ, &pos, names)
+		case "assert":
+			p.next()
+			test := p.test()
+			var msg node
+			if p.acceptOp(",") {
+				msg = p.test()
+			}
+			return newNode("Assert", &pos, test, msg)
+		}
+	}
+	return p.exprStmt()
+}
+
+func (p *parser) exprStmt() node {
+	pos := p.pos()
+	first := p.tok()
+	target := p.testListStarExpr()
+	t := p.tok()
+	if t.Type == tokOp {
+		if op, ok := augAssignOps[t.Value]; ok && !(p.py2 && op == "MatMult") {
+			switch nodeType(target) {
+			case "Name", "Attribute", "Subscript":
+			default:
+				p.errorAt(first, "illegal expression for augmented assignment")
+			}
+			setContext(p, target, ctxStore, first)
+			p.next()
+			var value node
+			if p.isKw("yield") {
+				value = p.yieldExpr()
+			} else {
+				value = p.testList()
+			}
+			return newNode("AugAssign", &pos, target, opNode(op), value)
+		}
+		if t.Value == ":" && !p.py2 {
+			p.next()
+			simple := 0
+			switch nodeType(target) {
+			case "Name":
+				if first.Type == tokName {
+					simple = 1
+				}
+			case "Attribute", "Subscript":
+			case "Tuple":
+				p.errorAt(first, "only single target (not tuple) can be annotated")
+			case "List":
+				p.errorAt(first, "only single target (not list) can be annotated")
+			default:
+				p.errorAt(first, "illegal target for annotation")
+			}
+			setContext(p, target, ctxStore, first)
+			ann := p.test()
+			var value node
+			if p.acceptOp("=") {
+				value = p.test()
+			}
+			return newNode("AnnAssign", &pos, target, ann, value, simple)
+		}
+	}
+	if !p.isOp("=") {
+		return newNode("Expr", &pos, target)
+	}
+	targets := []node{target}
+	firsts := []*token{first}
+	var value node
+	for p.acceptOp("=") {
+		ft := p.tok()
+		var e node
+		if p.isKw("yield") {
+			e = p.yieldExpr()
+		} else {
+			e = p.testListStarExpr()
+		}
+		if p.isOp("=") {
+			targets = append(targets, e)
+			firsts = append(firsts, ft)
+		} else {
+			value = e
+		}
+	}
+	out := make([]interface{}, 0, len(targets))
+	for i, e := range targets {
+		if nodeType(e) == "Yield" || nodeType(e) == "YieldFrom" {
+			p.errorAt(firsts[i], "assignment to yield expression not possible")
+		}
+		setContext(p, e, ctxStore, firsts[i])
+		out = append(out, e)
+	}
+	return newNode("Assign", &pos, out, value)
+}
+
+func (p *parser) importName() node {
+	pos := p.pos()
+	p.expectKw("import")
+	var names []interface{}
+	for {
+		name := p.dottedName()
+		var as interface{}
+		if p.acceptKw("as") {
+			as = p.name()
+		}
+		names = append(names, newNode("alias", nil, name, as))
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	return newNode("Import", &pos, names)
+}
+
+func (p *parser) dottedName() string {
+	name := p.name()
+	for p.acceptOp(".") {
+		name += "." + p.name()
+	}
+	return name
+}
+
+func (p *parser) importFrom() node {
+	pos := p.pos()
+	p.expectKw("from")
+	level := 0
+	for {
+		if p.acceptOp(".") {
+			level++
+		} else if p.acceptOp("...") {
+			level += 3
+		} else {
+			break
+		}
+	}
+	var module interface{}
+	if level == 0 || !p.isKw("import") {
+		module = p.dottedName()
+	}
+	p.expectKw("import")
+	var names []interface{}
+	if p.isOp("*") {
+		p.next()
+		names = append(names, newNode("alias", nil, "*", nil))
+	} else {
+		paren := p.acceptOp("(")
+		for {
+			name := p.name()
+			var as interface{}
+			if p.acceptKw("as") {
+				as = p.name()
+			}
+			names = append(names, newNode("alias", nil, name, as))
+			if !p.isOp(",") {
+				break
+			}
+			comma := p.next()
+			if !p.isName() {
+				if !paren {
+					p.errorAt(comma, "trailing comma not allowed without surrounding parentheses")
+				}
+				break
+			}
+		}
+		if paren {
+			p.expectOp(")")
+		}
+	}
+	if p.py2 && module == "__future__" {
+		p.future(names)
+	}
+	return newNode("ImportFrom", &pos, module, names, level)
+}
+
+func (p *parser) suite() []interface{} {
+	if !p.is(tokNewline) {
+		return p.simpleStmt()
+	}
+	p.next()
+	if !p.is(tokIndent) {
+		p.errorf("expected an indented block")
+	}
+	p.next()
+	var body []interface{}
+	for !p.is(tokDedent) {
+		if p.is(tokEndMarker) {
+			p.errorf("unexpected EOF while parsing")
+		}
+		body = append(body, p.stmtOrSkip()...)
+	}
+	p.next()
+	return body
+}
+
+func (p *parser) block() []interface{} {
+	p.expectOp(":")
+	return p.suite()
+}
+
+func (p *parser) ifStmt() node {
+	pos := p.pos()
+	p.next()
+	test := p.namedExprTest()
+	body := p.block()
+	root := newNode("If", &pos, test, body, []interface{}{})
+	cur := root
+	for p.acceptKw("elif") {
+		// elif clauses are positioned at their condition
+		pos := p.pos()
+		test := p.namedExprTest()
+		body := p.block()
+		n := newNode("If", &pos, test, body, []interface{}{})
+		cur["orelse"] = []interface{}{n}
+		cur = n
+	}
+	if p.acceptKw("else") {
+		cur["orelse"] = p.block()
+	}
+	return root
+}
+
+func (p *parser) whileStmt() node {
+	pos := p.pos()
+	p.next()
+	test := p.namedExprTest()
+	body := p.block()
+	orelse := []interface{}{}
+	if p.acceptKw("else") {
+		orelse = p.block()
+	}
+	return newNode("While", &pos, test, body, orelse)
+}
+
+func (p *parser) forStmt(async bool) node {
+	pos := p.pos()
+	p.expectKw("for")
+	first := p.tok()
+	target := p.forTarget()
+	setContext(p, target, ctxStore, first)
+	p.expectKw("in")
+	iter := p.testList()
+	body := p.block()
+	orelse := []interface{}{}
+	if p.acceptKw("else") {
+		orelse = p.block()
+	}
+	typ := "For"
+	if async {
+		typ = "AsyncFor"
+	}
+	return newNode(typ, &pos, target, iter, body, orelse)
+}
+
+// forTarget parses an exprlist used as a loop target. Tuples take the position of
+// the first element.
+func (p *parser) forTarget() node {
+	elts, tuple := p.exprListTuple()
+	if !tuple {
+		return elts[0].(node)
+	}
+	pos := nodePos(elts[0].(node))
+	return newNode("Tuple", &pos, elts, ctxStore)
+}
+
+func (p *parser) tryStmt() node {
+	pos := p.pos()
+	p.next()
+	body := p.block()
+	handlers := []interface{}{}
+	orelse := []interface{}{}
+	finalbody := []interface{}{}
+	for p.isKw("except") {
+		pos := p.pos()
+		p.next()
+		var typ node
+		var name interface{}
+		if !p.isOp(":") {
+			typ = p.test()
+			if p.py2 && (p.isOp(",") || p.isKw("as")) {
+				// the name of Python 2 handlers is any assignment target
+				p.next()
+				first := p.tok()
+				e := p.test()
+				setContext(p, e, ctxStore, first)
+				name = e
+			} else if p.acceptKw("as") {
+				name = p.name()
+			}
+		}
+		hbody := p.block()
+		handlers = append(handlers, newNode("ExceptHandler", &pos, typ, name, hbody))
+	}
+	if len(handlers) != 0 && p.acceptKw("else") {
+		orelse = p.block()
+	}
+	final := p.acceptKw("finally")
+	if final {
+		finalbody = p.block()
+	} else if len(handlers) == 0 {
+		p.errorf("invalid syntax")
+	}
+	if p.py2 {
+		return tryStmt2(pos, body, handlers, orelse, finalbody, final)
+	}
+	return newNode("Try", &pos, body, handlers, orelse, finalbody)
+}
+
+func (p *parser) withStmt(async bool) node {
+	pos := p.pos()
+	p.expectKw("with")
+	var (
+		items []interface{}
+		poss  []position
+	)
+	if !p.py2 && p.isParenWithItems() {
+		p.next()
+		for {
+			items = append(items, p.withItem())
+			if !p.acceptOp(",") || p.isOp(")") {
+				break
+			}
+		}
+		p.expectOp(")")
+	} else {
+		for {
+			poss = append(poss, p.pos())
+			items = append(items, p.withItem())
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	body := p.block()
+	if p.py2 {
+		return withStmt2(poss, items, body)
+	}
+	typ := "With"
+	if async {
+		typ = "AsyncWith"
+	}
+	return newNode(typ, &pos, items, body)
+}
+
+// isParenWithItems checks if the current token starts the parenthesized items of a
+// with statement (Python 3.10), like "(a as b, c as d):". The items are parsed ahead
+// and the parser is rewound. A parenthesized expression followed by something else
+// than the colon, like "(a, b) as c:", is an expression.
+func (p *parser) isParenWithItems() (ok bool) {
+	if !p.isOp("(") {
+		return false
+	}
+	start := p.i
+	defer func() {
+		p.i = start
+		if r := recover(); r != nil {
+			if _, isErr := r.(*SyntaxError); !isErr {
+				panic(r)
+			}
+			ok = false
+		}
+	}()
+	p.next()
+	for {
+		p.withItem()
+		if !p.acceptOp(",") || p.isOp(")") {
+			break
+		}
+	}
+	p.expectOp(")")
+	return p.isOp(":")
+}
+
+func (p *parser) withItem() node {
+	ctx := p.test()
+	var vars node
+	if p.acceptKw("as") {
+		first := p.tok()
+		vars = p.expr()
+		setContext(p, vars, ctxStore, first)
+	}
+	return newNode("withitem", nil, ctx, vars)
+}
+
+func (p *parser) asyncStmt(decorators []interface{}) node {
+	p.next()
+	if p.isKw("def") {
+		return p.funcDef(decorators, true)
+	}
+	if decorators == nil {
+		switch {
+		case p.isKw("for"):
+			return p.forStmt(true)
+		case p.isKw("with"):
+			return p.withStmt(true)
+		}
+	}
+	p.errorf("invalid syntax")
+	return nil
+}
+
+func (p *parser) decorated() node {
+	pos := p.pos()
+	var decorators []interface{}
+	for p.isOp("@") {
+		decorators = append(decorators, p.decorator())
+	}
+	var n node
+	switch {
+	case p.isKw("def"):
+		n = p.funcDef(decorators, false)
+	case p.isKw("class"):
+		n = p.classDef(decorators)
+	case p.isAsync():
+		n = p.asyncStmt(decorators)
+	default:
+		p.errorf("invalid syntax")
+	}
+	setPos(n, pos)
+	return n
+}
+
+func (p *parser) decorator() node {
+	pos := p.pos()
+	p.expectOp("@")
+	if !p.py2 && !p.isDottedDecorator() {
+		// any expression is allowed since Python 3.9 (PEP 614), positioned as usual
+		e := p.namedExprTest()
+		p.expect(tokNewline)
+		return e
+	}
+	// all the names of a dotted name share the position of its first one
+	npos := p.pos()
+	var e node = newNode("Name", &npos, p.name(), ctxLoad)
+	for p.acceptOp(".") {
+		e = newNode("Attribute", &npos, e, p.name(), ctxLoad)
+	}
+	if p.acceptOp("(") {
+		if p.acceptOp(")") {
+			e = p.emptyCall(&pos, e)
+		} else {
+			e = p.call(e)
+		}
+	}
+	p.expect(tokNewline)
+	return e
+}
+
+// isDottedDecorator checks if the decorator at the current token is a dotted name,
+// with an optional call, the only form allowed before Python 3.9.
+func (p *parser) isDottedDecorator() bool {
+	i := p.i
+	at := func(typ tokenType, v string) bool {
+		t := &p.toks[i]
+		return t.Type == typ && (v == "" || t.Value == v)
+	}
+	for {
+		if !at(tokName, "") || p.keywords[p.toks[i].Value] {
+			return false
+		}
+		i++
+		if !at(tokOp, ".") {
+			break
+		}
+		i++
+	}
+	if at(tokOp, "(") {
+		for depth := 0; i < len(p.toks)-1; i++ {
+			if at(tokOp, "(") || at(tokOp, "[") || at(tokOp, "{") {
+				depth++
+			} else if at(tokOp, ")") || at(tokOp, "]") || at(tokOp, "}") {
+				if depth--; depth == 0 {
+					i++
+					break
+				}
+			}
+		}
+	}
+	return at(tokNewline, "")
+}
+
+func (p *parser) funcDef(decorators []interface{}, async bool) node {
+	pos := p.pos()
+	p.expectKw("def")
+	name := p.name()
+	p.expectOp("(")
+	if p.py2 {
+		args := p.arguments2(")")
+		p.expectOp(")")
+		body := p.block()
+		if decorators == nil {
+			decorators = []interface{}{}
+		}
+		return newNode2("FunctionDef", &pos, name, args, body, decorators)
+	}
+	args := p.arguments(")", true)
+	p.expectOp(")")
+	var returns node
+	if p.acceptOp("->") {
+		returns = p.test()
+	}
+	prev := p.async
+	if async {
+		p.async = true
+	}
+	body := p.block()
+	p.async = prev
+	if decorators == nil {
+		decorators = []interface{}{}
+	}
+	typ := "FunctionDef"
+	if async {
+		typ = "AsyncFunctionDef"
+	}
+	return newNode(typ, &pos, name, args, body, decorators, returns)
+}
+
+// arguments parses typedargslist (with annotations) or varargslist (without them)
+// until the closing token.
+func (p *parser) arguments(end string, annotated bool) node {
+	var (
+		posonly            []interface{}
+		args, defaults     []interface{}
+		kwonly, kwDefaults []interface{}
+		vararg, kwarg      node
+		star, slash        bool
+	)
+	arg := func() node {
+		pos := p.pos()
+		name := p.name()
+		var ann node
+		if annotated && p.acceptOp(":") {
+			ann = p.test()
+		}
+		return newNode("arg", &pos, name, ann)
+	}
+	for !p.isOp(end) {
+		switch {
+		case p.isOp("**"):
+			p.next()
+			kwarg = arg()
+		case p.isOp("/"):
+			// the arguments before the slash are positional-only (PEP 570)
+			t := p.next()
+			if slash || star || len(args) == 0 {
+				p.errorAt(t, "invalid syntax")
+			}
+			slash = true
+			posonly, args = args, nil
+		case p.isOp("*"):
+			t := p.next()
+			if star {
+				p.errorAt(t, "invalid syntax")
+			}
+			star = true
+			if !p.isOp(",") {
+				vararg = arg()
+			} else if nt := p.peek(1); nt.Type == tokOp && (nt.Value == end || nt.Value == "**") {
+				p.errorAt(t, "named arguments must follow bare *")
+			}
+		default:
+			a := arg()
+			var def node
+			if p.acceptOp("=") {
+				def = p.test()
+			}
+			if star {
+				kwonly = append(kwonly, a)
+				if def != nil {
+					kwDefaults = append(kwDefaults, def)
+				} else {
+					kwDefaults = append(kwDefaults, nil)
+				}
+			} else {
+				args = append(args, a)
+				if def != nil {
+					defaults = append(defaults, def)
+				} else if len(defaults) != 0 {
+					p.errorf("non-default argument follows default argument")
+				}
+			}
+		}
+		if kwarg != nil {
+			p.acceptOp(",")
+			break
+		}
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	if posonly == nil {
+		posonly = []interface{}{}
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+	if defaults == nil {
+		defaults = []interface{}{}
+	}
+	if kwonly == nil {
+		kwonly = []interface{}{}
+		kwDefaults = []interface{}{}
+	}
+	return newNode("arguments", nil, posonly, args, vararg, kwonly, kwDefaults, kwarg, defaults)
+}
+
+func (p *parser) classDef(decorators []interface{}) node {
+	pos := p.pos()
+	p.expectKw("class")
+	name := p.name()
+	if p.py2 {
+		return p.classDef2(pos, name, decorators)
+	}
+	bases := []interface{}{}
+	kws := []interface{}{}
+	if p.acceptOp("(") {
+		if !p.acceptOp(")") {
+			c := p.call(nil)
+			bases = c["args"].([]interface{})
+			kws = c["keywords"].([]interface{})
+		}
+	}
+	body := p.block()
+	if decorators == nil {
+		decorators = []interface{}{}
+	}
+	return newNode("ClassDef", &pos, name, bases, kws, body, decorators)
+}
+
+// exprListTuple parses exprlist and returns its elements and if it forms a tuple.
+func (p *parser) exprListTuple() ([]interface{}, bool) {
+	var elts []interface{}
+	tuple := false
+	for {
+		if p.isOp("*") {
+			elts = append(elts, p.starExpr())
+		} else {
+			elts = append(elts, p.expr())
+		}
+		if !p.isOp(",") {
+			break
+		}
+		p.next()
+		tuple = true
+		if !p.isOp("*") && !p.atTest() {
+			break
+		}
+	}
+	return elts, tuple
+}
+
+func (p *parser) exprList() []interface{} {
+	elts, _ := p.exprListTuple()
+	return elts
+}
+
+// seqOf parses a comma-separated list of elements and returns either the single
+// element or a tuple positioned at the first token.
+func (p *parser) seqOf(elem func() node, star bool) node {
+	pos := p.pos()
+	var elts []interface{}
+	for {
+		if star && p.isOp("*") {
+			elts = append(elts, p.starExpr())
+		} else {
+			elts = append(elts, elem())
+		}
+		if !p.isOp(",") {
+			if len(elts) == 1 {
+				return elts[0].(node)
+			}
+			break
+		}
+		p.next()
+		if !(star && p.isOp("*")) && !p.atTest() {
+			break
+		}
+	}
+	return newNode("Tuple", &pos, elts, ctxLoad)
+}
+
+func (p *parser) testList() node {
+	return p.seqOf(p.test, false)
+}
+
+func (p *parser) testListStarExpr() node {
+	return p.seqOf(p.test, true)
+}
+
+func (p *parser) starExpr() node {
+	pos := p.pos()
+	if p.py2 {
+		// there is no iterable unpacking in Python 2
+		p.errorf("invalid syntax")
+	}
+	p.expectOp("*")
+	return newNode("Starred", &pos, p.expr(), ctxLoad)
+}
+
+func (p *parser) test() node {
+	if p.isKw("lambda") {
+		return p.lambda(false)
+	}
+	pos := p.pos()
+	e := p.orTest()
+	if p.isKw("if") {
+		p.next()
+		test := p.orTest()
+		p.expectKw("else")
+		orelse := p.test()
+		return newNode("IfExp", &pos, test, e, orelse)
+	}
+	return e
+}
+
+// namedExprTest parses a test that may be an assignment expression (PEP 572).
+func (p *parser) namedExprTest() node {
+	if nt := p.peek(1); !p.py2 && p.isName() && nt.Type == tokOp && nt.Value == ":=" {
+		pos := p.pos()
+		target := newNode("Name", &pos, p.name(), ctxStore)
+		p.next()
+		return newNode("NamedExpr", &pos, target, p.test())
+	}
+	e := p.test()
+	if p.isOp(":=") {
+		// only names can be the target of an assignment expression
+		p.errorf("invalid syntax")
+	}
+	return e
+}
+
+func (p *parser) testNoCond() node {
+	if p.isKw("lambda") {
+		return p.lambda(true)
+	}
+	return p.orTest()
+}
+
+func (p *parser) lambda(nocond bool) node {
+	pos := p.pos()
+	p.expectKw("lambda")
+	var args node
+	if p.py2 {
+		args = p.arguments2(":")
+	} else {
+		args = p.arguments(":", false)
+	}
+	p.expectOp(":")
+	var body node
+	if nocond {
+		body = p.testNoCond()
+	} else {
+		body = p.test()
+	}
+	return newNode("Lambda", &pos, args, body)
+}
+
+func (p *parser) boolOp(kw, op string, sub func() node) node {
+	pos := p.pos()
+	e := sub()
+	if !p.isKw(kw) {
+		return e
+	}
+	values := []interface{}{e}
+	for p.acceptKw(kw) {
+		values = append(values, sub())
+	}
+	return newNode("BoolOp", &pos, opNode(op), values)
+}
+
+func (p *parser) orTest() node {
+	return p.boolOp("or", "Or", p.andTest)
+}
+
+func (p *parser) andTest() node {
+	return p.boolOp("and", "And", p.notTest)
+}
+
+func (p *parser) notTest() node {
+	if p.isKw("not") {
+		pos := p.pos()
+		p.next()
+		return newNode("UnaryOp", &pos, opNode("Not"), p.notTest())
+	}
+	return p.comparison()
+}
+
+func (p *parser) compOp() string {
+	t := p.tok()
+	switch t.Type {
+	case tokOp:
+		switch t.Value {
+		case "<":
+			return "Lt"
+		case ">":
+			return "Gt"
+		case "==":
+			return "Eq"
+		case ">=":
+			return "GtE"
+		case "<=":
+			return "LtE"
+		case "!=", "<>":
+			return "NotEq"
+		}
+	case tokName:
+		switch t.Value {
+		case "in":
+			return "In"
+		case "not":
+			if nt := p.peek(1); nt.Type == tokName && nt.Value == "in" {
+				return "NotIn"
+			}
+		case "is":
+			if nt := p.peek(1); nt.Type == tokName && nt.Value == "not" {
+				return "IsNot"
+			}
+			return "Is"
+		}
+	}
+	return ""
+}
+
+func (p *parser) comparison() node {
+	pos := p.pos()
+	e := p.expr()
+	if p.compOp() == "" {
+		return e
+	}
+	var ops, comps []interface{}
+	for {
+		op := p.compOp()
+		if op == "" {
+			break
+		}
+		p.next()
+		if op == "NotIn" || op == "IsNot" {
+			p.next()
+		}
+		ops = append(ops, opNode(op))
+		comps = append(comps, p.expr())
+	}
+	return newNode("Compare", &pos, e, ops, comps)
+}
+
+// binOp parses a left-associative chain of binary operators. The first operation is
+// positioned at the start of the chain and the following ones at their operators.
+func (p *parser) binOp(ops map[string]string, sub func() node) node {
+	pos := p.pos()
+	e := sub()
+	for first := true; ; first = false {
+		t := p.tok()
+		op, ok := ops[t.Value]
+		if t.Type != tokOp || !ok {
+			return e
+		}
+		opos := tokenPosition(t)
+		p.next()
+		right := sub()
+		if first {
+			opos = pos
+		}
+		e = newNode("BinOp", &opos, e, opNode(op), right)
+	}
+}
+
+var (
+	opsOr    = map[string]string{"|": "BitOr"}
+	opsXor   = map[string]string{"^": "BitXor"}
+	opsAnd   = map[string]string{"&": "BitAnd"}
+	opsShift = map[string]string{"<<": "LShift", ">>": "RShift"}
+	opsArith = map[string]string{"+": "Add", "-": "Sub"}
+	opsTerm  = map[string]string{"*": "Mult", "@": "MatMult", "/": "Div", "%": "Mod", "//": "FloorDiv"}
+	opsTerm2 = map[string]string{"*": "Mult", "/": "Div", "%": "Mod", "//": "FloorDiv"}
+)
+
+func (p *parser) expr() node {
+	return p.binOp(opsOr, p.xorExpr)
+}
+
+func (p *parser) xorExpr() node {
+	return p.binOp(opsXor, p.andExpr)
+}
+
+func (p *parser) andExpr() node {
+	return p.binOp(opsAnd, p.shiftExpr)
+}
+
+func (p *parser) shiftExpr() node {
+	return p.binOp(opsShift, p.arithExpr)
+}
+
+func (p *parser) arithExpr() node {
+	return p.binOp(opsArith, p.term)
+}
+
+func (p *parser) term() node {
+	if p.py2 {
+		return p.binOp(opsTerm2, p.factor)
+	}
+	return p.binOp(opsTerm, p.factor)
+}
+
+func (p *parser) factor() node {
+	t := p.tok()
+	if t.Type == tokOp {
+		op := ""
+		switch t.Value {
+		case "+":
+			op = "UAdd"
+		case "-":
+			op = "USub"
+		case "~":
+			op = "Invert"
+		}
+		if op != "" {
+			pos := p.pos()
+			p.next()
+			return newNode("UnaryOp", &pos, opNode(op), p.factor())
+		}
+	}
+	return p.power()
+}
+
+func (p *parser) power() node {
+	pos := p.pos()
+	e := p.atomExpr()
+	if p.acceptOp("**") {
+		return newNode("BinOp", &pos, e, opNode("Pow"), p.factor())
+	}
+	return e
+}
+
+func (p *parser) atomExpr() node {
+	pos := p.pos()
+	await := false
+	if p.async && p.isKw("await") {
+		p.next()
+		await = true
+	}
+	e := p.atom()
+	for {
+		var n node
+		switch {
+		case p.isOp("("):
+			p.next()
+			if p.acceptOp(")") {
+				n = p.emptyCall(nil, e)
+			} else {
+				n = p.call(e)
+			}
+		case p.isOp("["):
+			p.next()
+			n = p.subscript(e)
+		case p.isOp("."):
+			p.next()
+			n = newNode("Attribute", nil, e, p.name(), ctxLoad)
+		default:
+			if await {
+				return newNode("Await", &pos, e)
+			}
+			return e
+		}
+		// trailers take the position of the expression they are applied to
+		setPos(n, nodePos(e))
+		e = n
+	}
+}
+
+// emptyCall creates a call without arguments.
+func (p *parser) emptyCall(pos *position, fnc node) node {
+	if p.py2 {
+		return newNode2("Call", pos, fnc, []interface{}{}, []interface{}{}, nil, nil)
+	}
+	return newNode("Call", pos, fnc, []interface{}{}, []interface{}{})
+}
+
+// call parses arguments of a call after the opening parenthesis, including the
+// closing one. If fnc is nil, the arguments of a class definition are parsed.
+func (p *parser) call(fnc node) node {
+	if p.py2 {
+		return p.call2(fnc)
+	}
+	var (
+		args, kws []interface{}
+		nkw       int
+		ngen      int
+	)
+	for !p.isOp(")") {
+		pos := p.pos()
+		first := p.tok()
+		switch {
+		case p.isOp("*"):
+			p.next()
+			if len(kws) != nkw {
+				p.errorAt(first, "iterable argument unpacking follows keyword argument unpacking")
+			}
+			args = append(args, newNode("Starred", &pos, p.test(), ctxLoad))
+		case p.isOp("**"):
+			p.next()
+			kws = append(kws, newNode("keyword", nil, nil, p.test()))
+		default:
+			e := p.namedExprTest()
+			switch {
+			case p.isOp("="):
+				p.next()
+				if nodeType(e) == "Lambda" {
+					p.errorAt(first, "lambda cannot contain assignment")
+				} else if nodeType(e) != "Name" {
+					p.errorAt(first, "keyword can't be an expression")
+				}
+				kws = append(kws, newNode("keyword", nil, e["id"], p.test()))
+				nkw++
+			case p.isKw("for") || p.isAsync():
+				e = newNode("GeneratorExp", &pos, e, p.compFor())
+				ngen++
+				fallthrough
+			default:
+				if len(kws) != 0 {
+					if len(kws) != nkw {
+						p.errorAt(first, "positional argument follows keyword argument unpacking")
+					}
+					p.errorAt(first, "positional argument follows keyword argument")
+				}
+				args = append(args, e)
+			}
+		}
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	end := p.expectOp(")")
+	if ngen > 0 && len(args)+len(kws) > 1 {
+		p.errorAt(end, "Generator expression must be parenthesized if not sole argument")
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+	if kws == nil {
+		kws = []interface{}{}
+	}
+	var pos *position
+	if fnc != nil {
+		fp := nodePos(fnc)
+		pos = &fp
+	}
+	return newNode("Call", pos, fnc, args, kws)
+}
+
+func (p *parser) subscript(value node) node {
+	pos := p.pos()
+	var slices []node
+	tuple := false
+	for {
+		slices = append(slices, p.slice())
+		if !p.acceptOp(",") {
+			break
+		}
+		tuple = true
+		if p.isOp("]") {
+			break
+		}
+	}
+	p.expectOp("]")
+	var slice node
+	switch {
+	case !tuple:
+		slice = slices[0]
+	default:
+		simple := true
+		for _, s := range slices {
+			if nodeType(s) != "Index" {
+				simple = false
+				break
+			}
+		}
+		if !simple {
+			dims := make([]interface{}, 0, len(slices))
+			for _, s := range slices {
+				dims = append(dims, s)
+			}
+			slice = newNode("ExtSlice", nil, dims)
+		} else {
+			elts := make([]interface{}, 0, len(slices))
+			for _, s := range slices {
+				elts = append(elts, s["value"])
+			}
+			slice = newNode("Index", nil, newNode("Tuple", &pos, elts, ctxLoad))
+		}
+	}
+	return newNode("Subscript", nil, value, slice, ctxLoad)
+}
+
+func (p *parser) slice() node {
+	if p.py2 {
+		return p.slice2()
+	}
+	var lower, upper, step node
+	if !p.isOp(":") {
+		lower = p.test()
+		if !p.isOp(":") {
+			return newNode("Index", nil, lower)
+		}
+	}
+	p.expectOp(":")
+	if p.atTest() {
+		upper = p.test()
+	}
+	if p.acceptOp(":") && p.atTest() {
+		step = p.test()
+	}
+	return newNode("Slice", nil, lower, upper, step)
+}
+
+func (p *parser) atom() node {
+	pos := p.pos()
+	t := p.tok()
+	switch t.Type {
+	case tokName:
+		if p.py2 {
+			// None, True and False are names in Python 2
+			return newNode("Name", &pos, p.name(), ctxLoad)
+		}
+		switch t.Value {
+		case "None":
+			p.next()
+			return newNode("NameConstant", &pos, nil)
+		case "True", "False":
+			p.next()
+			return newNode("NameConstant", &pos, t.Value == "True")
+		}
+		return newNode("Name", &pos, p.name(), ctxLoad)
+	case tokNumber:
+		p.next()
+		parseNum := parseNumber
+		if p.py2 {
+			parseNum = parseNumber2
+		}
+		n, err := parseNum(t.Value)
+		if err != nil {
+			p.errorAt(t, "%v", err)
+		}
+		return newNode("Num", &pos, n)
+	case tokString:
+		if p.py2 {
+			return p.strings2()
+		}
+		return p.strings()
+	case tokOp:
+		switch t.Value {
+		case "`":
+			return p.repr()
+		case "...":
+			if p.py2 {
+				// Python 2 only has the ellipsis in subscripts
+				break
+			}
+			p.next()
+			return newNode("Ellipsis", &pos)
+		case "(":
+			p.next()
+			if p.acceptOp(")") {
+				return newNode("Tuple", &pos, []interface{}{}, ctxLoad)
+			}
+			ipos := p.pos()
+			var e node
+			if p.isKw("yield") {
+				e = p.yieldExpr()
+			} else {
+				first, gen, elts := p.testListComp()
+				switch {
+				case gen != nil:
+					e = newNode("GeneratorExp", &ipos, first, gen)
+				case elts != nil:
+					e = newNode("Tuple", &ipos, elts, ctxLoad)
+				default:
+					e = first
+				}
+			}
+			p.expectOp(")")
+			return e
+		case "[":
+			p.next()
+			var e node
+			if p.isOp("]") {
+				e = newNode("List", &pos, []interface{}{}, ctxLoad)
+			} else {
+				ipos := p.pos()
+				first, gen, elts := p.testListComp()
+				switch {
+				case gen != nil:
+					e = newNode("ListComp", &ipos, first, gen)
+				case elts != nil:
+					e = newNode("List", &pos, elts, ctxLoad)
+				default:
+					e = newNode("List", &pos, []interface{}{first}, ctxLoad)
+				}
+			}
+			p.expectOp("]")
+			return e
+		case "{":
+			p.next()
+			var e node
+			if p.isOp("}") {
+				e = newNode("Dict", &pos, []interface{}{}, []interface{}{})
+			} else {
+				e = p.dictOrSet()
+			}
+			p.expectOp("}")
+			switch typ := nodeType(e); {
+			case !p.py2, typ == "Dict", typ == "Set":
+				// Python 2 comprehensions keep the position of their first token
+				setPos(e, pos)
+			}
+			return e
+		}
+	}
+	p.errorf("invalid syntax")
+	return nil
+}
+
+// testListComp parses testlist_comp. It returns the first element, the comprehension
+// clauses if it is a comprehension and all the elements if it is a sequence.
+func (p *parser) testListComp() (first node, gen []interface{}, elts []interface{}) {
+	if p.isOp("*") {
+		first = p.starExpr()
+	} else {
+		first = p.namedExprTest()
+	}
+	if p.isKw("for") || p.isAsync() {
+		return first, p.compFor(), nil
+	}
+	elts = []interface{}{first}
+	for p.acceptOp(",") {
+		if !p.isOp("*") && !p.atTest() {
+			break
+		}
+		if p.isOp("*") {
+			elts = append(elts, p.starExpr())
+		} else {
+			elts = append(elts, p.namedExprTest())
+		}
+	}
+	if len(elts) == 1 && !p.tupleComma() {
+		elts = nil
+	}
+	return first, nil, elts
+}
+
+// tupleComma checks if the last consumed token is a comma.
+func (p *parser) tupleComma() bool {
+	t := &p.toks[p.i-1]
+	return t.Type == tokOp && t.Value == ","
+}
+
+func (p *parser) dictOrSet() node {
+	pos := p.pos()
+	var (
+		keys, values []interface{}
+		isDict       bool
+	)
+	elem := func(first bool) {
+		if p.isOp("**") {
+			if p.py2 || !first && !isDict {
+				p.errorf("invalid syntax")
+			}
+			isDict = true
+			p.next()
+			keys = append(keys, nil)
+			values = append(values, p.expr())
+			return
+		}
+		if p.isOp("*") {
+			if !first && isDict {
+				p.errorf("invalid syntax")
+			}
+			values = append(values, p.starExpr())
+			return
+		}
+		k := p.namedExprTest()
+		if first && p.isOp(":") && nodeType(k) != "NamedExpr" {
+			isDict = true
+		}
+		if isDict {
+			p.expectOp(":")
+			keys = append(keys, k)
+			values = append(values, p.test())
+		} else {
+			values = append(values, k)
+		}
+	}
+	elem(true)
+	if p.isKw("for") || p.isAsync() {
+		if isDict {
+			if keys[0] == nil {
+				p.errorf("dict unpacking cannot be used in dict comprehension")
+			}
+			return newNode("DictComp", &pos, keys[0], values[0], p.compFor())
+		}
+		if nodeType(values[0].(node)) == "Starred" {
+			p.errorf("iterable unpacking cannot be used in comprehension")
+		}
+		return newNode("SetComp", &pos, values[0], p.compFor())
+	}
+	for p.acceptOp(",") {
+		if p.isOp("}") {
+			break
+		}
+		elem(false)
+	}
+	if isDict {
+		return newNode("Dict", &pos, keys, values)
+	}
+	return newNode("Set", &pos, values)
+}
+
+// compFor parses a chain of comprehension clauses.
+func (p *parser) compFor() []interface{} {
+	var gens []interface{}
+	for p.isKw("for") || p.isAsync() {
+		async := 0
+		if p.isAsync() {
+			p.next()
+			async = 1
+		}
+		p.expectKw("for")
+		first := p.tok()
+		target := p.forTarget()
+		setContext(p, target, ctxStore, first)
+		p.expectKw("in")
+		iter := p.orTest()
+		ifs := []interface{}{}
+		for p.acceptKw("if") {
+			ifs = append(ifs, p.testNoCond())
+		}
+		if p.py2 {
+			gens = append(gens, newNode2("comprehension", nil, target, iter, ifs))
+		} else {
+			gens = append(gens, newNode("comprehension", nil, target, iter, ifs, async))
+		}
+	}
+	return gens
+}
+
+func (p *parser) yieldExpr() node {
+	pos := p.pos()
+	p.expectKw("yield")
+	if !p.py2 && p.acceptKw("from") {
+		return newNode("YieldFrom", &pos, p.test())
+	}
+	var v node
+	if p.atTest() || p.isOp("*") {
+		v = p.testListStarExpr()
+	}
+	return newNode("Yield", &pos, v)
+}
+
+// setContext sets the expression context of the assignment or deletion target.
+func setContext(p *parser, e node, ctx string, at *token) {
+	name := ""
+	switch nodeType(e) {
+	case "Name":
+		if e["id"] == "__debug__" {
+			p.errorAt(at, "assignment to keyword")
+		}
+		e["ctx"] = ctx
+		return
+	case "Attribute":
+		if ctx == ctxStore && e["attr"] == "__debug__" {
+			p.errorAt(at, "assignment to keyword")
+		}
+		e["ctx"] = ctx
+		return
+	case "Subscript":
+		e["ctx"] = ctx
+		return
+	case "Starred":
+		e["ctx"] = ctx
+		setContext(p, e["value"].(node), ctx, at)
+		return
+	case "List":
+		e["ctx"] = ctx
+		for _, el := range e["elts"].([]interface{}) {
+			setContext(p, el.(node), ctx, at)
+		}
+		return
+	case "Tuple":
+		elts := e["elts"].([]interface{})
+		if len(elts) == 0 {
+			name = "()"
+			break
+		}
+		e["ctx"] = ctx
+		for _, el := range elts {
+			setContext(p, el.(node), ctx, at)
+		}
+		return
+	case "Lambda":
+		name = "lambda"
+	case "Call":
+		name = "function call"
+	case "BoolOp", "BinOp", "UnaryOp":
+		name = "operator"
+	case "GeneratorExp":
+		name = "generator expression"
+	case "Yield", "YieldFrom":
+		name = "yield expression"
+	case "Await":
+		name = "await expression"
+	case "ListComp":
+		name = "list comprehension"
+	case "SetComp":
+		name = "set comprehension"
+	case "DictComp":
+		name = "dict comprehension"
+	case "Dict", "Set", "Num", "Str", "Bytes", "JoinedStr", "FormattedValue":
+		name = "literal"
+	case "NameConstant":
+		name = "keyword"
+	case "Ellipsis":
+		name = "Ellipsis"
+	case "Compare":
+		name = "comparison"
+	case "IfExp":
+		name = "conditional expression"
+	case "NamedExpr":
+		name = "named expression"
+	default:
+		name = strings.ToLower(nodeType(e))
+	}
+	verb := "assign to"
+	if ctx == ctxDel {
+		verb = "delete"
+	}
+	p.errorAt(at, "can't %s %s", verb, name)
+}
@@ -0,0 +1,315 @@
+package parser
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+const fixturesDir = "../../fixtures"
+
+// TestParseFixtures checks that the parser produces the same AST as the Python native
+// driver for all fixtures, with the version it detects, and the partial AST of the one
+// with a syntax error.
+func TestParseFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(fixturesDir, "*.py.native"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, path := range files {
+		exp, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		name := strings.TrimSuffix(filepath.Base(path), ".native")
+		t.Run(name, func(t *testing.T) {
+			src, err := ioutil.ReadFile(strings.TrimSuffix(path, ".native"))
+			require.NoError(t, err)
+
+			ast, err := Parse(string(src))
+			if strings.HasPrefix(name, "_syntax_error") {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			expAST, err := uastyaml.Unmarshal(exp)
+			require.NoError(t, err)
+			if !nodes.Equal(expAST, ast) {
+				got, err := uastyaml.Marshal(ast)
+				require.NoError(t, err)
+				require.Equal(t, string(exp), string(got))
+			}
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	ast, err := NewDriver().Parse(context.Background(), "")
+	require.NoError(t, err)
+	require.Equal(t, nodes.Object{"PY3AST": nodes.Object{
+		"ast_type":   nodes.String("Module"),
+		"lineno":     nodes.Int(1),
+		"col_offset": nodes.Int(1),
+	}}, ast)
+}
+
+func TestParseSyntaxError(t *testing.T) {
+	src, err := ioutil.ReadFile(filepath.Join(fixturesDir, "_syntax_error.py"))
+	require.NoError(t, err)
+
+	ast, err := NewDriver().Parse(context.Background(), string(src))
+	require.Error(t, err)
+	require.False(t, driver.ErrDriverFailure.Is(err))
+	require.Equal(t, &SyntaxError{
+		Msg: "invalid syntax", Line: 2, Col: 4, EndLine: 2, EndCol: 5,
+	}, err)
+	require.Equal(t, "invalid syntax (line 2, column 4 to line 2, column 5)", err.Error())
+	// the function is kept without the statement with the error
+	require.Equal(t, []string{"FunctionDef"}, stmtTypes(ast))
+}
+
+// stmtTypes returns the types of the top-level statements of a native AST.
+func stmtTypes(ast nodes.Node) []string {
+	mod, _ := ast.(nodes.Object)["PY3AST"].(nodes.Object)
+	body, _ := mod["body"].(nodes.Array)
+	var out []string
+	for _, st := range body {
+		typ, _ := st.(nodes.Object)["ast_type"].(nodes.String)
+		out = append(out, string(typ))
+	}
+	return out
+}
+
+func TestParsePartial(t *testing.T) {
+	const src = `import os
+
+def f(a):
+    x = = 2
+    return x
+
+class K:
+    def m(self) pass
+    def n(self):
+        return 1
+
+while True:
+      pass
+    y = 3
+
+print(f)
+`
+	ast, err := Parse(src)
+	require.NotNil(t, ast)
+	multi, ok := err.(*driver.ErrMulti)
+	require.True(t, ok, "%T", err)
+	require.Equal(t, []error{
+		&SyntaxError{Msg: "invalid syntax", Line: 4, Col: 8, EndLine: 4, EndCol: 9},
+		&SyntaxError{Msg: "invalid syntax", Line: 8, Col: 16, EndLine: 8, EndCol: 20},
+		&SyntaxError{Msg: "unindent does not match any outer indentation level", Line: 14, Col: 4},
+	}, multi.Errors)
+	// the tokens after the indentation error are dropped
+	require.Equal(t, []string{"Import", "FunctionDef", "ClassDef", "While"}, stmtTypes(ast))
+}
+
+func TestParseExpression(t *testing.T) {
+	ast, err := ParseExpression("List[int]")
+	require.NoError(t, err)
+	obj, ok := ast.(nodes.Object)
+	require.True(t, ok, "%T", ast)
+	require.Equal(t, nodes.String("Subscript"), obj["ast_type"])
+
+	for _, src := range []string{"x = 1", "import os", "a\nb", "List["} {
+		_, err = ParseExpression(src)
+		require.Error(t, err, src)
+		_, ok = err.(*SyntaxError)
+		require.True(t, ok, "%T", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, c := range []struct {
+		name, src string
+		enc, text string
+	}{
+		{"utf8", "x = 'é'\n", "utf-8", "x = 'é'\n"},
+		{"bom", "\xef\xbb\xbfx = 'é'\n", "utf-8-sig", "x = 'é'\n"},
+		{"bom cookie", "\xef\xbb\xbf# coding: utf-8\n", "utf-8-sig", "# coding: utf-8\n"},
+		{"latin1", "# -*- coding: latin-1 -*-\nx = '\xe9'\n", "iso-8859-1", "# -*- coding: latin-1 -*-\nx = 'é'\n"},
+		{"second line", "#!/usr/bin/python\n# vim: set fileencoding=cp1252 :\n'\x80'\n", "cp1252", "#!/usr/bin/python\n# vim: set fileencoding=cp1252 :\n'€'\n"},
+		{"after code", "x = 1\n# coding: latin-1\n'é'\n", "utf-8", "x = 1\n# coding: latin-1\n'é'\n"},
+		{"ascii", "# coding: ascii\nx = 1\n", "ascii", "# coding: ascii\nx = 1\n"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := Decode(c.src)
+			require.NoError(t, err)
+			require.Equal(t, c.enc, s.Encoding)
+			require.Equal(t, c.text, s.Text)
+		})
+	}
+	for _, src := range []string{
+		"# coding: klingon\n",
+		"\xef\xbb\xbf# coding: latin-1\n",
+		"# coding: utf-8\nx = '\xe9'\n",
+		"\xef\xbb\xbfx = '\xe9'\n",
+		"x = '\xe9'\n",
+		"# coding: ascii\nx = '\xe9'\n",
+	} {
+		_, err := Decode(src)
+		require.Error(t, err, src)
+		_, ok := err.(*SyntaxError)
+		require.True(t, ok, "%T", err)
+	}
+}
+
+func TestSourceOffset(t *testing.T) {
+	for _, c := range []struct {
+		src  string
+		line int
+		col  int
+		off  int
+	}{
+		{"x = 1\n", 1, 5, 4},
+		{"\xef\xbb\xbfx = 1\n", 1, 5, 7},
+		{"s = 'é'; y\n", 1, 10, 10},
+		{"# coding: latin-1\ns = '\xe9'; y\n", 2, 10, 27},
+		{"# coding: shift_jis\ns = '\x82\xa0'; y\n", 2, 10, 30},
+		{"x = 1\n", 3, 1, 6},
+		{"x = 1\ny\n", 1, 20, 6},
+		{"s = 'é'\ny\n", 1, 20, 9},
+	} {
+		s, err := Decode(c.src)
+		require.NoError(t, err)
+		require.Equal(t, c.off, s.Offset(c.line, c.col), "%q", c.src)
+	}
+}
+
+func TestParseEncoded(t *testing.T) {
+	ast, err := Parse("# coding: latin-1\ns = '\xe9t\xe9'\n")
+	require.NoError(t, err)
+	mod, _ := ast.(nodes.Object)["PY3AST"].(nodes.Object)
+	body, _ := mod["body"].(nodes.Array)
+	require.Len(t, body, 1)
+	value, _ := body[0].(nodes.Object)["value"].(nodes.Object)
+	require.Equal(t, nodes.String("été"), value["s"])
+}
+
+func TestParseVersion(t *testing.T) {
+	rootKey := func(ast nodes.Node) string {
+		for k := range ast.(nodes.Object) {
+			return k
+		}
+		return ""
+	}
+	for _, c := range []struct {
+		name string
+		src  string
+		v    Version
+		exp  Version
+		err  bool
+	}{
+		{"auto py3", "print(1)\n", Auto, Python3, false},
+		{"auto py2", "print 1\n", Auto, Python2, false},
+		{"auto error", "print 1 +\n", Auto, Python3, true},
+		{"py2", "print(1)\n", Python2, Python2, false},
+		{"py3", "print 1\n", Python3, Python3, true},
+		{"py2 empty", "", Python2, Python2, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ast, v, err := ParseVersion(c.src, c.v)
+			if c.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, c.exp, v)
+			require.Equal(t, c.exp.rootKey(), rootKey(ast))
+		})
+	}
+
+	d := NewDriver()
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "python2"))
+	ast, err := d.Parse(ctx, "print(1)\n")
+	require.NoError(t, err)
+	require.Equal(t, "PY2AST", rootKey(ast))
+
+	ast, err = d.Parse(WithVersion(ctx, Python3), "print(1)\n")
+	require.NoError(t, err)
+	require.Equal(t, "PY3AST", rootKey(ast))
+
+	d.Version = Python2
+	ast, err = d.Parse(context.Background(), "print(1)\n")
+	require.NoError(t, err)
+	require.Equal(t, "PY2AST", rootKey(ast))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "4"))
+	_, err = d.Parse(ctx, "print(1)\n")
+	require.True(t, driver.ErrDriverFailure.Is(err))
+}
+
+func TestLookupVersion(t *testing.T) {
+	for name, exp := range map[string]Version{
+		"":          Auto,
+		"auto":      Auto,
+		"python":    Auto,
+		"2":         Python2,
+		"2.7":       Python2,
+		"python2":   Python2,
+		"Python-2":  Python2,
+		"3":         Python3,
+		"python3.6": Python3,
+		"py3":       Python3,
+	} {
+		v, ok := LookupVersion(name)
+		require.True(t, ok, name)
+		require.Equal(t, exp, v, name)
+	}
+	for _, name := range []string{"4", "27", "ruby"} {
+		_, ok := LookupVersion(name)
+		require.False(t, ok, name)
+	}
+}
+
+func TestParsePython2(t *testing.T) {
+	const src = "from __future__ import print_function\n" +
+		"print(x, file=f)\n" +
+		"n = 0777 + 10L + `a` if a <> b else ur'x'\n" +
+		"def f((a, b), c=1, *args, **kw):\n" +
+		"    exec code in g\n" +
+		"    raise E, 'msg', tb\n" +
+		"try:\n" +
+		"    pass\n" +
+		"except (A, B), e:\n" +
+		"    pass\n" +
+		"finally:\n" +
+		"    pass\n" +
+		"with a as b, c:\n" +
+		"    pass\n"
+	ast, v, err := ParseVersion(src, Python2)
+	require.NoError(t, err)
+	require.Equal(t, Python2, v)
+	mod, _ := ast.(nodes.Object)["PY2AST"].(nodes.Object)
+	body, _ := mod["body"].(nodes.Array)
+	var types []string
+	for _, st := range body {
+		typ, _ := st.(nodes.Object)["ast_type"].(nodes.String)
+		types = append(types, string(typ))
+	}
+	require.Equal(t, []string{"ImportFrom", "Expr", "Assign", "FunctionDef", "TryFinally", "With"}, types)
+
+	for _, src := range []string{
+		"exec\n",
+		"f(a=1, b)\n",
+		"x = 1 @ 2\n",
+		"nonlocal x\n",
+	} {
+		_, _, err := ParseVersion(src, Python2)
+		require.Error(t, err, src)
+	}
+}
@@ -0,0 +1,421 @@
+package parser
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// keywords2 are the keywords of Python 2.7. None, True, False and nonlocal are names
+// there, and print and exec are statements.
+var keywords2 = map[string]bool{
+	"and": true, "as": true, "assert": true, "break": true, "class": true, "continue": true,
+	"def": true, "del": true, "elif": true, "else": true, "except": true, "exec": true,
+	"finally": true, "for": true, "from": true, "global": true, "if": true, "import": true,
+	"in": true, "is": true, "lambda": true, "not": true, "or": true, "pass": true,
+	"print": true, "raise": true, "return": true, "try": true, "while": true, "with": true,
+	"yield": true,
+}
+
+// tokens2 adapts the tokens of the Python 3 tokenizer to the Python 2 grammar: the
+// backquotes are operators, and the "<>" operator, the octal and long numbers and the
+// "ur" strings, that the tokenizer splits, are joined back. Only the parser uses these
+// tokens, the positions are fixed with the original ones, like the Python driver does.
+func tokens2(toks []token) []token {
+	out := make([]token, 0, len(toks))
+	for _, t := range toks {
+		if t.Type == tokErrorToken {
+			switch strings.TrimSpace(t.Value) {
+			case "":
+				continue
+			case "`":
+				t.Type = tokOp
+			}
+		}
+		if n := len(out); n != 0 && joinTokens2(&out[n-1], &t) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// joinTokens2 appends the token to the previous one if they are parts of a single
+// Python 2 token.
+func joinTokens2(prev, t *token) bool {
+	if prev.End.Row != t.Start.Row || prev.End.Col != t.Start.Col {
+		return false
+	}
+	switch {
+	case prev.Type == tokOp && prev.Value == "<" && t.Type == tokOp && t.Value == ">":
+	case prev.Type == tokNumber && t.Type == tokNumber && strings.Trim(prev.Value, "0") == "":
+	case prev.Type == tokNumber && t.Type == tokName && (t.Value == "L" || t.Value == "l"):
+	case prev.Type == tokName && t.Type == tokString && strings.EqualFold(prev.Value, "ur"):
+		prev.Type = tokString
+	default:
+		return false
+	}
+	prev.Value += t.Value
+	prev.End = t.End
+	return true
+}
+
+// future enables the features of a "from __future__ import" statement that change
+// the Python 2 grammar.
+func (p *parser) future(names []interface{}) {
+	for _, n := range names {
+		if n.(node)["name"] != "print_function" || !p.keywords["print"] {
+			continue
+		}
+		kw := make(map[string]bool, len(p.keywords))
+		for k, v := range p.keywords {
+			if k != "print" {
+				kw[k] = v
+			}
+		}
+		p.keywords = kw
+	}
+}
+
+func (p *parser) printStmt() node {
+	pos := p.pos()
+	p.expectKw("print")
+	var dest node
+	values := []interface{}{}
+	if p.acceptOp(">>") {
+		dest = p.test()
+		if !p.acceptOp(",") {
+			return newNode2("Print", &pos, dest, values, true)
+		}
+		if !p.atTest() {
+			p.errorf("invalid syntax")
+		}
+	}
+	// a trailing comma suppresses the newline
+	nl := true
+	for p.atTest() {
+		values = append(values, p.test())
+		nl = !p.acceptOp(",")
+		if nl {
+			break
+		}
+	}
+	return newNode2("Print", &pos, dest, values, nl)
+}
+
+func (p *parser) execStmt() node {
+	pos := p.pos()
+	p.expectKw("exec")
+	body := p.expr()
+	var globals, locals node
+	if p.acceptKw("in") {
+		globals = p.test()
+		if p.acceptOp(",") {
+			locals = p.test()
+		}
+	}
+	return newNode2("Exec", &pos, body, globals, locals)
+}
+
+// raiseStmt2 parses the raise statement of Python 2, that takes the type, the value
+// and the traceback of the exception.
+func (p *parser) raiseStmt2() node {
+	pos := p.pos()
+	p.expectKw("raise")
+	var typ, inst, tback node
+	if p.atTest() {
+		typ = p.test()
+		if p.acceptOp(",") {
+			inst = p.test()
+			if p.acceptOp(",") {
+				tback = p.test()
+			}
+		}
+	}
+	return newNode2("Raise", &pos, typ, inst, tback)
+}
+
+// tryStmt2 creates the Python 2 node of a try statement: a TryExcept for the handlers
+// and a TryFinally for the finally clause, that has the TryExcept as its body if the
+// statement has both.
+func tryStmt2(pos position, body, handlers, orelse, finalbody []interface{}, final bool) node {
+	if len(handlers) != 0 {
+		n := newNode2("TryExcept", &pos, body, handlers, orelse)
+		if !final {
+			return n
+		}
+		body = []interface{}{n}
+	}
+	return newNode2("TryFinally", &pos, body, finalbody)
+}
+
+// withStmt2 creates the Python 2 node of a with statement, that has a single context
+// manager. The statements with several ones are nested, each positioned at its item.
+func withStmt2(poss []position, items, body []interface{}) node {
+	var n node
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i].(node)
+		n = newNode2("With", &poss[i], item["context_expr"], item["optional_vars"], body)
+		body = []interface{}{n}
+	}
+	return n
+}
+
+// arguments2 parses the Python 2 varargslist until the closing token. The parameters
+// are names, or tuples of them for the ones unpacking the argument, and the variadic
+// ones are only strings.
+func (p *parser) arguments2(end string) node {
+	var (
+		args, defaults []interface{}
+		vararg, kwarg  interface{}
+	)
+	for !p.isOp(end) {
+		switch {
+		case p.isOp("**"):
+			p.next()
+			kwarg = p.name()
+		case p.isOp("*") && vararg == nil:
+			p.next()
+			vararg = p.name()
+		case vararg != nil:
+			// only **kwargs may follow *args
+			p.errorf("invalid syntax")
+		default:
+			a := p.fpdef(ctxParam)
+			if p.acceptOp("=") {
+				defaults = append(defaults, p.test())
+			} else if len(defaults) != 0 {
+				p.errorf("non-default argument follows default argument")
+			}
+			args = append(args, a)
+		}
+		if kwarg != nil || !p.acceptOp(",") {
+			break
+		}
+		if vararg != nil && p.isOp(end) {
+			p.errorf("invalid syntax")
+		}
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+	if defaults == nil {
+		defaults = []interface{}{}
+	}
+	return newNode2("arguments", nil, args, vararg, kwarg, defaults)
+}
+
+// fpdef parses a parameter of a Python 2 function. The parenthesized lists of them
+// are tuples positioned at their first element, with names stored to, unless they have
+// a single element and no comma.
+func (p *parser) fpdef(ctx string) node {
+	if !p.acceptOp("(") {
+		pos := p.pos()
+		return newNode("Name", &pos, p.name(), ctx)
+	}
+	pos := p.pos()
+	var elts []interface{}
+	comma := false
+	for {
+		elts = append(elts, p.fpdef(ctxStore))
+		if !p.acceptOp(",") {
+			break
+		}
+		comma = true
+		if p.isOp(")") {
+			break
+		}
+	}
+	p.expectOp(")")
+	if e := elts[0].(node); len(elts) == 1 && !comma {
+		if nodeType(e) == "Name" {
+			e["ctx"] = ctx
+		}
+		return e
+	}
+	return newNode("Tuple", &pos, elts, ctxStore)
+}
+
+func (p *parser) classDef2(pos position, name string, decorators []interface{}) node {
+	bases := []interface{}{}
+	if p.acceptOp("(") {
+		for !p.isOp(")") {
+			bases = append(bases, p.test())
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		p.expectOp(")")
+	}
+	body := p.block()
+	if decorators == nil {
+		decorators = []interface{}{}
+	}
+	return newNode2("ClassDef", &pos, name, bases, body, decorators)
+}
+
+// call2 parses the arguments of a Python 2 call after the opening parenthesis. The
+// *args and **kwargs arguments are stored apart, and only keyword arguments may follow
+// them.
+func (p *parser) call2(fnc node) node {
+	var (
+		args, kws        []interface{}
+		starargs, kwargs node
+		ngen             int
+	)
+	for !p.isOp(")") {
+		pos := p.pos()
+		first := p.tok()
+		star := true
+		switch {
+		case kwargs != nil:
+			p.errorf("invalid syntax")
+		case p.isOp("*") && starargs == nil:
+			p.next()
+			starargs = p.test()
+		case p.isOp("**"):
+			p.next()
+			kwargs = p.test()
+		default:
+			star = false
+			e := p.test()
+			switch {
+			case p.isOp("="):
+				p.next()
+				if nodeType(e) == "Lambda" {
+					p.errorAt(first, "lambda cannot contain assignment")
+				} else if nodeType(e) != "Name" {
+					p.errorAt(first, "keyword can't be an expression")
+				}
+				kws = append(kws, newNode("keyword", nil, e["id"], p.test()))
+			case p.isKw("for"):
+				e = newNode("GeneratorExp", &pos, e, p.compFor())
+				ngen++
+				fallthrough
+			default:
+				if len(kws) != 0 {
+					p.errorAt(first, "non-keyword arg after keyword arg")
+				} else if starargs != nil {
+					p.errorAt(first, "only named arguments may follow *expression")
+				}
+				args = append(args, e)
+			}
+		}
+		if !p.acceptOp(",") {
+			break
+		}
+		if star && p.isOp(")") {
+			p.errorf("invalid syntax")
+		}
+	}
+	end := p.expectOp(")")
+	if ngen > 0 && len(args)+len(kws) > 1 {
+		p.errorAt(end, "Generator expression must be parenthesized if not sole argument")
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+	if kws == nil {
+		kws = []interface{}{}
+	}
+	pos := nodePos(fnc)
+	return newNode2("Call", &pos, fnc, args, kws, starargs, kwargs)
+}
+
+// slice2 parses a Python 2 slice. The ellipsis is a slice of its own, and the empty
+// step of the extended slices is the None name positioned at its colon.
+func (p *parser) slice2() node {
+	if p.acceptOp("...") {
+		return newNode("Ellipsis", nil)
+	}
+	var lower, upper, step node
+	if !p.isOp(":") {
+		lower = p.test()
+		if !p.isOp(":") {
+			return newNode("Index", nil, lower)
+		}
+	}
+	p.expectOp(":")
+	if p.atTest() {
+		upper = p.test()
+	}
+	if p.isOp(":") {
+		pos := p.pos()
+		p.next()
+		if p.atTest() {
+			step = p.test()
+		} else {
+			step = newNode("Name", &pos, "None", ctxLoad)
+		}
+	}
+	return newNode("Slice", nil, lower, upper, step)
+}
+
+// repr parses the backquotes of Python 2, that are the repr of the expression.
+func (p *parser) repr() node {
+	pos := p.pos()
+	p.expectOp("`")
+	v := p.testList()
+	p.expectOp("`")
+	return newNode2("Repr", &pos, v)
+}
+
+// strings2 parses concatenated Python 2 string literals. They are all Str nodes, and
+// the ones without the "u" prefix are byte strings, that only have the escapes of
+// bytes.
+func (p *parser) strings2() node {
+	pos := p.pos()
+	var b strings.Builder
+	for p.is(tokString) {
+		t := p.next()
+		prefix, body := splitStringToken(t.Value)
+		if strings.Contains(prefix, "f") {
+			p.errorAt(t, "invalid syntax")
+		}
+		v := body
+		if !strings.Contains(prefix, "r") {
+			var err error
+			v, err = unescape(body, !strings.Contains(prefix, "u"))
+			if err != nil {
+				p.errorAt(t, "%v", err)
+			}
+		}
+		b.WriteString(v)
+	}
+	return newNode("Str", &pos, latin1Invalid(b.String()))
+}
+
+// latin1Invalid decodes the bytes of the string that are not valid UTF-8 as Latin-1,
+// like the escaped bytes of the Python 2 byte strings.
+func latin1Invalid(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size <= 1 {
+			r = rune(s[i])
+		}
+		b.WriteRune(r)
+		i += size
+	}
+	return b.String()
+}
+
+// parseNumber2 parses a Python 2 NUMBER token, that may be a long integer with the "L"
+// suffix or an octal one with a leading zero.
+func parseNumber2(s string) (*pyNumber, error) {
+	if last := s[len(s)-1]; last == 'L' || last == 'l' {
+		s = s[:len(s)-1]
+		if strings.ContainsAny(s, ".eEjJ") && !strings.HasPrefix(strings.ToLower(s), "0x") {
+			return nil, fmt.Errorf("invalid syntax")
+		}
+	}
+	if len(s) > 1 && s[0] == '0' && strings.Trim(s, "01234567") == "" {
+		v, _ := new(big.Int).SetString(s[1:], 8)
+		return &pyNumber{Int: v}, nil
+	}
+	return parseNumber(s)
+}
@@ -0,0 +1,321 @@
+package parser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/runenames"
+)
+
+// strLiteral is a decoded string token.
+type strLiteral struct {
+	Bytes  bool
+	Raw    bool
+	Format bool
+	// Value is the decoded value for normal strings and the raw body for f-strings.
+	Value string
+}
+
+// splitStringToken splits the string token into the prefix and the body without quotes.
+func splitStringToken(tok string) (prefix, body string) {
+	i := strings.IndexAny(tok, `'"`)
+	prefix, tok = strings.ToLower(tok[:i]), tok[i:]
+	q := 1
+	if len(tok) >= 6 && (strings.HasPrefix(tok, `"""`) || strings.HasPrefix(tok, `'''`)) {
+		q = 3
+	}
+	return prefix, tok[q : len(tok)-q]
+}
+
+// decodeString decodes a single string token the same way the Python compiler does.
+func decodeString(tok string) (*strLiteral, error) {
+	prefix, body := splitStringToken(tok)
+	lit := &strLiteral{
+		Bytes:  strings.Contains(prefix, "b"),
+		Raw:    strings.Contains(prefix, "r"),
+		Format: strings.Contains(prefix, "f"),
+	}
+	if lit.Bytes {
+		for i := 0; i < len(body); i++ {
+			if body[i] >= utf8.RuneSelf {
+				return nil, fmt.Errorf("bytes can only contain ASCII literal characters.")
+			}
+		}
+	}
+	if lit.Raw || lit.Format {
+		lit.Value = body
+		return lit, nil
+	}
+	v, err := unescape(body, lit.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	lit.Value = v
+	return lit, nil
+}
+
+// unescape decodes backslash escapes. For bytes, the result holds one byte per escape.
+func unescape(s string, isBytes bool) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("trailing \\ in string")
+		}
+		c = s[i]
+		i++
+		switch c {
+		case '\n':
+		case '\r':
+			if i < len(s) && s[i] == '\n' {
+				i++
+			}
+		case '\\', '\'', '"':
+			b.WriteByte(c)
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			v := int(c - '0')
+			for n := 1; n < 3 && i < len(s) && s[i] >= '0' && s[i] <= '7'; n++ {
+				v = v*8 + int(s[i]-'0')
+				i++
+			}
+			if isBytes {
+				b.WriteByte(byte(v))
+			} else {
+				b.WriteRune(rune(v))
+			}
+		case 'x', 'u', 'U':
+			n := 2
+			if !isBytes && c == 'u' {
+				n = 4
+			} else if !isBytes && c == 'U' {
+				n = 8
+			} else if c != 'x' {
+				b.WriteByte('\\')
+				b.WriteByte(c)
+				continue
+			}
+			if i+n > len(s) {
+				return "", fmt.Errorf("truncated \\%cXX escape", c)
+			}
+			v, err := strconv.ParseUint(s[i:i+n], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("truncated \\%cXX escape", c)
+			}
+			i += n
+			if isBytes {
+				b.WriteByte(byte(v))
+			} else if v > unicode10FFFF {
+				return "", fmt.Errorf("illegal Unicode character")
+			} else {
+				b.WriteRune(rune(v))
+			}
+		case 'N':
+			if isBytes {
+				b.WriteString(`\N`)
+				continue
+			}
+			j := strings.IndexByte(s[i:], '}')
+			if i >= len(s) || s[i] != '{' || j < 0 {
+				return "", fmt.Errorf("malformed \\N character escape")
+			}
+			r, ok := lookupRune(s[i+1 : i+j])
+			if !ok {
+				return "", fmt.Errorf("unknown Unicode character name")
+			}
+			b.WriteRune(r)
+			i += j + 1
+		default:
+			// unknown escapes are kept as is
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+const unicode10FFFF = 0x10FFFF
+
+var (
+	runeNamesOnce sync.Once
+	runeNames     map[string]rune
+)
+
+// lookupRune is an equivalent of unicodedata.lookup.
+func lookupRune(name string) (rune, bool) {
+	name = strings.ToUpper(name)
+	for _, p := range []string{"CJK UNIFIED IDEOGRAPH-", "CJK COMPATIBILITY IDEOGRAPH-"} {
+		if strings.HasPrefix(name, p) {
+			v, err := strconv.ParseUint(name[len(p):], 16, 32)
+			return rune(v), err == nil
+		}
+	}
+	runeNamesOnce.Do(func() {
+		runeNames = make(map[string]rune)
+		for r := rune(0); r <= unicode10FFFF; r++ {
+			if n := runenames.Name(r); n != "" && n[0] != '<' {
+				runeNames[n] = r
+			}
+		}
+	})
+	r, ok := runeNames[name]
+	return r, ok
+}
+
+// pyNumber is a value of a numeric literal.
+type pyNumber struct {
+	Int     *big.Int
+	Float   float64
+	Complex bool
+	IsFloat bool
+}
+
+// parseNumber parses a NUMBER token.
+func parseNumber(s string) (*pyNumber, error) {
+	s = strings.Replace(s, "_", "", -1)
+	last := s[len(s)-1]
+	if last == 'j' || last == 'J' {
+		f, err := parseFloat(s[:len(s)-1])
+		if err != nil {
+			return nil, err
+		}
+		return &pyNumber{Float: f, Complex: true}, nil
+	}
+	if len(s) > 1 && s[0] == '0' {
+		base := 0
+		switch s[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 0 {
+			v, ok := new(big.Int).SetString(s[2:], base)
+			if !ok {
+				return nil, fmt.Errorf("invalid token")
+			}
+			return &pyNumber{Int: v}, nil
+		}
+	}
+	if strings.ContainsAny(s, ".eE") {
+		f, err := parseFloat(s)
+		if err != nil {
+			return nil, err
+		}
+		return &pyNumber{Float: f, IsFloat: true}, nil
+	}
+	if len(s) > 1 && s[0] == '0' && strings.Trim(s, "0") != "" {
+		return nil, fmt.Errorf("invalid token")
+	}
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return &pyNumber{Int: v}, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange {
+			return f, nil
+		}
+		return 0, fmt.Errorf("invalid token")
+	}
+	return f, nil
+}
+
+// pyFloatRepr formats the float the same way Python's repr does.
+func pyFloatRepr(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	exp, _ := strconv.Atoi(s[strings.IndexByte(s, 'e')+1:])
+	if exp < -4 || exp >= 16 {
+		return s
+	}
+	s = strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// pyBytesRepr formats bytes the same way Python's repr does.
+func pyBytesRepr(s string) string {
+	quote := byte('\'')
+	if strings.IndexByte(s, '\'') >= 0 && strings.IndexByte(s, '"') < 0 {
+		quote = '"'
+	}
+	var b strings.Builder
+	b.WriteString("b")
+	b.WriteByte(quote)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c < ' ' || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(quote)
+	return b.String()
+}
+
+// pyBase64 is an equivalent of codecs.encode(s, 'base64').decode().strip().
+func pyBase64(s string) string {
+	enc := base64.StdEncoding.EncodeToString([]byte(s))
+	var lines []string
+	for len(enc) > 76 {
+		lines = append(lines, enc[:76])
+		enc = enc[76:]
+	}
+	lines = append(lines, enc)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,528 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenType int
+
+const (
+	tokEndMarker = tokenType(iota)
+	tokName
+	tokNumber
+	tokString
+	tokNewline
+	tokIndent
+	tokDedent
+	tokOp
+	tokComment
+	tokNL
+	tokErrorToken
+	tokEncoding
+)
+
+var tokenNames = map[tokenType]string{
+	tokEndMarker:  "ENDMARKER",
+	tokName:       "NAME",
+	tokNumber:     "NUMBER",
+	tokString:     "STRING",
+	tokNewline:    "NEWLINE",
+	tokIndent:     "INDENT",
+	tokDedent:     "DEDENT",
+	tokOp:         "OP",
+	tokComment:    "COMMENT",
+	tokNL:         "NL",
+	tokErrorToken: "ERRORTOKEN",
+	tokEncoding:   "ENCODING",
+}
+
+func (t tokenType) String() string {
+	return tokenNames[t]
+}
+
+// tokenPos is a position of the token as reported by the Python tokenize module:
+// 1-based row and 0-based column in characters. Byte is the 0-based column in bytes,
+// as used by the CPython parser.
+type tokenPos struct {
+	Row  int
+	Col  int
+	Byte int
+}
+
+// token mirrors the TokenInfo tuple produced by the Python tokenize module.
+type token struct {
+	Type  tokenType
+	Value string
+	Start tokenPos
+	End   tokenPos
+	// Line is the physical line (or lines, for multiline strings) of the token.
+	Line string
+}
+
+// multiline reports if the token spans more than one line.
+func (t *token) multiline() bool {
+	return t.Start.Row != t.End.Row
+}
+
+// SyntaxError is returned when the source cannot be tokenized or parsed. The positions
+// are the ones of the tokenize module: 1-based lines and 0-based columns in characters.
+type SyntaxError struct {
+	Msg  string
+	Line int
+	Col  int
+	// EndLine and EndCol are the end of the token where the error was found, or zero
+	// if the error has no token, like the errors of the tokenizer.
+	EndLine int
+	EndCol  int
+}
+
+func (e *SyntaxError) Error() string {
+	if e.EndLine == 0 {
+		return fmt.Sprintf("%s (line %d, column %d)", e.Msg, e.Line, e.Col)
+	}
+	return fmt.Sprintf("%s (line %d, column %d to line %d, column %d)", e.Msg, e.Line, e.Col, e.EndLine, e.EndCol)
+}
+
+func group(choices ...string) string {
+	return "(?:" + strings.Join(choices, "|") + ")"
+}
+
+func maybe(choices ...string) string {
+	return group(choices...) + "?"
+}
+
+// The expressions below are a straight port of the ones in the tokenize module
+// of Python 3.6.
+const (
+	reWhitespace = `[ \f\t]*`
+	reComment    = `#[^\r\n]*`
+	reName       = `[\p{L}\p{N}_]+`
+
+	reHexnumber = `0[xX](?:_?[0-9a-fA-F])+`
+	reBinnumber = `0[bB](?:_?[01])+`
+	reOctnumber = `0[oO](?:_?[0-7])+`
+	reDecnumber = `(?:0(?:_?0)*|[1-9](?:_?[0-9])*)`
+	reExponent  = `[eE][-+]?[0-9](?:_?[0-9])*`
+
+	reStringPrefix = `(?:[bB][rR]?|[rR][bBfF]?|[uU]|[fF][rR]?)?`
+)
+
+var (
+	reIntnumber   = group(reHexnumber, reBinnumber, reOctnumber, reDecnumber)
+	rePointfloat  = group(`[0-9](?:_?[0-9])*\.(?:[0-9](?:_?[0-9])*)?`, `\.[0-9](?:_?[0-9])*`) + maybe(reExponent)
+	reExpfloat    = `[0-9](?:_?[0-9])*` + reExponent
+	reFloatnumber = group(rePointfloat, reExpfloat)
+	reImagnumber  = group(`[0-9](?:_?[0-9])*[jJ]`, reFloatnumber+`[jJ]`)
+	reNumber      = group(reImagnumber, reFloatnumber, reIntnumber)
+
+	reTriple = group(reStringPrefix+"'''", reStringPrefix+`"""`)
+
+	reOperator = group(`\*\*=?`, `>>=?`, `<<=?`, `!=`, `//=?`, `->`, `:=`, `[+\-*/%&@|^=<>]=?`, `~`)
+	reBracket  = `[][(){}]`
+	reSpecial  = group(`\r?\n`, `\.\.\.`, `[:;.,@]`)
+	reFunny    = group(reOperator, reBracket, reSpecial)
+
+	reContStr = group(
+		reStringPrefix+`'[^\n'\\]*(?:\\.[^\n'\\]*)*`+group(`'`, `\\\r?\n`),
+		reStringPrefix+`"[^\n"\\]*(?:\\.[^\n"\\]*)*`+group(`"`, `\\\r?\n`),
+	)
+	rePseudoExtras = group(`\\\r?\n|\z`, reComment, reTriple)
+	rePseudoToken  = regexp.MustCompile(`^` + reWhitespace + `(` + group(rePseudoExtras, reNumber, reFunny, reContStr, reName) + `)`)
+
+	// string tails, keyed by the opening quote
+	endPatterns = map[string]*regexp.Regexp{
+		`'`:   regexp.MustCompile(`^[^'\\]*(?:\\(?s:.)[^'\\]*)*'`),
+		`"`:   regexp.MustCompile(`^[^"\\]*(?:\\(?s:.)[^"\\]*)*"`),
+		`'''`: regexp.MustCompile(`^[^'\\]*(?:(?:\\(?s:.)|'(?:[^']|'[^']))[^'\\]*)*'''`),
+		`"""`: regexp.MustCompile(`^[^"\\]*(?:(?:\\(?s:.)|"(?:[^"]|"[^"]))[^"\\]*)*"""`),
+	}
+)
+
+const tabSize = 8
+
+func isStringPrefix(s string) bool {
+	switch strings.ToLower(s) {
+	case "", "b", "r", "u", "f", "br", "rb", "fr", "rf":
+		return true
+	}
+	return false
+}
+
+// quoteOf returns the opening quote of a string token start (prefix included), if any.
+func quoteOf(tok string) string {
+	i := strings.IndexAny(tok, `'"`)
+	if i < 0 || i > 2 || !isStringPrefix(tok[:i]) {
+		return ""
+	}
+	q := tok[i : i+1]
+	if strings.HasPrefix(tok[i:], q+q+q) {
+		return q + q + q
+	}
+	return q
+}
+
+// splitLines splits the source the same way readline does, keeping line endings.
+func splitLines(src string) []string {
+	var lines []string
+	for len(src) != 0 {
+		i := strings.IndexByte(src, '\n')
+		if i < 0 {
+			lines = append(lines, src)
+			break
+		}
+		lines = append(lines, src[:i+1])
+		src = src[i+1:]
+	}
+	return lines
+}
+
+// tokenizer is a port of the generate_tokens function from the Python tokenize module.
+type tokenizer struct {
+	lines []string
+	toks  []token
+
+	// cols are the columns of the byte offsets of the line colsRow, or nil if it
+	// has only ASCII characters, so they are counted once for each line.
+	colsRow  int
+	colsLine string
+	cols     []int
+}
+
+func (t *tokenizer) pos(row int, line string, off int) tokenPos {
+	if off > len(line) {
+		off = len(line)
+	}
+	return tokenPos{Row: row, Col: t.col(row, line, off), Byte: off}
+}
+
+// col returns the column in characters of the byte offset of the line.
+func (t *tokenizer) col(row int, line string, off int) int {
+	if t.colsRow != row || t.colsLine != line {
+		t.colsRow, t.colsLine, t.cols = row, line, nil
+		if !isASCII(line) {
+			t.cols = make([]int, len(line)+1)
+			n, prev := 0, 0
+			for i := range line {
+				// the offsets in a character count it, like RuneCountInString
+				for j := prev + 1; j < i; j++ {
+					t.cols[j] = n
+				}
+				t.cols[i] = n
+				n, prev = n+1, i
+			}
+			for j := prev + 1; j <= len(line); j++ {
+				t.cols[j] = n
+			}
+		}
+	}
+	if t.cols == nil {
+		return off
+	}
+	return t.cols[off]
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *tokenizer) emit(typ tokenType, val string, row int, line string, start, end int) {
+	t.toks = append(t.toks, token{
+		Type: typ, Value: val, Line: line,
+		Start: t.pos(row, line, start),
+		End:   t.pos(row, line, end),
+	})
+}
+
+// tokenize splits Python source into the list of tokens, including comments,
+// non-logical newlines and the leading ENCODING token. If the source cannot be
+// tokenized, the tokens of the logical lines before the error are returned with it,
+// closing their indented blocks.
+func tokenize(src string) ([]token, error) {
+	t := &tokenizer{lines: splitLines(src)}
+	t.toks = append(t.toks, token{Type: tokEncoding, Value: "utf-8"})
+	if err := t.run(); err != nil {
+		return t.truncate(), err
+	}
+	return t.toks, nil
+}
+
+// truncate drops the tokens after the last NEWLINE and ends the stream there.
+func (t *tokenizer) truncate() []token {
+	toks := t.toks[:1]
+	for i, tok := range t.toks {
+		if tok.Type == tokNewline {
+			toks = t.toks[:i+1]
+		}
+	}
+	depth := 0
+	for _, tok := range toks {
+		switch tok.Type {
+		case tokIndent:
+			depth++
+		case tokDedent:
+			depth--
+		}
+	}
+	end := toks[len(toks)-1].End
+	pos := tokenPos{Row: end.Row + 1}
+	for ; depth > 0; depth-- {
+		toks = append(toks, token{Type: tokDedent, Start: pos, End: pos})
+	}
+	return append(toks, token{Type: tokEndMarker, Start: pos, End: pos})
+}
+
+func (t *tokenizer) run() error {
+	var (
+		lnum      = 0
+		parenlev  = 0
+		continued = false
+		indents   = []int{0}
+		lastLine  string
+
+		contStr   string
+		contLine  string
+		needCont  bool
+		strStart  tokenPos
+		strQuote  string
+		inContStr bool
+	)
+	for {
+		line := ""
+		if lnum < len(t.lines) {
+			line = t.lines[lnum]
+		}
+		lnum++
+		lastLine2 := lastLine
+		lastLine = line
+		pos, max := 0, len(line)
+
+		if inContStr {
+			if line == "" {
+				return &SyntaxError{Msg: "EOF in multi-line string", Line: strStart.Row, Col: strStart.Col}
+			}
+			if m := endPatterns[strQuote].FindStringIndex(line); m != nil {
+				end := m[1]
+				pos = end
+				t.toks = append(t.toks, token{
+					Type: tokString, Value: contStr + line[:end],
+					Start: strStart, End: t.pos(lnum, line, end),
+					Line: contLine + line,
+				})
+				contStr, contLine, needCont, inContStr = "", "", false, false
+			} else if needCont && !strings.HasSuffix(line, "\\\n") && !strings.HasSuffix(line, "\\\r\n") {
+				t.toks = append(t.toks, token{
+					Type: tokErrorToken, Value: contStr + line,
+					Start: strStart, End: t.pos(lnum, line, len(line)),
+					Line: contLine,
+				})
+				contStr, contLine, inContStr = "", "", false
+				continue
+			} else {
+				contStr += line
+				contLine += line
+				continue
+			}
+		} else if parenlev == 0 && !continued {
+			if line == "" {
+				lastLine = lastLine2
+				break
+			}
+			column := 0
+			for pos < max {
+				switch line[pos] {
+				case ' ':
+					column++
+				case '\t':
+					column = (column/tabSize + 1) * tabSize
+				case '\f':
+					column = 0
+				default:
+					goto measured
+				}
+				pos++
+			}
+		measured:
+			if pos == max {
+				break
+			}
+			if c := line[pos]; c == '#' || c == '\r' || c == '\n' {
+				if c == '#' {
+					comment := strings.TrimRight(line[pos:], "\r\n")
+					nlPos := pos + len(comment)
+					t.emit(tokComment, comment, lnum, line, pos, nlPos)
+					t.emit(tokNL, line[nlPos:], lnum, line, nlPos, len(line))
+				} else {
+					t.emit(tokNL, line[pos:], lnum, line, pos, len(line))
+				}
+				continue
+			}
+			if column > indents[len(indents)-1] {
+				indents = append(indents, column)
+				t.emit(tokIndent, line[:pos], lnum, line, 0, pos)
+			}
+			for column < indents[len(indents)-1] {
+				found := false
+				for _, ind := range indents {
+					if ind == column {
+						found = true
+						break
+					}
+				}
+				if !found {
+					return &SyntaxError{Msg: "unindent does not match any outer indentation level", Line: lnum, Col: pos}
+				}
+				indents = indents[:len(indents)-1]
+				t.emit(tokDedent, "", lnum, line, pos, pos)
+			}
+		} else {
+			if line == "" {
+				return &SyntaxError{Msg: "EOF in multi-line statement", Line: lnum, Col: 0}
+			}
+			continued = false
+		}
+
+	scan:
+		for pos < max {
+			m := rePseudoToken.FindStringSubmatchIndex(line[pos:])
+			if m == nil {
+				t.emit(tokErrorToken, line[pos:pos+1], lnum, line, pos, pos+1)
+				pos++
+				continue
+			}
+			start, end := pos+m[2], pos+m[3]
+			pos = end
+			if start == end {
+				continue
+			}
+			tok := line[start:end]
+			initial := tok[0]
+			switch {
+			case (initial >= '0' && initial <= '9') || (initial == '.' && tok != "." && tok != "..."):
+				t.emit(tokNumber, tok, lnum, line, start, end)
+			case initial == '\r' || initial == '\n':
+				if parenlev > 0 {
+					t.emit(tokNL, tok, lnum, line, start, end)
+				} else {
+					t.emit(tokNewline, tok, lnum, line, start, end)
+				}
+			case initial == '#':
+				t.emit(tokComment, tok, lnum, line, start, end)
+			case len(quoteOf(tok)) == 3:
+				q := quoteOf(tok)
+				if em := endPatterns[q].FindStringIndex(line[pos:]); em != nil {
+					pos += em[1]
+					t.emit(tokString, line[start:pos], lnum, line, start, pos)
+				} else {
+					strStart = t.pos(lnum, line, start)
+					strQuote = q
+					contStr = line[start:]
+					contLine = line
+					inContStr = true
+					break scan
+				}
+			case quoteOf(tok) != "":
+				if tok[len(tok)-1] == '\n' {
+					strStart = t.pos(lnum, line, start)
+					strQuote = quoteOf(tok)
+					contStr, needCont = line[start:], true
+					contLine = line
+					inContStr = true
+					break scan
+				}
+				t.emit(tokString, tok, lnum, line, start, end)
+			case isIdentStart(tok):
+				t.emit(tokName, tok, lnum, line, start, end)
+			case initial == '\\':
+				continued = true
+			default:
+				switch initial {
+				case '(', '[', '{':
+					parenlev++
+				case ')', ']', '}':
+					parenlev--
+				}
+				t.emit(tokOp, tok, lnum, line, start, end)
+			}
+		}
+	}
+	// add an implicit NEWLINE if the input doesn't end in a newline
+	if lastLine != "" && !strings.HasSuffix(lastLine, "\n") && !strings.HasSuffix(lastLine, "\r") {
+		row := lnum - 1
+		n := utf8.RuneCountInString(lastLine)
+		t.toks = append(t.toks, token{
+			Type: tokNewline, Value: "",
+			Start: tokenPos{Row: row, Col: n, Byte: len(lastLine)},
+			End:   tokenPos{Row: row, Col: n + 1, Byte: len(lastLine) + 1},
+		})
+	}
+	for range indents[1:] {
+		t.toks = append(t.toks, token{Type: tokDedent, Start: tokenPos{Row: lnum}, End: tokenPos{Row: lnum}})
+	}
+	t.toks = append(t.toks, token{Type: tokEndMarker, Start: tokenPos{Row: lnum}, End: tokenPos{Row: lnum}})
+	return nil
+}
+
+func isIdentStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= utf8.RuneSelf && !isDigitRune(r))
+}
+
+func isDigitRune(r rune) bool {
+	return reDigit.MatchString(string(r))
+}
+
+var reDigit = regexp.MustCompile(`^\p{N}$`)
+
+// Token is a name, number, string or operator token of the Python source.
+type Token struct {
+	Value string
+	// Start and End are the positions of the first character of the token and of the
+	// character following it.
+	Start, End Position
+}
+
+// Position is a position in the source. Line and Col are 1-based and the column is
+// in characters, as the ones of the native AST nodes. Offset is a 0-based offset in
+// bytes.
+type Position struct {
+	Offset    int
+	Line, Col int
+}
+
+// Tokenize splits the Python source to tokens. Comments, blank lines, indentation
+// and the end of logical lines are not included. The source is decoded as described
+// in Source, and the offsets of the positions are the ones of the original code.
+func Tokenize(src string) ([]Token, error) {
+	s, err := Decode(src)
+	if err != nil {
+		return nil, err
+	}
+	return s.Tokens()
+}
+
+// Tokens splits the decoded source to tokens, like Tokenize.
+func (s *Source) Tokens() ([]Token, error) {
+	toks, err := tokenize(s.Text)
+	if err != nil {
+		return nil, err
+	}
+	conv := func(p tokenPos) Position {
+		return Position{Offset: s.Offset(p.Row, p.Col+1), Line: p.Row, Col: p.Col + 1}
+	}
+	out := make([]Token, 0, len(toks))
+	for _, t := range toks {
+		switch t.Type {
+		case tokName, tokNumber, tokString, tokOp:
+			out = append(out, Token{Value: t.Value, Start: conv(t.Start), End: conv(t.End)})
+		}
+	}
+	return out, nil
+}
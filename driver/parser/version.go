@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Version is the version of the Python grammar used to parse the code.
+type Version int
+
+const (
+	// Auto parses the code as Python 3, and as Python 2 if it has syntax errors that
+	// Python 2 doesn't have, like the version detection of the Python driver.
+	Auto Version = iota
+	// Python2 parses the code with the Python 2.7 grammar. The root key of the AST is
+	// "PY2AST", and the nodes are the ones of the Python 2 AST module.
+	Python2
+	// Python3 parses the code with the Python 3 grammar. The root key of the AST is
+	// "PY3AST".
+	Python3
+)
+
+// MetadataKey is the key of the gRPC metadata with the version of the requests and of
+// the responses: "2" or "3". The requests may also have "auto" or the name of the
+// language, like "python2".
+const MetadataKey = "python-version"
+
+func (v Version) String() string {
+	switch v {
+	case Python2:
+		return "2"
+	case Python3:
+		return "3"
+	}
+	return "auto"
+}
+
+// rootKey returns the key of the root of the native AST of the version.
+func (v Version) rootKey() string {
+	if v == Python2 {
+		return "PY2AST"
+	}
+	return "PY3AST"
+}
+
+// LookupVersion returns the version with the name: "2", "3" or "auto", or the name of
+// the language with or without the version, like "python2" or "python3.6". An empty
+// name and the name of the language alone are Auto.
+func LookupVersion(name string) (Version, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, prefix := range []string{"python", "py"} {
+		if strings.HasPrefix(name, prefix) {
+			name = strings.TrimPrefix(name[len(prefix):], "-")
+			break
+		}
+	}
+	switch {
+	case name == "", name == "auto":
+		return Auto, true
+	case name == "2", strings.HasPrefix(name, "2."):
+		return Python2, true
+	case name == "3", strings.HasPrefix(name, "3."):
+		return Python3, true
+	}
+	return Auto, false
+}
+
+type versionKey struct{}
+
+// WithVersion returns a context that requests the version to the Driver.
+func WithVersion(ctx context.Context, v Version) context.Context {
+	return context.WithValue(ctx, versionKey{}, v)
+}
+
+// requestedVersion returns the version requested with WithVersion or with the gRPC
+// metadata of the request, if any.
+func requestedVersion(ctx context.Context) (Version, bool, error) {
+	if v, ok := ctx.Value(versionKey{}).(Version); ok {
+		return v, true, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	vals := md.Get(MetadataKey)
+	if len(vals) == 0 {
+		return Auto, false, nil
+	}
+	v, ok := LookupVersion(vals[0])
+	if !ok {
+		return Auto, false, fmt.Errorf("unknown Python version: %q", vals[0])
+	}
+	return v, true, nil
+}
+
+// sendVersion sends the version of the parsed code in the header of the gRPC response.
+// It does nothing if the request is not a gRPC one.
+func sendVersion(ctx context.Context, v Version) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, v.String()))
+}
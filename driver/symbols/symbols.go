@@ -0,0 +1,412 @@
+// Package symbols lists the top-level definitions of a Python module from its semantic
+// UAST, as produced by normalizer.Transforms.
+package symbols
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+)
+
+// ErrNotModule is returned when the root of the UAST is not a Python module.
+var ErrNotModule = errors.New("symbols: the root node is not a module")
+
+// Kind is the kind of definition of a symbol.
+type Kind string
+
+const (
+	Function Kind = "function"
+	Class    Kind = "class"
+	Variable Kind = "variable"
+	Import   Kind = "import"
+)
+
+// Symbol is a name defined in the module scope.
+type Symbol struct {
+	Name string
+	Kind Kind
+	// Positions are the positions of the name in its last definition.
+	Positions uast.Positions
+	// Doc is the docstring of functions and classes, or the string literal following
+	// the assignment of a variable.
+	Doc string
+	// Path is the qualified name of the imported module or name, for imports. Relative
+	// imports start with a dot for each level.
+	Path string
+	// Exported is set for the names in __all__ or, if the module doesn't define it,
+	// for the public names that are not imported.
+	Exported bool
+}
+
+// Table is the symbol table of a module.
+type Table struct {
+	// Doc is the docstring of the module.
+	Doc string
+	// Symbols are the names defined in the module, sorted by the position of their
+	// last definition.
+	Symbols []Symbol
+	// All are the names listed in __all__, or nil if it's not defined. Only literal
+	// lists and tuples of strings are supported, assigned or added to it with "+=",
+	// append or extend.
+	All []string
+}
+
+// Lookup returns the symbol with the given name.
+func (t *Table) Lookup(name string) (Symbol, bool) {
+	for _, s := range t.Symbols {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Symbol{}, false
+}
+
+// FromUAST returns the symbol table of the semantic UAST of a module. Definitions in
+// compound statements of the module, like conditional imports, are included, but not
+// the ones in functions and classes.
+func FromUAST(root nodes.Node) (*Table, error) {
+	mod, ok := root.(nodes.Object)
+	if !ok || typeOf(mod) != "Module" {
+		return nil, ErrNotModule
+	}
+	t := &Table{Doc: commentText(mod["docstring"])}
+	b := &builder{t: t, index: make(map[string]int)}
+	b.walk(mod["body"])
+
+	all := make(map[string]bool, len(t.All))
+	for _, name := range t.All {
+		all[name] = true
+	}
+	for i := range t.Symbols {
+		s := &t.Symbols[i]
+		if t.All != nil {
+			s.Exported = all[s.Name]
+		} else {
+			s.Exported = !strings.HasPrefix(s.Name, "_") && s.Kind != Import
+		}
+	}
+	sort.SliceStable(t.Symbols, func(i, j int) bool {
+		return offset(t.Symbols[i].Positions) < offset(t.Symbols[j].Positions)
+	})
+	return t, nil
+}
+
+// typeOf returns the type of the node without the namespace of the driver.
+func typeOf(n nodes.Node) string {
+	return strings.TrimPrefix(uast.TypeOf(n), "python:")
+}
+
+func offset(pos uast.Positions) uint32 {
+	if start := pos.Start(); start != nil {
+		return start.Offset
+	}
+	return 0
+}
+
+type builder struct {
+	t *Table
+	// index of the symbols by name
+	index map[string]int
+}
+
+// define sets the symbol, replacing a previous definition of the name.
+func (b *builder) define(s Symbol) {
+	if s.Name == "" {
+		return
+	}
+	if i, ok := b.index[s.Name]; ok {
+		b.t.Symbols[i] = s
+		return
+	}
+	b.index[s.Name] = len(b.t.Symbols)
+	b.t.Symbols = append(b.t.Symbols, s)
+}
+
+func (b *builder) walk(n nodes.Node) {
+	switch n := n.(type) {
+	case nodes.Array:
+		for i, st := range n {
+			var next nodes.Node
+			if i+1 < len(n) {
+				next = n[i+1]
+			}
+			b.stmt(st, next)
+		}
+	case nodes.Object:
+		b.stmt(n, nil)
+	}
+}
+
+// stmt adds the definitions of a statement. The next statement of the block is used
+// for the docstrings of variables.
+func (b *builder) stmt(n, next nodes.Node) {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		b.walk(n)
+		return
+	}
+	switch typeOf(obj) {
+	case uast.TypeOf(uast.FunctionGroup{}):
+		if meta, alias, ok := group(obj); ok {
+			b.define(Symbol{
+				Name:      identName(alias["Name"]),
+				Kind:      Function,
+				Positions: positions(alias["Name"]),
+				Doc:       commentText(meta["docstring"]),
+			})
+		}
+		return
+	case uast.TypeOf(uast.Group{}):
+		if meta, alias, ok := group(obj); ok && typeOf(alias["Node"]) == uast.TypeOf(uast.Block{}) {
+			b.define(Symbol{
+				Name:      identName(alias["Name"]),
+				Kind:      Class,
+				Positions: positions(alias["Name"]),
+				Doc:       commentText(meta["docstring"]),
+			})
+			return
+		}
+	case uast.TypeOf(uast.RuntimeImport{}):
+		b.imports(obj)
+		return
+	case uast.TypeOf(uast.Function{}):
+		// lambdas
+		return
+	case "Assign":
+		if obj["operator"] != nil {
+			// augmented assignments do not define the variables
+			if isAll(obj["targets"]) {
+				b.t.All = append(b.t.All, strs(obj["value"])...)
+			}
+			return
+		}
+		if isAll(obj["targets"]) && obj["value"] != nil {
+			b.t.All = strs(obj["value"])
+			if b.t.All == nil {
+				b.t.All = []string{}
+			}
+		}
+		b.targets(obj["targets"], docstring(next))
+		return
+	case "Expr":
+		b.extendAll(obj["value"])
+		return
+	}
+	for _, k := range obj.Keys() {
+		b.walk(obj[k])
+	}
+}
+
+// group returns the first node of a function or class group and the alias binding
+// the name.
+func group(obj nodes.Object) (meta, alias nodes.Object, _ bool) {
+	list, _ := obj["Nodes"].(nodes.Array)
+	if len(list) != 2 {
+		return nil, nil, false
+	}
+	meta, _ = list[0].(nodes.Object)
+	alias, ok := list[1].(nodes.Object)
+	if !ok || typeOf(alias) != uast.TypeOf(uast.Alias{}) {
+		return nil, nil, false
+	}
+	return meta, alias, true
+}
+
+// targets defines the variables assigned in the targets, including the ones in
+// destructuring assignments.
+func (b *builder) targets(n nodes.Node, doc string) {
+	switch n := n.(type) {
+	case nodes.Array:
+		for _, e := range n {
+			b.targets(e, doc)
+		}
+	case nodes.Object:
+		switch typeOf(n) {
+		case "BoxedName":
+			b.define(Symbol{
+				Name:      identName(n["boxed_value"]),
+				Kind:      Variable,
+				Positions: positions(n["boxed_value"]),
+				Doc:       doc,
+			})
+		case "Tuple", "List":
+			b.targets(n["elts"], doc)
+		case "Starred":
+			b.targets(n["value"], doc)
+		}
+	}
+}
+
+// imports defines the names bound by an import statement.
+func (b *builder) imports(obj nodes.Object) {
+	module := importPath(obj["Path"])
+	names, _ := obj["Names"].(nodes.Array)
+	if len(names) == 0 {
+		if obj["All"] == nodes.Bool(true) {
+			return
+		}
+		path, _ := obj["Path"].(nodes.Object)
+		switch typeOf(path) {
+		case uast.TypeOf(uast.Alias{}):
+			b.importSymbol(path["Name"], importPath(path["Node"]))
+		case uast.TypeOf(uast.QualifiedIdentifier{}):
+			// "import a.b" binds "a"
+			if ids, _ := path["Names"].(nodes.Array); len(ids) != 0 {
+				b.importSymbol(ids[0], identName(ids[0]))
+			}
+		default:
+			b.importSymbol(path, module)
+		}
+		return
+	}
+	prefix := module
+	if !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	for _, name := range names {
+		name, _ := name.(nodes.Object)
+		if typeOf(name) == uast.TypeOf(uast.Alias{}) {
+			b.importSymbol(name["Name"], prefix+importPath(name["Node"]))
+		} else {
+			b.importSymbol(name, prefix+identName(name))
+		}
+	}
+}
+
+func (b *builder) importSymbol(id nodes.Node, path string) {
+	b.define(Symbol{
+		Name:      identName(id),
+		Kind:      Import,
+		Positions: positions(id),
+		Path:      path,
+	})
+}
+
+// importPath returns the dotted path of an identifier or qualified identifier of an
+// import. The levels of relative imports are ".." identifiers.
+func importPath(n nodes.Node) string {
+	obj, _ := n.(nodes.Object)
+	var ids nodes.Array
+	switch typeOf(obj) {
+	case uast.TypeOf(uast.Identifier{}):
+		ids = nodes.Array{obj}
+	case uast.TypeOf(uast.QualifiedIdentifier{}):
+		ids, _ = obj["Names"].(nodes.Array)
+	}
+	dots := ""
+	var names []string
+	for _, id := range ids {
+		switch name := identName(id); {
+		case name == ".." && len(names) == 0:
+			dots += "."
+		case name == "." && len(ids) == 1:
+			dots = "."
+		default:
+			names = append(names, name)
+		}
+	}
+	return dots + strings.Join(names, ".")
+}
+
+// isAll checks if the assignment target is the __all__ variable.
+func isAll(n nodes.Node) bool {
+	if arr, ok := n.(nodes.Array); ok {
+		if len(arr) != 1 {
+			return false
+		}
+		n = arr[0]
+	}
+	obj, _ := n.(nodes.Object)
+	return typeOf(obj) == "BoxedName" && identName(obj["boxed_value"]) == "__all__"
+}
+
+// extendAll adds the names of __all__.append and __all__.extend calls to the list.
+func (b *builder) extendAll(n nodes.Node) {
+	call, _ := n.(nodes.Object)
+	if typeOf(call) != "Call" {
+		return
+	}
+	fnc, _ := call["callee"].(nodes.Object)
+	ids, _ := fnc["identifiers"].(nodes.Array)
+	if typeOf(fnc) != "QualifiedIdentifier" || len(ids) != 2 || !isAll(ids[0]) {
+		return
+	}
+	attr, _ := ids[1].(nodes.Object)
+	args, _ := call["args"].(nodes.Array)
+	if len(args) != 1 {
+		return
+	}
+	arg, _ := args[0].(nodes.Object)
+	if arg["Variadic"] == nodes.Bool(true) {
+		return
+	}
+	switch identName(attr["boxed_value"]) {
+	case "append":
+		if s, ok := str(arg["Init"]); ok {
+			b.t.All = append(b.t.All, s)
+		}
+	case "extend":
+		b.t.All = append(b.t.All, strs(arg["Init"])...)
+	}
+}
+
+// strs returns the strings of a literal list or tuple.
+func strs(n nodes.Node) []string {
+	obj, _ := n.(nodes.Object)
+	switch typeOf(obj) {
+	case "List", "Tuple":
+	default:
+		return nil
+	}
+	elts, _ := obj["elts"].(nodes.Array)
+	var out []string
+	for _, e := range elts {
+		if s, ok := str(e); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// str returns the value of a string literal.
+func str(n nodes.Node) (string, bool) {
+	obj, _ := n.(nodes.Object)
+	if typeOf(obj) != "BoxedStr" {
+		return "", false
+	}
+	val, _ := obj["boxed_value"].(nodes.Object)
+	s, ok := val["Value"].(nodes.String)
+	return string(s), ok
+}
+
+// docstring returns the string of an expression statement, used as the docstring of
+// the previous assignment.
+func docstring(n nodes.Node) string {
+	obj, _ := n.(nodes.Object)
+	if typeOf(obj) != "Expr" {
+		return ""
+	}
+	s, _ := str(obj["value"])
+	return strings.TrimSpace(s)
+}
+
+func identName(n nodes.Node) string {
+	obj, _ := n.(nodes.Object)
+	name, _ := obj["Name"].(nodes.String)
+	return string(name)
+}
+
+func positions(n nodes.Node) uast.Positions {
+	obj, _ := n.(nodes.Object)
+	return uast.PositionsOf(obj)
+}
+
+// commentText returns the text of a docstring comment.
+func commentText(n nodes.Node) string {
+	obj, _ := n.(nodes.Object)
+	text, _ := obj["Text"].(nodes.String)
+	return string(text)
+}
@@ -0,0 +1,120 @@
+package symbols
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+	"github.com/stretchr/testify/require"
+)
+
+const fixturesDir = "../../fixtures"
+
+func readTable(t testing.TB, path string) *Table {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	ast, err := uastyaml.Unmarshal(data)
+	require.NoError(t, err)
+	tbl, err := FromUAST(ast)
+	require.NoError(t, err)
+	return tbl
+}
+
+// TestFixtures checks that the symbol tables of all the fixtures are consistent.
+func TestFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(fixturesDir, "*.sem.uast"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), ".sem.uast")
+		t.Run(name, func(t *testing.T) {
+			tbl := readTable(t, path)
+			seen := make(map[string]bool)
+			var last uint32
+			for _, s := range tbl.Symbols {
+				require.NotEmpty(t, s.Name)
+				require.False(t, seen[s.Name], "duplicated symbol %q", s.Name)
+				seen[s.Name] = true
+				// the native AST has no position for some one-line functions
+				if start := s.Positions.Start(); start != nil {
+					require.True(t, start.Offset >= last, "symbol %q is not sorted", s.Name)
+					last = start.Offset
+				}
+				if s.Kind == Import {
+					require.NotEmpty(t, s.Path, "import %q has no path", s.Name)
+				}
+			}
+		})
+	}
+}
+
+type symbol struct {
+	Name     string
+	Kind     Kind
+	Line     uint32
+	Doc      string
+	Path     string
+	Exported bool
+}
+
+func summary(tbl *Table) []symbol {
+	var out []symbol
+	for _, s := range tbl.Symbols {
+		out = append(out, symbol{
+			Name: s.Name, Kind: s.Kind, Line: s.Positions.Start().Line,
+			Doc: s.Doc, Path: s.Path, Exported: s.Exported,
+		})
+	}
+	return out
+}
+
+func TestModuleSymbols(t *testing.T) {
+	tbl := readTable(t, filepath.Join(fixturesDir, "module_symbols.py.sem.uast"))
+	require.Equal(t, "Symbols exported by a module.", tbl.Doc)
+	require.Equal(t, []string{"Config", "load", "DEFAULT", "Ordered", "VERSION"}, tbl.All)
+	require.Equal(t, []symbol{
+		{Name: "os", Kind: Import, Line: 3, Path: "os"},
+		{Name: "_json", Kind: Import, Line: 4, Path: "json"},
+		{Name: "Ordered", Kind: Import, Line: 5, Path: "collections.OrderedDict", Exported: true},
+		{Name: "namedtuple", Kind: Import, Line: 5, Path: "collections.namedtuple"},
+		{Name: "sibling", Kind: Import, Line: 6, Path: ".sibling"},
+		{Name: "safe_load", Kind: Variable, Line: 11},
+		{Name: "__all__", Kind: Variable, Line: 13},
+		{Name: "VERSION", Kind: Variable, Line: 17, Exported: true},
+		{Name: "DEFAULT", Kind: Variable, Line: 18, Doc: "The default configuration.", Exported: true},
+		{Name: "_EXTRA", Kind: Variable, Line: 18, Doc: "The default configuration."},
+		{Name: "counter", Kind: Variable, Line: 21},
+		{Name: "Config", Kind: Class, Line: 24, Doc: "A configuration.", Exported: true},
+		{Name: "load", Kind: Function, Line: 30, Doc: "Load the configuration in the path.", Exported: true},
+		{Name: "_reload", Kind: Function, Line: 36},
+	}, summary(tbl))
+}
+
+func TestNoAll(t *testing.T) {
+	tbl := readTable(t, filepath.Join(fixturesDir, "docstrings.py.sem.uast"))
+	require.Nil(t, tbl.All)
+	require.Equal(t, "Module docstring.\n\nThe indentation of the lines after the first one is removed.", tbl.Doc)
+	require.Equal(t, []symbol{
+		{Name: "os", Kind: Import, Line: 8, Path: "os"},
+		{Name: "func", Kind: Function, Line: 11, Doc: "Summary line.\n\nArgs:\n    a: the argument.", Exported: true},
+		{Name: "only_doc", Kind: Function, Line: 20, Doc: `Raw docstring with a \n escape.`, Exported: true},
+		{Name: "Klass", Kind: Class, Line: 24, Doc: "Class docstring.", Exported: true},
+		{Name: "no_doc", Kind: Function, Line: 35, Exported: true},
+		{Name: "bytes_doc", Kind: Function, Line: 40, Exported: true},
+	}, summary(tbl))
+
+	s, ok := tbl.Lookup("Klass")
+	require.True(t, ok)
+	require.Equal(t, Class, s.Kind)
+	_, ok = tbl.Lookup("method")
+	require.False(t, ok)
+}
+
+func TestNotModule(t *testing.T) {
+	_, err := FromUAST(nodes.Object{})
+	require.Equal(t, ErrNotModule, err)
+}
@@ -0,0 +1,4 @@
+count: int
+names: List[str] = []
+self.value: Optional[str]
+(wrapped): int = 0
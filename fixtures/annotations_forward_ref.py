@@ -0,0 +1,17 @@
+from typing import List, Literal, Optional
+
+
+class Node:
+    def add(self, child: "Node", *rest: 'Node', **kw: int) -> "List[Node]":
+        pass
+
+    def parent(self, *, default: Optional["Node"] = None) -> Optional['Node']:
+        pass
+
+
+def mode(m: Literal["r", "w"], escaped: "List[\x69nt]", bad: "not valid!") -> None:
+    pass
+
+
+children: "List[Node]" = []
+count: int
@@ -0,0 +1,2 @@
+a = b = 1
+x = y.attr = z[0] = compute()
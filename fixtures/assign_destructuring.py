@@ -0,0 +1,4 @@
+a, *b = xs
+[c, d] = pair
+(e, (f, g)), h = nested
+first, *_, last = items
@@ -0,0 +1,5 @@
+total **= 2
+self.count += 1
+table[key] |= mask
+quotient //= divisor
+matrix @= other
@@ -0,0 +1,31 @@
+for item in items:
+    if item.ok:
+        break
+else:
+    handle_missing()
+
+while retries:
+    retries -= 1
+else:
+    give_up()
+
+if a:
+    pass
+elif b:
+    pass
+
+try:
+    run()
+except (KeyError, ValueError) as err:
+    log(err)
+except Exception:
+    raise
+else:
+    commit()
+finally:
+    close()
+
+async def consume(source, lock):
+    async with lock as held, open_file() as f:
+        async for chunk in source:
+            f.write(chunk)
@@ -0,0 +1,49 @@
+import abc
+import functools as ft
+from typing import overload
+from abc import abstractmethod as abstract
+
+
+def logged(func):
+    @ft.wraps(func)
+    def wrapper(*args, **kwargs):
+        return func(*args, **kwargs)
+    return wrapper
+
+
+class Shape(abc.ABC):
+    @staticmethod
+    def create(kind):
+        pass
+
+    @classmethod
+    def default(cls):
+        pass
+
+    @property
+    def area(self):
+        return self._area
+
+    @area.setter
+    def area(self, value):
+        self._area = value
+
+    @area.deleter
+    def area(self):
+        del self._area
+
+    @abstract
+    def draw(self):
+        pass
+
+    @abc.abstractproperty
+    def name(self):
+        pass
+
+    @overload
+    def scale(self, factor: int) -> None: ...
+
+    @logged
+    @ft.lru_cache(maxsize=None)
+    def scale(self, factor):
+        pass
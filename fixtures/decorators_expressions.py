@@ -0,0 +1,27 @@
+buttons = [QPushButton(f'Button {i}') for i in range(10)]
+
+
+@buttons[0].clicked.connect
+def spam():
+    pass
+
+
+@(lambda f: f)
+def eggs():
+    pass
+
+
+@handlers["x"](1).register
+class Ham:
+    @property
+    def name(self):
+        pass
+
+    @(cache := functools.cache)
+    def value(self):
+        pass
+
+
+@a.b(c)
+def bacon():
+    pass
@@ -0,0 +1,41 @@
+#!/usr/bin/env python
+# module comment
+"""Module docstring.
+
+    The indentation of the lines after the first one is removed.
+"""  # same line
+
+import os
+
+
+def func(a):
+    """Summary line.
+
+    Args:
+        a: the argument.
+    """
+    return a
+
+
+def only_doc():
+    r'''Raw docstring with a \n escape.'''
+
+
+class Klass:
+    """
+    Class docstring.
+    """
+
+    async def method(self):
+        """One line."""
+        "not a docstring"
+        pass
+
+
+def no_doc():
+    x = "not a docstring"
+    return x
+
+
+def bytes_doc():
+    b"not a docstring"
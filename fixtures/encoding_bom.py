@@ -0,0 +1,2 @@
+﻿nombre = "naïve"; größe = len(nombre)
+print(nombre, größe)
@@ -0,0 +1,3 @@
+# vim: set fileencoding=cp1252 :
+price = "10 "  # euro sign
+quote = "smart and single"
@@ -0,0 +1,6 @@
+# -*- coding: latin-1 -*-
+# Ce fichier est encod en Latin-1
+caf = "crme brle"
+
+def seal(ao):
+    return ao + len(caf)
@@ -0,0 +1,4 @@
+#!/usr/bin/env python
+# coding: shift_jis
+message = "ɂ"
+length = len(message)  # 
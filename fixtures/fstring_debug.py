@@ -0,0 +1,6 @@
+x = 1
+print(f"{x=}")
+print(f"{x = }")
+print(f"{x=!s}")
+print(f"{x=:>10}")
+print(f"{x == 1}")
@@ -0,0 +1,7 @@
+def f(a, b=1, /, c=2, *, d, e=3, **kwargs):
+    pass
+
+def g(a, /):
+    pass
+
+h = lambda a, /, b=1: a + b
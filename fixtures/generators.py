@@ -0,0 +1,32 @@
+def gen():
+    yield 1
+
+
+def gen_from():
+    yield from gen()
+
+
+def gen_expr():
+    x = yield
+    return x
+
+
+def not_gen():
+    def inner():
+        yield 1
+    f = lambda: (yield)
+    return (i for i in inner())
+
+
+def gen_default(a=(lambda: 1)):
+    if a:
+        for i in a():
+            yield i
+
+
+async def coro():
+    await coro()
+
+
+async def async_gen():
+    yield 1
@@ -0,0 +1,12 @@
+# comment before the import
+import os  # comment after the import
+from sys import (
+    path,
+)
+
+
+def f(a,  # comment after a parameter
+      # comment before a parameter
+      b=1, *args, c, **kwargs) -> int:  # comment after the return type
+    # comment in the body
+    return os.path.join(a, sep=b)  # comment after the call
@@ -0,0 +1,20 @@
+match command.split():
+    case [action]:
+        pass
+    case ["go", direction] if direction in exits:
+        go(direction)
+    case ["drop", *objects]:
+        drop(objects)
+    case Point(x=0, y=0) | Point(0, 0):
+        print("Origin")
+    case {"x": x, "y": y, **rest}:
+        print(x, y, rest)
+    case Color.RED as color:
+        print(color)
+    case (1 | -2 | 3+4j, None, True):
+        pass
+    case _:
+        pass
+
+match = 1
+match(x)
@@ -0,0 +1,37 @@
+"""Symbols exported by a module."""
+
+import os.path
+import json as _json
+from collections import OrderedDict as Ordered, namedtuple
+from . import sibling
+
+try:
+    from yaml import safe_load
+except ImportError:
+    safe_load = None
+
+__all__ = ["Config", "load", "DEFAULT"]
+__all__ += ("Ordered",)
+__all__.append("VERSION")
+
+VERSION = "1.0"
+DEFAULT, _EXTRA = {}, []
+"""The default configuration."""
+
+counter: int = 0
+
+
+class Config(object):
+    """A configuration."""
+
+    name = "config"
+
+
+def load(path):
+    """Load the configuration in the path."""
+    with open(path) as f:
+        return Config(_json.load(f))
+
+
+async def _reload():
+    pass
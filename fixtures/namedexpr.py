@@ -0,0 +1,8 @@
+if (n := len(a)) > 10:
+    print(n)
+
+while chunk := read(256):
+    process(chunk)
+
+filtered = [y for x in data if (y := f(x)) is not None]
+call(value := 42)
@@ -0,0 +1,5 @@
+a = 1e400
+b = -1_0e400
+c = 1e400j
+d = 1.5e-400
+e = 2E+308
@@ -0,0 +1,7 @@
+i = 42
+big = 123_456_789_012_345_678_901_234_567_890
+h, o, b = 0xFF, 0o17, 0b1010
+f = 1_000.5
+e = 1e-3
+c = 3.5j
+neg = -7
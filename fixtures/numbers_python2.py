@@ -0,0 +1,5 @@
+o = 0777
+l = 10L
+h = 0x1FL
+z = 00
+big = 123456789012345678901234567890L
@@ -0,0 +1,12 @@
+import os.path, sys as system
+from ..pkg.mod import (first,
+    second as other)
+
+@decorator(key=1)
+@other.decorator
+def decorated(a, b=2):
+    return call(a, flag=True, **b)
+
+@decorator
+class Decorated(Base, metaclass=Meta):
+    def método(self): pass
@@ -0,0 +1,11 @@
+def f(a, b):
+    return *a, b
+
+
+def g(a, b):
+    return a, *b,
+
+
+def h(a, b):
+    yield *a, b
+    x = yield *a, *b
@@ -0,0 +1,52 @@
+from typing import List, Optional
+
+
+def join(sep, items, *rest, **kw):  # type: (str, List[str], *int, **bool) -> str
+    return sep.join(items)
+
+
+class Stack:
+    def push(self, item):
+        # type: (int) -> None
+        self.items = []  # type: List[int]
+        self.items.append(item)
+
+    def pop(self, default=None):
+        # type: (Optional[int]) -> Optional[int]  # trailing comment
+        pass
+
+    def annotated(self, item: str):
+        # type: (int) -> bool
+        pass
+
+    def mismatch(self, a, b):
+        # type: (int) -> None
+        pass
+
+
+def ellipsis(a, b):
+    # type: (...) -> None
+    pass
+
+
+def untyped(a):
+    # just a comment
+    pass
+
+
+x = None  # type: Optional[Stack]
+y = 1  # type: ignore
+
+
+def per_argument(a,  # type: int
+                 b,  # type: str
+                 *args  # type: bool
+                 ):
+    # type: (...) -> None
+    pass
+
+
+def commented(a):  # noqa
+    # a comment
+    # type: (int) -> str
+    pass
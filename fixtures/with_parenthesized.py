@@ -0,0 +1,21 @@
+with (open("a") as a, open("b") as b):
+    pass
+
+with (
+    open("a") as a,
+    open("b"),
+):
+    pass
+
+with (a, b):
+    pass
+
+with (a, b) as c:
+    pass
+
+with (a) as b, c:
+    pass
+
+async def f():
+    async with (a as b, c as d):
+        pass
@@ -1,12 +1,5 @@
 #!/bin/sh
-# cd to script dir
-cd ${0%/*}
-
-if [ -x ".local/bin/python_driver" ]
-then
-    export PATH=.local/bin:$PATH
-    export PYTHONPATH=.local/lib/python3.6/site-packages:$PYTHONPATH
-    python3 .local/bin/python_driver
-else
-    python3 python_driver
-fi
+# The native driver is the Go parser linked into the driver server (see driver/impl),
+# so there is no native process to start. This file is not part of the driver image.
+echo "the native driver is linked into the driver server" >&2
+exit 1